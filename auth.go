@@ -0,0 +1,73 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+)
+
+// openKeyStore returns the API key store of the config, or nil if authentication is disabled.
+// Returns the store and an error, if any.
+func openKeyStore(config middleware.AuthConfig, duckdbConn *sql.DB) (middleware.KeyStore, error) {
+	switch config.KeyStore {
+	case middleware.KeyStoreNone:
+		return nil, nil
+	case middleware.KeyStoreFile:
+		if config.KeysFile == "" {
+			return nil, errors.New("missing API keys file, use --auth-keys or auth.keys_file in --config")
+		}
+		return middleware.NewFileKeyStore(config.KeysFile)
+	case middleware.KeyStoreDuckDB:
+		return middleware.NewDuckDBKeyStore(duckdbConn, config.KeysTable)
+	default:
+		return nil, fmt.Errorf("unknown API key store %q, must be %q or %q",
+			config.KeyStore, middleware.KeyStoreFile, middleware.KeyStoreDuckDB)
+	}
+}
+
+// createAPIKey creates the API key of --new-api-key.  With the "duckdb" store, the key is
+// inserted into the table of the --db file, which the server must not have open, and its
+// token is printed.  Otherwise the key's YAML entry is printed to stdout, to append to the
+// keys file, and its token to stderr.  Returns an error, if any.
+func createAPIKey(config ServiceConfig, cmdFlags serviceFlags) error {
+	key, token, err := middleware.NewAPIKey(cmdFlags.NewAPIKey, cmdFlags.NewAPIKeyScopes, cmdFlags.NewAPIKeyDatasets)
+	if err != nil {
+		return err
+	}
+
+	if config.Auth.KeyStore != middleware.KeyStoreDuckDB {
+		out, err := yaml.Marshal([]middleware.APIKey{key})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "token: %s\n", token)
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+
+	if config.DuckDBFile == "" {
+		return errors.New("the duckdb API key store needs a --db file")
+	}
+	duckdbConn, err := sql.Open("duckdb", config.DuckDBFile)
+	if err != nil {
+		return err
+	}
+	defer duckdbConn.Close()
+	store, err := middleware.NewDuckDBKeyStore(duckdbConn, config.Auth.KeysTable)
+	if err != nil {
+		return err
+	}
+	if err := store.AddKey(context.Background(), key); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "token: %s\n", token)
+	return nil
+}
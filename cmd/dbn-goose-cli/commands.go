@@ -0,0 +1,358 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/relvacode/iso8601"
+	"github.com/spf13/pflag"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/tui"
+)
+
+// watchWidths are the column widths of the watch command's table output
+var watchWidths = []int{35, 6, 8, 10, 10, 10, 10, 8}
+
+///////////////////////////////////////////////////////////////////////////////
+// Flags
+
+func tradesFlags(flags *pflag.FlagSet, opts *options) {
+	flags.IntVarP(&opts.Count, "count", "n", 0, "Number of trades to show (default: the server's, 25)")
+}
+
+func timeRangeFlags(flags *pflag.FlagSet, opts *options) {
+	flags.StringVarP(&opts.Start, "start", "s", "", "Start of the time range in ISO 8601 format (default: midnight Eastern)")
+	flags.StringVarP(&opts.End, "end", "e", "", "End of the time range in ISO 8601 format (default: now)")
+}
+
+func exportFlags(flags *pflag.FlagSet, opts *options) {
+	timeRangeFlags(flags, opts)
+	flags.StringVarP(&opts.Table, "table", "t", "candles", "Table to export: trades or candles")
+	flags.StringVarP(&opts.Format, "format", "f", "csv", "File format: csv, xlsx, parquet or json")
+	flags.IntVarP(&opts.Limit, "limit", "l", 0, "Maximum rows to export (default: the server's maximum)")
+	flags.StringVarP(&opts.Out, "out", "O", "", "Output filename, '-' for stdout (default: the server's filename)")
+}
+
+func watchFlags(flags *pflag.FlagSet, opts *options) {
+	flags.StringSliceVarP(&opts.Schemas, "schemas", "", []string{"trades", "ohlcv-1m"}, "Schemas to follow: trades, ohlcv-1m")
+}
+
+func tuiFlags(flags *pflag.FlagSet, opts *options) {
+	watchFlags(flags, opts)
+	flags.StringVarP(&opts.File, "file", "f", "", "DBN file to follow instead of the server, such as the server's --out")
+	flags.BoolVarP(&opts.Follow, "follow", "", true, "Wait for records appended to --file")
+}
+
+// parseTimeRange parses the --start and --end flags
+func parseTimeRange(opts *options) (sdk.TimeRange, error) {
+	var timeRange sdk.TimeRange
+	var err error
+	if opts.Start != "" {
+		if timeRange.Start, err = iso8601.ParseString(opts.Start); err != nil {
+			return timeRange, usageError(fmt.Sprintf("failed to parse --start as ISO 8601 time: %s", err.Error()))
+		}
+	}
+	if opts.End != "" {
+		if timeRange.End, err = iso8601.ParseString(opts.End); err != nil {
+			return timeRange, usageError(fmt.Sprintf("failed to parse --end as ISO 8601 time: %s", err.Error()))
+		}
+	}
+	return timeRange, nil
+}
+
+// datasetTickerArgs returns the dataset and ticker positional arguments
+func datasetTickerArgs(flags *pflag.FlagSet) (string, string, error) {
+	if flags.NArg() != 2 {
+		return "", "", usageError("expected <dataset> <ticker> arguments")
+	}
+	return flags.Arg(0), flags.Arg(1), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Commands
+
+func runTrades(ctx context.Context, opts *options, flags *pflag.FlagSet) error {
+	dataset, ticker, err := datasetTickerArgs(flags)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	trades, err := client.LastTrades(ctx, dataset, ticker, opts.Count)
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		return writeJSON(os.Stdout, trades)
+	}
+	rows := make([][]string, len(trades))
+	for i, trade := range trades {
+		rows[i] = []string{
+			formatTimestamp(trade.Timestamp, trade.Nanos),
+			trade.Ticker,
+			strconv.Itoa(int(trade.PublisherID)),
+			formatPrice(trade.Price),
+			strconv.FormatInt(trade.Shares, 10),
+		}
+	}
+	return writeRows(os.Stdout, opts.Output, []string{"TIME", "SYMBOL", "PUB", "PRICE", "SHARES"}, rows)
+}
+
+func runCandles(ctx context.Context, opts *options, flags *pflag.FlagSet) error {
+	dataset, ticker, err := datasetTickerArgs(flags)
+	if err != nil {
+		return err
+	}
+	timeRange, err := parseTimeRange(opts)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	candles, err := client.Candles(ctx, dataset, ticker, timeRange)
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		return writeJSON(os.Stdout, candles)
+	}
+	rows := make([][]string, len(candles))
+	for i, candle := range candles {
+		rows[i] = []string{
+			formatTimestamp(candle.Timestamp, candle.Nanos),
+			candle.Ticker,
+			formatPrice(candle.Open),
+			formatPrice(candle.High),
+			formatPrice(candle.Low),
+			formatPrice(candle.Close),
+			strconv.FormatUint(candle.Volume, 10),
+		}
+	}
+	return writeRows(os.Stdout, opts.Output, []string{"TIME", "SYMBOL", "OPEN", "HIGH", "LOW", "CLOSE", "VOLUME"}, rows)
+}
+
+// runExport downloads the file to --out, or to the server's filename in the current
+// directory.  The file's description is printed to stderr, unless written to stdout.
+func runExport(ctx context.Context, opts *options, flags *pflag.FlagSet) error {
+	dataset, ticker, err := datasetTickerArgs(flags)
+	if err != nil {
+		return err
+	}
+	timeRange, err := parseTimeRange(opts)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	exportOpts := sdk.ExportOptions{TimeRange: timeRange, Table: opts.Table, Format: opts.Format, Limit: opts.Limit}
+
+	if opts.Out == "-" {
+		_, err := client.Export(ctx, dataset, ticker, exportOpts, os.Stdout)
+		return err
+	}
+
+	// Until the server names the file, write to a temporary file beside it
+	dir := "."
+	if opts.Out != "" {
+		dir = filepath.Dir(opts.Out)
+	}
+	tmpFile, err := os.CreateTemp(dir, ".dbn-goose-export-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name()) // fails harmlessly once renamed
+	info, err := client.Export(ctx, dataset, ticker, exportOpts, tmpFile)
+	if closeErr := tmpFile.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write file: %w", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	filename := opts.Out
+	if filename == "" {
+		filename = filepath.Base(info.Filename)
+		if filename == "." || filename == string(filepath.Separator) {
+			filename = fmt.Sprintf("%s-%s-%s.%s", dataset, ticker, opts.Table, opts.Format)
+		}
+	}
+	if err := os.Rename(tmpFile.Name(), filename); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d rows (%s) to %s\n", info.RowCount, formatBytes(info.Size), filename)
+	return nil
+}
+
+func runSubscriptions(ctx context.Context, opts *options, flags *pflag.FlagSet) error {
+	if flags.NArg() != 0 {
+		return usageError("expected no arguments")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	subscriptions, err := client.Subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		return writeJSON(os.Stdout, subscriptions)
+	}
+	rows := make([][]string, len(subscriptions))
+	for i, subscription := range subscriptions {
+		rows[i] = []string{
+			subscription.Dataset,
+			strings.Join(subscription.Schemas, ","),
+			strings.Join(subscription.Symbols, ","),
+		}
+	}
+	return writeRows(os.Stdout, opts.Output, []string{"DATASET", "SCHEMAS", "SYMBOLS"}, rows)
+}
+
+// runWatch follows the WebSocket gateway until interrupted, writing a row for each
+// trade and candle.  JSON output is a line for each sdk.StreamMessage.
+func runWatch(ctx context.Context, opts *options, flags *pflag.FlagSet) error {
+	if flags.NArg() < 2 {
+		return usageError("expected <dataset> <ticker>... arguments")
+	}
+	dataset, tickers := flags.Arg(0), flags.Args()[1:]
+	streamURL, err := streamURLOf(opts.ServerURL)
+	if err != nil {
+		return err
+	}
+
+	dialCtx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	stream, err := sdk.DialStream(dialCtx, streamURL, streamHeader(opts))
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	for _, schema := range opts.Schemas {
+		if _, err := stream.Subscribe(dataset, schema, tickers...); err != nil {
+			return err
+		}
+	}
+
+	var rw rowWriter
+	if opts.Output != "json" {
+		header := []string{"TIME", "TYPE", "SYMBOL", "OPEN", "HIGH", "LOW", "CLOSE", "VOLUME"}
+		if rw, err = newRowWriter(os.Stdout, opts.Output, header, watchWidths); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil // interrupted
+		case msg, ok := <-stream.Messages():
+			if !ok {
+				if err := stream.Err(); err != nil {
+					return fmt.Errorf("stream ended: %w", err)
+				}
+				return nil
+			}
+			if err := writeStreamMessage(os.Stdout, rw, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// runTUI shows the watchlist of the server's stream, or of a DBN file, until the user quits
+func runTUI(ctx context.Context, opts *options, flags *pflag.FlagSet) error {
+	if opts.File != "" {
+		return tui.Run(ctx, tui.Config{
+			Title:   filepath.Base(opts.File),
+			Tickers: flags.Args(),
+			Source:  tui.FileSource(opts.File, opts.Follow),
+		})
+	}
+
+	if flags.NArg() < 2 {
+		return usageError("expected <dataset> <ticker>... arguments, or --file")
+	}
+	dataset, tickers := flags.Arg(0), flags.Args()[1:]
+	streamURL, err := streamURLOf(opts.ServerURL)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	return tui.Run(ctx, tui.Config{
+		Title:   dataset + " " + opts.ServerURL,
+		Tickers: tickers,
+		Source:  tui.StreamSource(client, streamURL, streamHeader(opts), dataset, opts.Schemas, tickers),
+	})
+}
+
+// writeStreamMessage writes a trade or candle as a row, or any message as JSON if rw is nil.
+// Acknowledgements and errors are written to stderr.
+func writeStreamMessage(w io.Writer, rw rowWriter, msg sdk.StreamMessage) error {
+	switch msg.Type {
+	case sdk.StreamMessageSubscribed:
+		fmt.Fprintf(os.Stderr, "subscribed to %s %s: %s\n", msg.Dataset, msg.Schema, strings.Join(msg.Symbols, ","))
+	case sdk.StreamMessageError:
+		fmt.Fprintf(os.Stderr, "error: %s\n", msg.Message)
+	}
+	if rw == nil {
+		return writeJSONLine(w, msg)
+	}
+	switch {
+	case msg.Type == sdk.StreamMessageTrade && msg.Trade != nil:
+		trade := msg.Trade
+		return rw.Write([]string{formatTimestamp(trade.Timestamp, trade.Nanos), "trade", trade.Ticker,
+			"", "", "", formatPrice(trade.Price), strconv.FormatInt(trade.Shares, 10)})
+	case msg.Type == sdk.StreamMessageCandle && msg.Candle != nil:
+		candle := msg.Candle
+		return rw.Write([]string{formatTimestamp(candle.Timestamp, candle.Nanos), "candle", candle.Ticker,
+			formatPrice(candle.Open), formatPrice(candle.High), formatPrice(candle.Low), formatPrice(candle.Close),
+			strconv.FormatUint(candle.Volume, 10)})
+	}
+	return nil
+}
+
+// streamURLOf returns the WebSocket gateway URL of the server URL
+func streamURLOf(serverURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(serverURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("invalid server URL %q: scheme must be http or https", serverURL)
+	}
+	return u.JoinPath("/ws/v1").String(), nil
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2025 Neomantra Corp
+//
+// dbn-goose-cli queries a running dbn-duckduck-goose server from a terminal.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+)
+
+const (
+	defaultServerURL = "http://localhost:8888"
+	defaultTimeout   = 30 * time.Second
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	Name    string
+	Args    string // usage of the positional arguments
+	Summary string
+	Run     func(ctx context.Context, opts *options, flags *pflag.FlagSet) error
+	Flags   func(flags *pflag.FlagSet, opts *options) // adds the command's flags, may be nil
+}
+
+// options are the parsed flags of a command
+type options struct {
+	ServerURL string        // URL of the server
+	Token     string        // API key token of the server, if it enables authentication
+	Output    string        // output format: table, csv or json
+	Timeout   time.Duration // time limit of requests, 0 for none
+	ShowHelp  bool
+
+	// trades
+	Count int
+	// candles and export
+	Start string
+	End   string
+	// export
+	Table  string
+	Format string
+	Limit  int
+	Out    string
+	// watch and tui
+	Schemas []string
+	// tui
+	File   string
+	Follow bool
+}
+
+var commands = []command{
+	{Name: "trades", Args: "<dataset> <ticker>", Summary: "Show the last trades of a ticker", Run: runTrades, Flags: tradesFlags},
+	{Name: "candles", Args: "<dataset> <ticker>", Summary: "Show the one-minute candles of a ticker", Run: runCandles, Flags: timeRangeFlags},
+	{Name: "export", Args: "<dataset> <ticker>", Summary: "Download a file of a ticker's trades or candles", Run: runExport, Flags: exportFlags},
+	{Name: "subscriptions", Args: "", Summary: "List the datasets and symbols the server ingests", Run: runSubscriptions},
+	{Name: "watch", Args: "<dataset> <ticker>...", Summary: "Follow the live trades and candles of tickers", Run: runWatch, Flags: watchFlags},
+	{Name: "tui", Args: "<dataset> <ticker>... | --file <file> [ticker...]", Summary: "Show a live watchlist of tickers in the terminal", Run: runTUI, Flags: tuiFlags},
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		printUsage()
+		os.Exit(0)
+	}
+
+	cmd := findCommand(os.Args[1])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+
+	var opts options
+	flags := pflag.NewFlagSet(cmd.Name, pflag.ContinueOnError)
+	flags.StringVarP(&opts.ServerURL, "url", "u", "", fmt.Sprintf("URL of the server (or set 'GOOSE_URL' envvar) (default %q)", defaultServerURL))
+	flags.StringVarP(&opts.Token, "token", "", "", "API key token of the server (or set 'GOOSE_TOKEN' envvar)")
+	flags.StringVarP(&opts.Output, "output", "o", "table", "Output format: table, csv or json")
+	flags.DurationVarP(&opts.Timeout, "timeout", "", defaultTimeout, "Time limit of requests (0 for none)")
+	flags.BoolVarP(&opts.ShowHelp, "help", "h", false, "Show help")
+	if cmd.Flags != nil {
+		cmd.Flags(flags, &opts)
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [opts] %s\n\n%s\n\n", os.Args[0], cmd.Name, cmd.Args, cmd.Summary)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(2)
+	}
+	if opts.ShowHelp {
+		flags.Usage()
+		os.Exit(0)
+	}
+
+	if opts.ServerURL == "" {
+		opts.ServerURL = os.Getenv("GOOSE_URL")
+	}
+	if opts.ServerURL == "" {
+		opts.ServerURL = defaultServerURL
+	}
+	if opts.Token == "" {
+		opts.Token = os.Getenv("GOOSE_TOKEN")
+	}
+	switch opts.Output {
+	case "table", "csv", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --output: %s. Must be table, csv or json\n", opts.Output)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd.Run(ctx, &opts, flags); err != nil {
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(os.Stderr, "%s\n\n", err.Error())
+			flags.Usage()
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// printUsage prints the commands of the CLI
+func printUsage() {
+	fmt.Fprintf(os.Stdout, "usage: %s <command> [opts] [args]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stdout, "  %-14s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(os.Stdout, "\nRun '%s <command> --help' for the options of a command.\n", os.Args[0])
+}
+
+// findCommand returns the command of the name, or nil if there is none
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// usageError is an error in the arguments of a command
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// newClient returns an sdk.Client of the server
+func newClient(opts *options) (*sdk.Client, error) {
+	config := sdk.ClientConfig{BaseURL: opts.ServerURL}
+	if opts.Token != "" {
+		config.Auth = sdk.BearerToken(opts.Token)
+	}
+	return sdk.NewClient(config)
+}
+
+// streamHeader returns the header of WebSocket gateway requests, with the API key token, if any
+func streamHeader(opts *options) http.Header {
+	if opts.Token == "" {
+		return nil
+	}
+	return http.Header{"Authorization": {"Bearer " + opts.Token}}
+}
+
+// withTimeout returns ctx limited to the request timeout, if any
+func withTimeout(ctx context.Context, opts *options) (context.Context, context.CancelFunc) {
+	if opts.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, opts.Timeout)
+}
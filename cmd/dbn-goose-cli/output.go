@@ -0,0 +1,162 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// rowWriter writes rows of a table or CSV output
+type rowWriter interface {
+	Write(row []string) error
+	Flush() error
+}
+
+// newRowWriter returns a rowWriter of the header in the table or csv format.
+// Table rows are buffered to align their columns until Flush, unless widths
+// are given, in which case each row is written immediately, padded to them.
+func newRowWriter(w io.Writer, format string, header []string, widths []int) (rowWriter, error) {
+	if format == "csv" {
+		cw := &csvRowWriter{csv.NewWriter(w)}
+		return cw, cw.Write(header)
+	}
+	tw := &tableRowWriter{w: w, widths: widths}
+	return tw, tw.Write(header)
+}
+
+// csvRowWriter writes rows as CSV, flushing each for streaming output
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvRowWriter) Write(row []string) error {
+	if err := cw.w.Write(row); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvRowWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// tableRowWriter writes rows as space-aligned columns
+type tableRowWriter struct {
+	w      io.Writer
+	widths []int      // fixed column widths, nil to align buffered rows on Flush
+	rows   [][]string // buffered rows
+}
+
+func (tw *tableRowWriter) Write(row []string) error {
+	if tw.widths == nil {
+		tw.rows = append(tw.rows, row)
+		return nil
+	}
+	return tw.writeRow(row, tw.widths)
+}
+
+func (tw *tableRowWriter) Flush() error {
+	if tw.widths != nil {
+		return nil
+	}
+	var widths []int
+	for _, row := range tw.rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	for _, row := range tw.rows {
+		if err := tw.writeRow(row, widths); err != nil {
+			return err
+		}
+	}
+	tw.rows = nil
+	return nil
+}
+
+// writeRow writes the row with each cell but the last padded to its width
+func (tw *tableRowWriter) writeRow(row []string, widths []int) error {
+	var line strings.Builder
+	for i, cell := range row {
+		line.WriteString(cell)
+		if i < len(row)-1 {
+			width := 0
+			if i < len(widths) {
+				width = widths[i]
+			}
+			line.WriteString(strings.Repeat(" ", max(width-utf8.RuneCountInString(cell), 0)+2))
+		}
+	}
+	line.WriteByte('\n')
+	_, err := io.WriteString(tw.w, line.String())
+	return err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// writeJSON writes the value as indented JSON
+func writeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeJSONLine writes the value as a line of JSON
+func writeJSONLine(w io.Writer, value any) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// writeRows writes the rows of the header in the table or csv format
+func writeRows(w io.Writer, format string, header []string, rows [][]string) error {
+	rw, err := newRowWriter(w, format, header, nil)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := rw.Write(row); err != nil {
+			return err
+		}
+	}
+	return rw.Flush()
+}
+
+// formatTimestamp formats seconds and nanoseconds from the epoch as local RFC 3339
+func formatTimestamp(seconds int64, nanos int64) string {
+	return time.Unix(seconds, nanos).Format(time.RFC3339Nano)
+}
+
+// formatPrice formats a price with as few digits as needed
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// formatBytes formats a byte count for humans
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2025 Neomantra Corp
+//
+// The server's ServiceConfig is layered: the defaults, then the YAML file of
+// --config, then environment variables, then command-line flags.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/relvacode/iso8601"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/flightserver"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/handlers"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/pgserver"
+)
+
+// redactedSecret replaces secrets in printed configs
+const redactedSecret = "REDACTED"
+
+// MetricsConfig is the configuration of the Prometheus request and ingest metrics
+type MetricsConfig struct {
+	Path            string    `yaml:"path"`              // Route of the metrics
+	SlowTime        int32     `yaml:"slow_time"`         // Seconds after which a request is counted as slow
+	DurationBuckets []float64 `yaml:"duration_buckets"`  // Request duration histogram buckets in seconds
+	MaxSymbolLabels int       `yaml:"max_symbol_labels"` // Symbols given their own label in the ingest metrics; the rest are "_other"
+}
+
+// defaultServiceConfig returns the ServiceConfig before the config file, environment and flags are applied
+func defaultServiceConfig() ServiceConfig {
+	return ServiceConfig{
+		HostPort:        "localhost:8888",
+		LiveConfig:      livedata.LiveDataConfig{Schemas: slices.Clone(livedata.DefaultSchemas)},
+		ScratchDir:      os.TempDir(),
+		MaxExports:      4,
+		ShutdownTimeout: 30 * time.Second,
+		FlightSQL:       flightserver.Config{Username: "goose", QueryTimeout: 30 * time.Second},
+		PgWire:          pgserver.Config{Username: "goose", StatementTimeout: 30 * time.Second},
+		Query:           handlers.DefaultQueryConfig,
+		Health:          handlers.DefaultHealthConfig,
+		Cors:            middleware.DefaultCorsConfig,
+		Log:             middleware.DefaultLogConfig,
+		Tracing:         middleware.DefaultTracingConfig,
+		Auth:            middleware.DefaultAuthConfig,
+		Retention:       livedata.DefaultRetentionConfig,
+		Metrics: MetricsConfig{
+			Path:            "/metrics",
+			SlowTime:        5,
+			MaxSymbolLabels: livedata.DefaultMaxSymbolLabels,
+			// used for p95, p99
+			DurationBuckets: []float64{0.1, 0.3, 1.2, 5, 10},
+		},
+	}
+}
+
+// serviceFlags are the command-line flags that are not ServiceConfig settings
+type serviceFlags struct {
+	ConfigFile        string   // YAML config file
+	ShowConfig        bool     // Print the effective config and exit
+	NewAPIKey         string   // ID of an API key to create, then exit
+	NewAPIKeyScopes   []string // Scopes of the new API key
+	NewAPIKeyDatasets []string // Datasets of the new API key
+	ShowHelp          bool
+}
+
+// loadServiceConfig returns the ServiceConfig of the defaults, then the config file of the
+// command-line args, then the environment, then the flags of args.  It may be called again
+// to reload the config file.  Returns the config, the flags that are not settings, the
+// parsed flags for usage, and an error, if any.
+func loadServiceConfig(args []string) (ServiceConfig, serviceFlags, *pflag.FlagSet, error) {
+	var startTimeArg, apiKeyArg, flightPasswordArg, pgPasswordArg string
+	var cmdFlags serviceFlags
+
+	// Layer the defaults, config file and environment; they are the flags' defaults
+	config := defaultServiceConfig()
+	cmdFlags.ConfigFile = configFileArg(args)
+	if cmdFlags.ConfigFile != "" {
+		if err := loadConfigFile(cmdFlags.ConfigFile, &config); err != nil {
+			return config, cmdFlags, nil, fmt.Errorf("failed to load --config: %w", err)
+		}
+	}
+	applyEnvironment(&config)
+
+	flags := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {}
+	flags.StringVarP(&cmdFlags.ConfigFile, "config", "c", cmdFlags.ConfigFile, "YAML config file, overridden by envvars and flags")
+	flags.BoolVarP(&cmdFlags.ShowConfig, "print-config", "", false, "Print the effective config, with secrets redacted, and exit")
+	flags.StringVarP(&config.DuckDBFile, "db", "", config.DuckDBFile, "DuckDB datate file to use (':memory:' if empty)")
+	flags.StringVarP(&config.HostPort, "hostport", "p", config.HostPort, "'host:port' to service HTTP")
+	flags.StringVarP(&config.LiveConfig.Dataset, "dataset", "d", config.LiveConfig.Dataset, "Dataset to subscribe to")
+	flags.StringVarP(&apiKeyArg, "key", "k", "", "Databento API key (or set 'DATABENTO_API_KEY' envvar)")
+	flags.StringVarP(&config.LiveConfig.OutFilename, "out", "o", config.LiveConfig.OutFilename, "Output filename for DBN stream ('-' for stdout)")
+	flags.StringVarP(&startTimeArg, "start", "t", "", "Start time to request as ISO 8601 format (default: now)")
+	flags.BoolVarP(&config.LiveConfig.Snapshot, "snapshot", "n", config.LiveConfig.Snapshot, "Enable snapshot on subscription request")
+	flags.StringSliceVarP(&config.LiveConfig.Schemas, "schemas", "", config.LiveConfig.Schemas, "DBN schemas to ingest: trades and/or ohlcv-1m")
+	flags.StringVarP(&config.ScratchDir, "scratch", "", config.ScratchDir, "Parent of the private directory for export files, swept on startup and removed on shutdown")
+	flags.IntVarP(&config.MaxExports, "max-exports", "", config.MaxExports, "Maximum number of concurrent file exports")
+	flags.StringVarP(&config.FlightSQL.HostPort, "flight-hostport", "", config.FlightSQL.HostPort, "'host:port' to service Arrow Flight SQL (disabled if empty)")
+	flags.StringVarP(&config.FlightSQL.Username, "flight-user", "", config.FlightSQL.Username, "Flight SQL username")
+	flags.StringVarP(&flightPasswordArg, "flight-password", "", "", "Flight SQL password (or set 'FLIGHT_SQL_PASSWORD' envvar)")
+	flags.DurationVarP(&config.FlightSQL.QueryTimeout, "flight-timeout", "", config.FlightSQL.QueryTimeout, "Maximum time to execute a Flight SQL query")
+	flags.StringVarP(&config.PgWire.HostPort, "pg-hostport", "", config.PgWire.HostPort, "'host:port' to service the PostgreSQL wire protocol (disabled if empty)")
+	flags.StringVarP(&config.PgWire.Username, "pg-user", "", config.PgWire.Username, "PostgreSQL wire protocol username")
+	flags.StringVarP(&pgPasswordArg, "pg-password", "", "", "PostgreSQL wire protocol password (or set 'PGWIRE_PASSWORD' envvar)")
+	flags.DurationVarP(&config.PgWire.StatementTimeout, "pg-statement-timeout", "", config.PgWire.StatementTimeout, "Maximum statement_timeout of PostgreSQL wire protocol sessions")
+	flags.StringSliceVarP(&config.Query.Tables, "query-tables", "", config.Query.Tables, "Tables and views open to ad-hoc SQL, Flight SQL and PostgreSQL wire protocol queries")
+	flags.IntVarP(&config.Query.MaxRows, "query-max-rows", "", config.Query.MaxRows, "Maximum rows returned by an ad-hoc SQL or Flight SQL query")
+	flags.DurationVarP(&config.Query.Timeout, "query-timeout", "", config.Query.Timeout, "Maximum time to execute an ad-hoc SQL query")
+	flags.IntVarP(&config.Query.CacheSize, "query-cache-size", "", config.Query.CacheSize, "Maximum ad-hoc SQL query results cached (0 disables)")
+	flags.DurationVarP(&config.Query.CacheTTL, "query-cache-ttl", "", config.Query.CacheTTL, "Time an ad-hoc SQL query result is cached (0 disables)")
+	flags.DurationVarP(&config.Health.Staleness, "ready-staleness", "", config.Health.Staleness, "Time without live data after which /readyz reports not ready (0 disables)")
+	flags.StringVarP(&config.Tracing.Exporter, "tracing", "", config.Tracing.Exporter, "OpenTelemetry trace exporter: otlp or stdout (disabled if empty)")
+	flags.StringVarP(&config.Tracing.Endpoint, "tracing-endpoint", "", config.Tracing.Endpoint, "'host:port' of the OTLP/HTTP trace collector")
+	flags.StringVarP(&config.Auth.KeyStore, "auth", "", config.Auth.KeyStore, "Store of the API keys that requests must present: file or duckdb (disabled if empty)")
+	flags.StringVarP(&config.Auth.KeysFile, "auth-keys", "", config.Auth.KeysFile, "YAML file of the API keys, with --auth file")
+	flags.StringVarP(&cmdFlags.NewAPIKey, "new-api-key", "", "", "Create an API key of this ID in the --auth store, print its token, and exit")
+	flags.StringSliceVarP(&cmdFlags.NewAPIKeyScopes, "new-api-key-scopes", "", []string{middleware.ScopeRead}, "Scopes of the --new-api-key: read, export, subscriptions and/or admin")
+	flags.StringSliceVarP(&cmdFlags.NewAPIKeyDatasets, "new-api-key-datasets", "", []string{middleware.AllDatasets}, "Datasets of the --new-api-key, '*' for all")
+	flags.DurationVarP(&config.Retention.MaxAge, "retention", "", config.Retention.MaxAge, "Age after which trades and candles are deleted from DuckDB (0 keeps them)")
+	flags.DurationVarP(&config.Retention.Interval, "retention-interval", "", config.Retention.Interval, "Time between deletions of old trades and candles")
+	flags.DurationVarP(&config.ShutdownTimeout, "shutdown-timeout", "", config.ShutdownTimeout, "Time allowed to drain requests and persist data on shutdown")
+	flags.BoolVarP(&config.Verbose, "verbose", "v", config.Verbose, "Verbose logging")
+	flags.BoolVarP(&cmdFlags.ShowHelp, "help", "h", false, "Show help")
+	if err := flags.Parse(args); err != nil {
+		return config, cmdFlags, flags, err
+	}
+
+	// therese are pre-loaded subscription requests
+	if flags.NArg() != 0 {
+		config.LiveConfig.SubSymbols = flags.Args()
+	}
+	setNonEmpty(&config.LiveConfig.ApiKey, apiKeyArg)
+	setNonEmpty(&config.FlightSQL.Password, flightPasswordArg)
+	setNonEmpty(&config.PgWire.Password, pgPasswordArg)
+
+	if startTimeArg != "" {
+		startTime, err := iso8601.ParseString(startTimeArg)
+		if err != nil {
+			return config, cmdFlags, flags, fmt.Errorf("failed to parse --start as ISO 8601 time: %w", err)
+		}
+		config.LiveConfig.StartTime = startTime
+	}
+
+	config.LiveConfig.Verbose = config.Verbose
+	config.FlightSQL.Tables = config.Query.Tables
+	config.FlightSQL.MaxRows = config.Query.MaxRows
+	config.PgWire.Tables = config.Query.Tables
+	return config, cmdFlags, flags, nil
+}
+
+// configFileArg returns the --config argument of args, or "" if there is none.
+// It is scanned before the other flags, whose defaults come from the file.
+func configFileArg(args []string) string {
+	var configFile string
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {}
+	flags.StringVarP(&configFile, "config", "c", "", "")
+	flags.BoolP("help", "h", false, "")
+	flags.Parse(args)
+	return configFile
+}
+
+// loadConfigFile overlays the settings of a YAML config file onto config.
+// Settings missing from the file are left as they are.
+func loadConfigFile(filename string, config *ServiceConfig) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true) // reject misspelled settings
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return nil
+}
+
+// applyEnvironment overlays the settings of environment variables onto config
+func applyEnvironment(config *ServiceConfig) {
+	setNonEmpty(&config.LiveConfig.ApiKey, os.Getenv("DATABENTO_API_KEY"))
+	setNonEmpty(&config.FlightSQL.Password, os.Getenv("FLIGHT_SQL_PASSWORD"))
+	setNonEmpty(&config.PgWire.Password, os.Getenv("PGWIRE_PASSWORD"))
+	setNonEmpty(&config.Cors.AllowedOrigins, os.Getenv("ALLOWED_ORIGINS"))
+	setNonEmpty(&config.Cors.AllowedHeaders, os.Getenv("ALLOWED_HEADERS"))
+	setNonEmpty(&config.Log.Path, os.Getenv("APP_LOG_PATH"))
+	if mode := os.Getenv("GIN_MODE"); mode != "" {
+		config.Log.Release = (mode == "release")
+	}
+}
+
+// setNonEmpty sets val to s, if s is not empty
+func setNonEmpty(val *string, s string) {
+	if s != "" {
+		*val = s
+	}
+}
+
+// printConfig writes the config as YAML, with its secrets redacted
+func printConfig(w io.Writer, config ServiceConfig) error {
+	for _, secret := range []*string{&config.LiveConfig.ApiKey, &config.FlightSQL.Password, &config.PgWire.Password} {
+		if *secret != "" {
+			*secret = redactedSecret
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -26,9 +26,15 @@ const docTemplate = `{
     "paths": {
         "/candles/{dataset}/{ticker}": {
             "get": {
-                "description": "Returns a time range of OHLCV for a Dataset and Ticker",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a time range of OHLCV for a Dataset and Ticker.\nRequests with 'Accept: application/vnd.apache.arrow.stream' get an Arrow IPC stream.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/vnd.apache.arrow.stream"
                 ],
                 "summary": "Get a time range of OHLCV for a Dataset and Ticker",
                 "operationId": "GetOhlcvByDatasetAndTicker",
@@ -78,6 +84,10 @@ const docTemplate = `{
                         "description": "dataset not found",
                         "schema": {}
                     },
+                    "406": {
+                        "description": "Arrow not supported by this build",
+                        "schema": {}
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {}
@@ -87,6 +97,11 @@ const docTemplate = `{
         },
         "/charts/candles/{dataset}/{ticker}": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "description": "Returns an HTML page candlestick chart with volume and EMA for the given dataset and ticker.",
                 "produces": [
                     "text/html"
@@ -123,6 +138,25 @@ const docTemplate = `{
                         "description": "(optional) end of date range in ISO8601. Default is now.",
                         "name": "end",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "(optional) live-update the chart from the stream - default is true unless 'end' is given",
+                        "name": "live",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "(optional) inline the chart's JavaScript, returning a single-file offline page as an attachment. Implies live=false.",
+                        "name": "inline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "ema:20,bb:20:2,rsi:14",
+                        "description": "(optional) comma-separated indicators to draw instead of the VWAP lines: sma:N, ema:N, bb:N:K, rsi:N, macd:FAST:SLOW:SIGNAL, vwap:session",
+                        "name": "indicators",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -132,6 +166,10 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
                     "404": {
                         "description": "dataset not found",
                         "schema": {}
@@ -143,14 +181,99 @@ const docTemplate = `{
                 }
             }
         },
-        "/last-trades/csv/{dataset}/{ticker}": {
+        "/charts/compare/{dataset}": {
             "get": {
-                "description": "Returns Excel file with last N trades by dataset and ticker.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns an HTML page plotting each symbol's percent change from its first close in the range, on a shared time axis.\nWith 'ratio', a second pane plots the relative strength of one symbol to another as the ratio of their closes.",
                 "produces": [
-                    "text/csv"
+                    "text/html"
                 ],
-                "summary": "GET last N trades by market and ticker",
-                "operationId": "GetLastTradesByDatasetAndTickerCSV",
+                "summary": "Returns an HTML page comparing the percent change of several tickers of a dataset.",
+                "operationId": "GetCompareChartByDataset",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "DBEQ.BASIC",
+                        "description": "DataBento dataset",
+                        "name": "dataset",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "SPY,QQQ,IWM",
+                        "description": "comma-separated symbols to compare",
+                        "name": "symbols",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "QQQ,SPY",
+                        "description": "(optional) two comma-separated symbols, plotting the ratio of the first's close to the second's",
+                        "name": "ratio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "(optional) inline the chart's JavaScript, returning a single-file offline page as an attachment",
+                        "name": "inline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "ISO8601",
+                        "description": "(optional) start of date range in ISO8601. Default is midnight Eastern.",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "ISO8601",
+                        "description": "(optional) end of date range in ISO8601. Default is now.",
+                        "name": "end",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "HTML page with comparison chart",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "dataset not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/charts/tape/{dataset}/{ticker}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns an HTML page scatter chart of individual trades, each sized by its shares.\nAt most the 5000 most recent trades of the range are plotted.",
+                "produces": [
+                    "text/html"
+                ],
+                "summary": "Returns an HTML page time-and-sales chart of trades for the given dataset and ticker.",
+                "operationId": "GetTapeChartByDatasetAndTicker",
                 "parameters": [
                     {
                         "type": "string",
@@ -169,19 +292,37 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "(optional) number of trades to return - default is 25",
-                        "name": "count",
+                        "type": "boolean",
+                        "description": "(optional) inline the chart's JavaScript, returning a single-file offline page as an attachment",
+                        "name": "inline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "ISO8601",
+                        "description": "(optional) start of date range in ISO8601. Default is midnight Eastern.",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "ISO8601",
+                        "description": "(optional) end of date range in ISO8601. Default is now.",
+                        "name": "end",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "CSV file with last N trades",
+                        "description": "HTML page with trade tape chart",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
                     "404": {
                         "description": "dataset not found",
                         "schema": {}
@@ -193,14 +334,19 @@ const docTemplate = `{
                 }
             }
         },
-        "/last-trades/excel/{dataset}/{ticker}": {
+        "/charts/volume-profile/{dataset}/{ticker}": {
             "get": {
-                "description": "Returns Excel file with last N trades by dataset and ticker.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns an HTML page histogram of the shares traded at each price in a session,\nhighlighting the point of control (the price with the most volume) and the value area around it.",
                 "produces": [
-                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                    "text/html"
                 ],
-                "summary": "GET last N trades by market and ticker",
-                "operationId": "GetLastTradesByDatasetAndTickerExcel",
+                "summary": "Returns an HTML page volume profile chart of a session for the given dataset and ticker.",
+                "operationId": "GetVolumeProfileChartByDatasetAndTicker",
                 "parameters": [
                     {
                         "type": "string",
@@ -219,19 +365,44 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "(optional) number of trades to return - default is 25",
-                        "name": "count",
+                        "type": "boolean",
+                        "description": "(optional) inline the chart's JavaScript, returning a single-file offline page as an attachment",
+                        "name": "inline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-04-20",
+                        "description": "(optional) session date as YYYY-MM-DD, from midnight to midnight Eastern. Default is today in Eastern.",
+                        "name": "date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "example": 0.05,
+                        "description": "(optional) price bucket size. Default divides the session's range into 50 levels.",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "example": 70,
+                        "description": "(optional) percent of volume in the value area. Default is 70.",
+                        "name": "va",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Excel file with last N trades",
+                        "description": "HTML page with volume profile chart",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
                     "404": {
                         "description": "dataset not found",
                         "schema": {}
@@ -243,14 +414,22 @@ const docTemplate = `{
                 }
             }
         },
-        "/last-trades/json/{dataset}/{ticker}": {
+        "/export/{dataset}/{ticker}": {
             "get": {
-                "description": "Returns the last N trades by dataset and ticker.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a file of trades or candles for a Dataset, Ticker and time range, as an attachment.\nAt most 1,000,000 rows are exported; the X-Row-Count header has the number of rows in the file.",
                 "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                    "application/vnd.apache.parquet",
                     "application/json"
                 ],
-                "summary": "GET last N trades by market and ticker",
-                "operationId": "GetLastTradesByDatasetAndTicker",
+                "summary": "Export trades or candles for a Dataset and Ticker",
+                "operationId": "GetExportByDatasetAndTicker",
                 "parameters": [
                     {
                         "type": "string",
@@ -268,23 +447,142 @@ const docTemplate = `{
                         "in": "path",
                         "required": true
                     },
+                    {
+                        "enum": [
+                            "trades",
+                            "candles"
+                        ],
+                        "type": "string",
+                        "description": "(optional) table to export - default is candles",
+                        "name": "table",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "xlsx",
+                            "parquet",
+                            "json"
+                        ],
+                        "type": "string",
+                        "description": "(optional) file format - default is csv",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "ISO8601",
+                        "description": "(optional) start of date range in ISO8601. Default is midnight Eastern.",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "ISO8601",
+                        "description": "(optional) end of date range in ISO8601. Default is now.",
+                        "name": "end",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "(optional) number of trades to return - default is 25",
-                        "name": "count",
+                        "description": "(optional) maximum rows to export - default and maximum is 1000000",
+                        "name": "limit",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "array of TradeTicks",
+                        "description": "file of trades or candles",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "dataset not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    },
+                    "503": {
+                        "description": "too many concurrent exports",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/indicators/{dataset}/{ticker}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns technical indicators computed over the candles of a Dataset and Ticker.\nIndicators are comma-separated: sma:N, ema:N, bb:N:K, rsi:N, macd:FAST:SLOW:SIGNAL, vwap:session",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get technical indicators for a Dataset and Ticker",
+                "operationId": "GetIndicatorsByDatasetAndTicker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "DBEQ.BASIC",
+                        "description": "DataBento dataset",
+                        "name": "dataset",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "AAPL",
+                        "description": "symbol to query",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "ema:20,bb:20:2,rsi:14",
+                        "description": "comma-separated indicators",
+                        "name": "indicators",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "format": "ISO8601",
+                        "description": "(optional) start of date range in ISO8601. Default is midnight Eastern.",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "ISO8601",
+                        "description": "(optional) end of date range in ISO8601. Default is now.",
+                        "name": "end",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "array of IndicatorSeries",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/sdk.TradeTick"
+                                "$ref": "#/definitions/sdk.IndicatorSeries"
                             }
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
                     "404": {
                         "description": "dataset not found",
                         "schema": {}
@@ -295,28 +593,452 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "sdk.Candle": {
-            "type": "object",
-            "properties": {
-                "close": {
-                    "description": "Close price of candlestick",
-                    "type": "number",
-                    "example": 214.21
-                },
-                "high": {
-                    "description": "High price of candlestick",
-                    "type": "number",
-                    "example": 214.21
-                },
-                "low": {
-                    "description": "Low price of candlestick",
-                    "type": "number",
-                    "example": 214.21
-                },
-                "ns": {
+        },
+        "/last-trades/csv/{dataset}/{ticker}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns Excel file with last N trades by dataset and ticker.",
+                "produces": [
+                    "text/csv"
+                ],
+                "summary": "GET last N trades by market and ticker",
+                "operationId": "GetLastTradesByDatasetAndTickerCSV",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "DBEQ.BASIC",
+                        "description": "DataBento dataset",
+                        "name": "dataset",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "AAPL",
+                        "description": "symbol to query",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "(optional) number of trades to return - default is 25",
+                        "name": "count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "CSV file with last N trades",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "dataset not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/last-trades/excel/{dataset}/{ticker}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns Excel file with last N trades by dataset and ticker.",
+                "produces": [
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "summary": "GET last N trades by market and ticker",
+                "operationId": "GetLastTradesByDatasetAndTickerExcel",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "DBEQ.BASIC",
+                        "description": "DataBento dataset",
+                        "name": "dataset",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "AAPL",
+                        "description": "symbol to query",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "(optional) number of trades to return - default is 25",
+                        "name": "count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Excel file with last N trades",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "dataset not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/last-trades/json/{dataset}/{ticker}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the last N trades by dataset and ticker.\nRequests with 'Accept: application/vnd.apache.arrow.stream' get an Arrow IPC stream.",
+                "produces": [
+                    "application/json",
+                    "application/vnd.apache.arrow.stream"
+                ],
+                "summary": "GET last N trades by market and ticker",
+                "operationId": "GetLastTradesByDatasetAndTicker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "DBEQ.BASIC",
+                        "description": "DataBento dataset",
+                        "name": "dataset",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "AAPL",
+                        "description": "symbol to query",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "(optional) number of trades to return - default is 25",
+                        "name": "count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "array of TradeTicks",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/sdk.TradeTick"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "dataset not found",
+                        "schema": {}
+                    },
+                    "406": {
+                        "description": "Arrow not supported by this build",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/query": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Runs a single SELECT statement of the allowed tables in a read-only transaction, returning its result as JSON, CSV or an Arrow IPC stream.\nThe format is the 'format' parameter, otherwise negotiated with the Accept header.\nQueries are cancelled at the server's time limit, and at most the server's row limit is returned; the X-Truncated header is true if rows were dropped.\nResults are cached briefly; the X-Cache header is HIT if the result was cached.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/vnd.apache.arrow.stream"
+                ],
+                "summary": "Run an ad-hoc SQL query",
+                "operationId": "PostQuery",
+                "parameters": [
+                    {
+                        "description": "SQL query",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/sdk.QueryRequest"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "csv",
+                            "arrow"
+                        ],
+                        "type": "string",
+                        "description": "(optional) result format - default is negotiated, otherwise json",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/sdk.QueryResult"
+                        }
+                    },
+                    "400": {
+                        "description": "query not allowed or invalid",
+                        "schema": {}
+                    },
+                    "403": {
+                        "description": "API key may not access every dataset",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    },
+                    "504": {
+                        "description": "query timed out",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/status": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the server's build, the subscriptions of each live dataset with the time of its latest event and its lag from the server's clock, the row count of each table, and the size of the database file.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "GET server status",
+                "operationId": "GetStatus",
+                "responses": {
+                    "200": {
+                        "description": "Status of the server",
+                        "schema": {
+                            "$ref": "#/definitions/sdk.Status"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    },
+                    "504": {
+                        "description": "database did not answer in time",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/stream/{dataset}/{ticker}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Streams live trades and candles for a Dataset and Ticker as Server-Sent Events.\nEach event's type is its schema and its data is an sdk.TradeTick or sdk.Candle as JSON.\nThe event ID is a cursor of the last trades and candle ts_event nanoseconds, as '\u003ctrades\u003e-\u003cohlcv-1m\u003e'.\nEvents sharing a ts_event are numbered from 0 in ingestion order, which follows a ts_event as '.\u003cn\u003e' if not 0.\nClients reconnecting with a Last-Event-ID header first receive all the events they missed from DuckDB, in order.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream trades and candles for a Dataset and Ticker as Server-Sent Events",
+                "operationId": "GetStreamByDatasetAndTicker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "DBEQ.BASIC",
+                        "description": "DataBento dataset",
+                        "name": "dataset",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "AAPL",
+                        "description": "symbol to stream",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "(optional) comma-separated schemas to stream - default is 'trades,ohlcv-1m'",
+                        "name": "schemas",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "(optional) ID of the last event received; missed events are replayed",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "(optional) same as the Last-Event-ID header, which takes precedence",
+                        "name": "lastEventId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "stream of Server-Sent Events",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "dataset not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the datasets the server ingests, with their schemas and subscribed symbols, sorted by dataset.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "GET live subscriptions",
+                "operationId": "GetSubscriptions",
+                "responses": {
+                    "200": {
+                        "description": "array of Subscriptions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/sdk.Subscription"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{dataset}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the symbols the dataset's live session ingests, without restarting it, and returns its new subscription.\nNew symbols are subscribed from now.  Databento has no unsubscribe, so removed symbols are still received, but are no longer ingested or streamed.\nThe change lasts until the server restarts, or a config reload changes the configured symbols.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "PUT live subscriptions",
+                "operationId": "PutSubscriptionsByDataset",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "DBEQ.BASIC",
+                        "description": "DataBento dataset",
+                        "name": "dataset",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Symbols to subscribe",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/sdk.SubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/sdk.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "404": {
+                        "description": "dataset not found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "sdk.Candle": {
+            "type": "object",
+            "properties": {
+                "close": {
+                    "description": "Close price of candlestick",
+                    "type": "number",
+                    "example": 214.21
+                },
+                "high": {
+                    "description": "High price of candlestick",
+                    "type": "number",
+                    "example": 214.21
+                },
+                "low": {
+                    "description": "Low price of candlestick",
+                    "type": "number",
+                    "example": 214.21
+                },
+                "ns": {
                     "description": "Nanoseconds portion of the event timestamp",
                     "type": "integer",
                     "example": 123456
@@ -348,6 +1070,266 @@ const docTemplate = `{
                 }
             }
         },
+        "sdk.DatasetStatus": {
+            "type": "object",
+            "properties": {
+                "connected": {
+                    "description": "True while the live session is followed",
+                    "type": "boolean",
+                    "example": true
+                },
+                "dataset": {
+                    "description": "Databento dataset",
+                    "type": "string",
+                    "example": "DBEQ.BASIC"
+                },
+                "lag_seconds": {
+                    "description": "Seconds from the latest ts_event to the server's clock; 0 if none",
+                    "type": "number",
+                    "example": 0.25
+                },
+                "last_event_ts": {
+                    "description": "Latest ts_event of the trades and candles ingested, as nanoseconds from the epoch; 0 if none",
+                    "type": "integer",
+                    "example": 1713644400123456789
+                },
+                "last_record_ts": {
+                    "description": "Time the last record of any kind was received, as seconds from the epoch; 0 if none",
+                    "type": "integer",
+                    "example": 1713644401
+                },
+                "schemas": {
+                    "description": "DBN schemas ingested",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "trades",
+                        "ohlcv-1m"
+                    ]
+                },
+                "symbols": {
+                    "description": "Subscribed symbols, sorted",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "AAPL",
+                        "SPY"
+                    ]
+                }
+            }
+        },
+        "sdk.IndicatorPoint": {
+            "type": "object",
+            "properties": {
+                "ts": {
+                    "description": "Candle timestamp as seconds from the epoch",
+                    "type": "integer",
+                    "example": 1713644400
+                },
+                "value": {
+                    "description": "Indicator value",
+                    "type": "number",
+                    "example": 214.21
+                }
+            }
+        },
+        "sdk.IndicatorSeries": {
+            "type": "object",
+            "properties": {
+                "indicator": {
+                    "description": "Indicator specification that produced the line",
+                    "type": "string",
+                    "example": "bb:20:2"
+                },
+                "line": {
+                    "description": "Line of a multi-line indicator, e.g. \"upper\" or \"signal\"",
+                    "type": "string",
+                    "example": "upper"
+                },
+                "name": {
+                    "description": "Field name of the line, unique per request",
+                    "type": "string",
+                    "example": "bb_20_2_upper"
+                },
+                "pane": {
+                    "description": "\"overlay\" to draw over prices, otherwise the name of its own pane",
+                    "type": "string",
+                    "example": "overlay"
+                },
+                "points": {
+                    "description": "Values, omitting the warm-up period",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sdk.IndicatorPoint"
+                    }
+                }
+            }
+        },
+        "sdk.QueryColumn": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Column name",
+                    "type": "string",
+                    "example": "ticker"
+                },
+                "type": {
+                    "description": "DuckDB type of the column",
+                    "type": "string",
+                    "example": "VARCHAR"
+                }
+            }
+        },
+        "sdk.QueryRequest": {
+            "type": "object",
+            "properties": {
+                "max_rows": {
+                    "description": "Maximum rows to return, at most the server's limit",
+                    "type": "integer",
+                    "example": 1000
+                },
+                "sql": {
+                    "description": "Single SELECT statement of the allowed tables",
+                    "type": "string",
+                    "example": "SELECT ticker, count(*) AS trades FROM trades GROUP BY ticker"
+                }
+            }
+        },
+        "sdk.QueryResult": {
+            "type": "object",
+            "properties": {
+                "cached": {
+                    "description": "True if the result was served from the cache",
+                    "type": "boolean",
+                    "example": false
+                },
+                "columns": {
+                    "description": "Result columns",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sdk.QueryColumn"
+                    }
+                },
+                "row_count": {
+                    "description": "Number of rows",
+                    "type": "integer",
+                    "example": 1
+                },
+                "rows": {
+                    "description": "Result rows, each with a value per column",
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                },
+                "truncated": {
+                    "description": "True if rows beyond the row limit were dropped",
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "sdk.Status": {
+            "type": "object",
+            "properties": {
+                "datasets": {
+                    "description": "Live datasets, sorted by dataset",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sdk.DatasetStatus"
+                    }
+                },
+                "db_file": {
+                    "description": "DuckDB database file, empty if in-memory",
+                    "type": "string",
+                    "example": "goose.duckdb"
+                },
+                "db_size": {
+                    "description": "Bytes of the database file and its write-ahead log, 0 if in-memory",
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "tables": {
+                    "description": "Tables of the database, sorted by name",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sdk.TableStatus"
+                    }
+                },
+                "version": {
+                    "description": "VCS type and revision of the server's build",
+                    "type": "string",
+                    "example": "git:0123abcd"
+                }
+            }
+        },
+        "sdk.Subscription": {
+            "type": "object",
+            "properties": {
+                "dataset": {
+                    "description": "Databento dataset",
+                    "type": "string",
+                    "example": "DBEQ.BASIC"
+                },
+                "schemas": {
+                    "description": "DBN schemas ingested",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "trades",
+                        "ohlcv-1m"
+                    ]
+                },
+                "symbols": {
+                    "description": "Subscribed symbols, sorted",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "AAPL",
+                        "SPY"
+                    ]
+                }
+            }
+        },
+        "sdk.SubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "symbols": {
+                    "description": "Symbols to subscribe",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "AAPL",
+                        "SPY"
+                    ]
+                }
+            }
+        },
+        "sdk.TableStatus": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Table name",
+                    "type": "string",
+                    "example": "trades"
+                },
+                "row_count": {
+                    "description": "Number of rows",
+                    "type": "integer",
+                    "example": 123456
+                }
+            }
+        },
         "sdk.TradeTick": {
             "type": "object",
             "properties": {
@@ -388,6 +1370,14 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key token '\u003cid\u003e.\u003csecret\u003e', if the server enables authentication",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        }
     }
 }`
 
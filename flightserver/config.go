@@ -0,0 +1,17 @@
+// Copyright (c) 2025 Neomantra Corp
+
+// Package flightserver serves the DuckDB store read-only over Arrow Flight SQL,
+// for ADBC and Flight SQL drivers.  It requires the duckdb_arrow build tag.
+package flightserver
+
+import "time"
+
+// Config is the configuration of a Flight SQL server
+type Config struct {
+	HostPort     string        `yaml:"hostport"` // 'host:port' to listen on
+	Username     string        `yaml:"user"`     // Username clients authenticate with
+	Password     string        `yaml:"password"` // Password clients authenticate with
+	QueryTimeout time.Duration `yaml:"timeout"`  // Maximum time to execute a query
+	Tables       []string      `yaml:"-"`        // Tables open to queries (default: middleware.DefaultQueryTables)
+	MaxRows      int           `yaml:"-"`        // Maximum rows a query returns
+}
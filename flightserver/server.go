@@ -0,0 +1,373 @@
+// Copyright (c) 2025 Neomantra Corp
+
+//go:build duckdb_arrow
+
+package flightserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql/schema_ref"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/marcboeker/go-duckdb/v2"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+)
+
+const (
+	tableType   = "BASE TABLE"             // Flight SQL table type of our tables, as DuckDB names it
+	resultTable = "temp.flight_sql_result" // connection's temporary table of a query's result
+)
+
+// Server is a read-only Arrow Flight SQL server over the DuckDB store
+type Server struct {
+	flightsql.BaseServer
+	config     Config
+	duckdbConn *sql.DB
+	guard      *middleware.SQLGuard
+	catalog    string // DuckDB catalog of the tables
+	token      string // bearer token issued on a successful handshake
+	logger     *zap.Logger
+	server     flight.Server
+}
+
+// NewServer creates a Flight SQL server querying duckdbConn.
+// Returns nil and an error, if any.
+func NewServer(config Config, duckdbConn *sql.DB, logger *zap.Logger) (*Server, error) {
+	if config.Password == "" {
+		return nil, fmt.Errorf("a password is required")
+	}
+	if config.QueryTimeout <= 0 {
+		return nil, fmt.Errorf("query timeout must be greater than 0")
+	}
+	if config.MaxRows <= 0 {
+		return nil, fmt.Errorf("max rows must be greater than 0")
+	}
+	tables := config.Tables
+	if len(tables) == 0 {
+		tables = middleware.DefaultQueryTables
+	}
+
+	var catalog string
+	if err := duckdbConn.QueryRow("SELECT current_database();").Scan(&catalog); err != nil {
+		return nil, fmt.Errorf("failed to query catalog: %w", err)
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	s := &Server{
+		config:     config,
+		duckdbConn: duckdbConn,
+		guard:      middleware.NewSQLGuard(duckdbConn, tables),
+		catalog:    catalog,
+		token:      hex.EncodeToString(tokenBytes),
+		logger:     logger,
+	}
+	s.Alloc = memory.DefaultAllocator
+	s.RegisterSqlInfo(flightsql.SqlInfoFlightSqlServerName, "dbn-duckduck-goose")
+	s.RegisterSqlInfo(flightsql.SqlInfoFlightSqlServerVersion, "1.0")
+	s.RegisterSqlInfo(flightsql.SqlInfoFlightSqlServerArrowVersion, "18")
+	s.RegisterSqlInfo(flightsql.SqlInfoFlightSqlServerReadOnly, true)
+
+	s.server = flight.NewServerWithMiddleware([]flight.ServerMiddleware{
+		flight.CreateServerBasicAuthMiddleware(s),
+	})
+	s.server.RegisterFlightService(flightsql.NewFlightServer(s))
+	if err := s.server.Init(config.HostPort); err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	return s, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.server.Addr().String()
+}
+
+// Serve serves Flight SQL until Shutdown.  Returns an error, if any.
+func (s *Server) Serve() error {
+	return s.server.Serve()
+}
+
+// Shutdown stops the server, waiting for in-flight requests
+func (s *Server) Shutdown() {
+	s.server.Shutdown()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Authentication, as flight.BasicAuthValidator
+
+// Validate checks a handshake's credentials, returning the bearer token for later requests
+func (s *Server) Validate(username, password string) (string, error) {
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(s.config.Username)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.config.Password)) == 1
+	if !userOK || !passwordOK {
+		return "", status.Error(codes.Unauthenticated, "invalid username or password")
+	}
+	return s.token, nil
+}
+
+// IsValid checks a request's bearer token, returning the identity of its user
+func (s *Server) IsValid(bearerToken string) (any, error) {
+	if subtle.ConstantTimeCompare([]byte(bearerToken), []byte(s.token)) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	return s.config.Username, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Statements
+
+// GetFlightInfoStatement checks the query and returns a ticket to execute it
+func (s *Server) GetFlightInfoStatement(ctx context.Context, cmd flightsql.StatementQuery, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	if len(cmd.GetTransactionId()) != 0 {
+		return nil, status.Error(codes.InvalidArgument, "transactions are not supported")
+	}
+	query := cmd.GetQuery()
+	if err := s.checkQuery(ctx, query); err != nil {
+		return nil, err
+	}
+	ticket, err := flightsql.CreateStatementQueryTicket([]byte(query))
+	if err != nil {
+		return nil, err
+	}
+	return &flight.FlightInfo{
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: ticket}}},
+		FlightDescriptor: desc,
+		TotalRecords:     -1,
+		TotalBytes:       -1,
+	}, nil
+}
+
+// DoGetStatement executes a ticket's query, streaming its results
+func (s *Server) DoGetStatement(ctx context.Context, ticket flightsql.StatementQueryTicket) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	// tickets come from the client, so are checked again
+	query := string(ticket.GetStatementHandle())
+	if err := s.checkQuery(ctx, query); err != nil {
+		return nil, nil, err
+	}
+
+	reader, truncated, err := s.queryArrow(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.logger.Info("Flight SQL query", zap.Any("user", flight.AuthFromContext(ctx)), zap.String("query", query),
+		zap.Bool("truncated", truncated))
+	ch := make(chan flight.StreamChunk, 2)
+	go flight.StreamChunksFromReader(reader, ch)
+	return reader.Schema(), ch, nil
+}
+
+// checkQuery returns a gRPC error if the query is not allowed
+func (s *Server) checkQuery(ctx context.Context, query string) error {
+	if err := s.guard.Check(ctx, query); err != nil {
+		if errors.Is(err, middleware.ErrQueryNotAllowed) {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// queryArrow executes the query in a read-only transaction on a connection of the pool,
+// within the query timeout, returning at most the configured maximum rows.
+// Returns the query's records, whether rows were dropped, and an error, if any.
+func (s *Server) queryArrow(ctx context.Context, query string) (array.RecordReader, bool, error) {
+	conn, err := middleware.BeginReadOnly(ctx, s.duckdbConn)
+	if err != nil {
+		return nil, false, status.Error(codes.Unavailable, err.Error())
+	}
+	defer middleware.EndReadOnly(conn)
+
+	// DuckDB's Arrow interface cannot be interrupted, so the query runs into a
+	// temporary table through the interruptible interface first.  One row past
+	// the limit is kept, to tell if the result was truncated.  The read-only
+	// transaction still fails writes to the database, but not to the temporary
+	// table, which is private to the connection and dropped by the rollback.
+	timeoutCtx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	_, err = conn.ExecContext(timeoutCtx, fmt.Sprintf("CREATE OR REPLACE TEMP TABLE %s AS SELECT * FROM (\n%s\n) LIMIT %d",
+		resultTable, query, s.config.MaxRows+1))
+	if err != nil {
+		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return nil, false, status.Errorf(codes.DeadlineExceeded, "query exceeded timeout of %s", s.config.QueryTimeout)
+		}
+		return nil, false, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var rowCount int
+	if err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", resultTable)).Scan(&rowCount); err != nil {
+		return nil, false, status.Error(codes.Internal, err.Error())
+	}
+	truncated := rowCount > s.config.MaxRows
+
+	// DuckDB materializes the result as record batches, so the connection is released before streaming
+	var reader array.RecordReader
+	err = conn.Raw(func(driverConn any) error {
+		arrowConn, err := duckdb.NewArrowFromConn(driverConn.(driver.Conn))
+		if err != nil {
+			return err
+		}
+		reader, err = arrowConn.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT %d;", resultTable, s.config.MaxRows))
+		return err
+	})
+	if err != nil {
+		return nil, false, status.Error(codes.Internal, err.Error())
+	}
+	return reader, truncated, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Catalog metadata, limited to the allowed tables
+
+// GetFlightInfoTables returns a ticket to list the tables
+func (s *Server) GetFlightInfoTables(_ context.Context, cmd flightsql.GetTables, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	schema := schema_ref.Tables
+	if cmd.GetIncludeSchema() {
+		schema = schema_ref.TablesWithIncludedSchema
+	}
+	return s.flightInfoForCommand(desc, schema), nil
+}
+
+// DoGetTables lists the allowed tables matching the command's filters
+func (s *Server) DoGetTables(ctx context.Context, cmd flightsql.GetTables) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	schema := schema_ref.Tables
+	if cmd.GetIncludeSchema() {
+		schema = schema_ref.TablesWithIncludedSchema
+	}
+	builder := array.NewRecordBuilder(s.Alloc, schema)
+	defer builder.Release()
+
+	if s.matchesTableFilters(cmd) {
+		tableNamePat := likePatternRegexp(cmd.GetTableNameFilterPattern())
+		for _, table := range s.guard.Tables() {
+			if tableNamePat != nil && !tableNamePat.MatchString(table) {
+				continue
+			}
+			builder.Field(0).(*array.StringBuilder).Append(s.catalog)
+			builder.Field(1).(*array.StringBuilder).Append("main")
+			builder.Field(2).(*array.StringBuilder).Append(table)
+			builder.Field(3).(*array.StringBuilder).Append(tableType)
+			if cmd.GetIncludeSchema() {
+				tableSchema, err := s.tableSchema(ctx, table)
+				if err != nil {
+					return nil, nil, err
+				}
+				builder.Field(4).(*array.BinaryBuilder).Append(flight.SerializeSchema(tableSchema, s.Alloc))
+			}
+		}
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+	reader, err := array.NewRecordReader(schema, []arrow.Record{record})
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := make(chan flight.StreamChunk, 1)
+	go flight.StreamChunksFromReader(reader, ch)
+	return schema, ch, nil
+}
+
+// GetFlightInfoTableTypes returns a ticket to list the table types
+func (s *Server) GetFlightInfoTableTypes(_ context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	return s.flightInfoForCommand(desc, schema_ref.TableTypes), nil
+}
+
+// DoGetTableTypes lists our only table type
+func (s *Server) DoGetTableTypes(context.Context) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	builder := array.NewRecordBuilder(s.Alloc, schema_ref.TableTypes)
+	defer builder.Release()
+	builder.Field(0).(*array.StringBuilder).Append(tableType)
+
+	record := builder.NewRecord()
+	defer record.Release()
+	reader, err := array.NewRecordReader(schema_ref.TableTypes, []arrow.Record{record})
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := make(chan flight.StreamChunk, 1)
+	go flight.StreamChunksFromReader(reader, ch)
+	return schema_ref.TableTypes, ch, nil
+}
+
+// flightInfoForCommand returns FlightInfo whose ticket is the command itself
+func (s *Server) flightInfoForCommand(desc *flight.FlightDescriptor, schema *arrow.Schema) *flight.FlightInfo {
+	return &flight.FlightInfo{
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: desc.Cmd}}},
+		FlightDescriptor: desc,
+		Schema:           flight.SerializeSchema(schema, s.Alloc),
+		TotalRecords:     -1,
+		TotalBytes:       -1,
+	}
+}
+
+// matchesTableFilters returns true if our catalog, schema and table type pass the command's filters
+func (s *Server) matchesTableFilters(cmd flightsql.GetTables) bool {
+	if catalog := cmd.GetCatalog(); catalog != nil && *catalog != s.catalog {
+		return false
+	}
+	if schemaPat := likePatternRegexp(cmd.GetDBSchemaFilterPattern()); schemaPat != nil && !schemaPat.MatchString("main") {
+		return false
+	}
+	if tableTypes := cmd.GetTableTypes(); len(tableTypes) != 0 {
+		for _, t := range tableTypes {
+			if strings.EqualFold(t, tableType) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// tableSchema returns the Arrow schema of an allowed table
+func (s *Server) tableSchema(ctx context.Context, table string) (*arrow.Schema, error) {
+	reader, _, err := s.queryArrow(ctx, fmt.Sprintf(`SELECT * FROM "%s" LIMIT 0;`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+	return reader.Schema(), nil
+}
+
+// likePatternRegexp converts a Flight SQL filter pattern, in SQL LIKE syntax, to a regexp.
+// Returns nil if there is no pattern.
+func likePatternRegexp(pattern *string) *regexp.Regexp {
+	if pattern == nil {
+		return nil
+	}
+	var expr strings.Builder
+	expr.WriteString("(?i)^")
+	for _, r := range *pattern {
+		switch r {
+		case '%':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2025 Neomantra Corp
+
+//go:build !duckdb_arrow
+
+package flightserver
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Server is a read-only Arrow Flight SQL server over the DuckDB store.
+// This build lacks the duckdb_arrow tag, so it cannot be created.
+type Server struct{}
+
+// NewServer returns an error, as this build lacks the duckdb_arrow tag
+func NewServer(config Config, duckdbConn *sql.DB, logger *zap.Logger) (*Server, error) {
+	return nil, fmt.Errorf("Flight SQL is not supported by this build, rebuild with '-tags duckdb_arrow'")
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string { return "" }
+
+// Serve serves Flight SQL until Shutdown.  Returns an error, if any.
+func (s *Server) Serve() error { return nil }
+
+// Shutdown stops the server, waiting for in-flight requests
+func (s *Server) Shutdown() {}
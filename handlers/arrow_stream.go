@@ -0,0 +1,42 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// arrowStreamMIME is the media type of an Arrow IPC stream
+const arrowStreamMIME = "application/vnd.apache.arrow.stream"
+
+// errArrowUnsupported is returned by writeArrowStream when built without the duckdb_arrow tag
+var errArrowUnsupported = errors.New("Arrow IPC responses are not supported by this build, rebuild with '-tags duckdb_arrow'")
+
+// acceptsArrowStream returns true if the request's Accept header prefers an Arrow IPC stream over JSON
+func acceptsArrowStream(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, arrowStreamMIME) == arrowStreamMIME
+}
+
+// respondArrowStream responds to the request with the query's results as an Arrow IPC stream.
+// Responds with http.StatusNotAcceptable if Arrow is not supported by this build,
+// or an internal error with errorMsg if the query fails before anything was transmitted.
+func respondArrowStream(c *gin.Context, errorMsg string, queryStr string, args ...any) {
+	err := writeArrowStream(c, queryStr, args...)
+	switch {
+	case err == nil:
+	case errors.Is(err, errArrowUnsupported):
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"message": err.Error()})
+	case !c.Writer.Written():
+		middleware.InternalError(c, errorMsg, err)
+	default:
+		// the stream is already underway, so the client sees a truncated stream
+		c.Error(fmt.Errorf("%s: %w", errorMsg, err))
+		c.Abort()
+	}
+}
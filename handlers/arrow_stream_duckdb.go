@@ -0,0 +1,62 @@
+// Copyright (c) 2025 Neomantra Corp
+
+//go:build duckdb_arrow
+
+package handlers
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/gin-gonic/gin"
+	"github.com/marcboeker/go-duckdb/v2"
+)
+
+// writeArrowStream executes the query on the global DuckDB connection and transmits
+// its record batches as an Arrow IPC stream, without scanning the rows.
+// Nothing has been transmitted if the query itself fails.  Returns an error, if any.
+func writeArrowStream(c *gin.Context, queryStr string, args ...any) (err error) {
+	var rowCount int64
+	ctx, span := middleware.StartQuerySpan(c.Request.Context(), "query arrow stream", queryStr)
+	defer func() { middleware.EndQuerySpan(span, int(rowCount), err) }()
+	conn, err := gDuckdbConn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	// DuckDB materializes the result as record batches, so the connection is released before transmitting
+	var reader array.RecordReader
+	err = conn.Raw(func(driverConn any) error {
+		arrowConn, err := duckdb.NewArrowFromConn(driverConn.(driver.Conn))
+		if err != nil {
+			return err
+		}
+		reader, err = arrowConn.QueryContext(ctx, queryStr, args...)
+		return err
+	})
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("DuckDB Arrow query failed: %w", err)
+	}
+	defer reader.Release()
+
+	c.Header("Content-Type", arrowStreamMIME)
+	c.Status(http.StatusOK)
+	writer := ipc.NewWriter(c.Writer, ipc.WithSchema(reader.Schema()))
+	for reader.Next() {
+		rowCount += reader.Record().NumRows()
+		if err := writer.Write(reader.Record()); err != nil {
+			writer.Close()
+			return err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
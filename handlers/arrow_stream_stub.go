@@ -0,0 +1,12 @@
+// Copyright (c) 2025 Neomantra Corp
+
+//go:build !duckdb_arrow
+
+package handlers
+
+import "github.com/gin-gonic/gin"
+
+// writeArrowStream returns errArrowUnsupported, as this build lacks the duckdb_arrow tag
+func writeArrowStream(c *gin.Context, queryStr string, args ...any) error {
+	return errArrowUnsupported
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+const (
+	chartAssetsRoute = "/assets"        // route of the embedded chart assets
+	echartsAssetName = "echarts.min.js" // ECharts library, the only asset the charts need
+)
+
+// chartAssetsHost returns the AssetsHost for charts: the /assets route if ECharts is embedded,
+// otherwise empty for the go-echarts CDN default.
+func chartAssetsHost() string {
+	if echartsAsset() == nil {
+		return ""
+	}
+	return chartAssetsRoute + "/"
+}
+
+// chartInitializationOpts returns the opts.Initialization shared by our chart pages
+func chartInitializationOpts(pageTitle string) opts.Initialization {
+	return opts.Initialization{
+		PageTitle:  pageTitle,
+		Theme:      "dark",
+		Width:      "100%",
+		AssetsHost: chartAssetsHost(),
+	}
+}
+
+// RegisterChartAssets registers the /assets route for the embedded ECharts library
+func RegisterChartAssets(r *gin.Engine) *gin.Engine {
+	echarts := echartsAsset()
+	if echarts == nil {
+		return r
+	}
+	r.GET(chartAssetsRoute+"/"+echartsAssetName, func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", echarts)
+	})
+	return r
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// extractParamInline extracts the 'inline' query parameter, which requests a single-file offline page
+func extractParamInline(c *gin.Context) (bool, error) {
+	inlineStr := c.Query("inline")
+	if inlineStr == "" {
+		return false, nil
+	}
+	inline, err := strconv.ParseBool(inlineStr)
+	if err != nil {
+		return false, fmt.Errorf("invalid 'inline' value: %s. %w", inlineStr, err)
+	}
+	if inline && echartsAsset() == nil {
+		return false, fmt.Errorf("'inline' requires a build with the echarts_embed tag")
+	}
+	return inline, nil
+}
+
+// unsafeFilenamePat matches runs of characters not allowed in attachment filenames
+var unsafeFilenamePat = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// attachmentFilename returns a safe attachment filename of the parts and extension,
+// e.g. "DBEQ.BASIC-SPY-candles.html"
+func attachmentFilename(ext string, parts ...string) string {
+	for i, part := range parts {
+		parts[i] = unsafeFilenamePat.ReplaceAllString(part, "_")
+	}
+	return strings.Join(parts, "-") + "." + ext
+}
+
+// inlineChartAssets replaces the rendered page's ECharts script reference with the library itself,
+// so the page works as a single file without the server.
+// Returns an error if ECharts was not embedded in this build.
+func inlineChartAssets(chartHTML []byte) ([]byte, error) {
+	echarts := echartsAsset()
+	if echarts == nil {
+		return nil, fmt.Errorf("%s is not embedded in this build, which loads it from the CDN", echartsAssetName)
+	}
+	scriptTag := []byte(fmt.Sprintf(`<script src="%s%s"></script>`, chartAssetsHost(), echartsAssetName))
+	if !bytes.Contains(chartHTML, scriptTag) {
+		return nil, fmt.Errorf("%s script not found in chart", echartsAssetName)
+	}
+	var inlined bytes.Buffer
+	inlined.Grow(len(chartHTML) + len(echarts))
+	before, after, _ := bytes.Cut(chartHTML, scriptTag)
+	inlined.Write(before)
+	inlined.WriteString("<script>")
+	inlined.Write(bytes.ReplaceAll(echarts, []byte("</script"), []byte(`<\/script`)))
+	inlined.WriteString("</script>")
+	inlined.Write(after)
+	return inlined.Bytes(), nil
+}
+
+// writeChartHTML transmits a rendered chart page.  If inline, the page is made
+// self-contained and sent as an attachment named filename.
+// Returns an error if inlining fails; nothing has been written in that case.
+func writeChartHTML(c *gin.Context, chartHTML []byte, inline bool, filename string) error {
+	if inline {
+		var err error
+		if chartHTML, err = inlineChartAssets(chartHTML); err != nil {
+			return err
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", chartHTML)
+	return nil
+}
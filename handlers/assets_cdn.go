@@ -0,0 +1,10 @@
+// Copyright (c) 2025 Neomantra Corp
+
+//go:build !echarts_embed
+
+package handlers
+
+// echartsAsset returns nil, as builds without the echarts_embed tag load ECharts from the go-echarts CDN
+func echartsAsset() []byte {
+	return nil
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2025 Neomantra Corp
+
+//go:build echarts_embed
+
+package handlers
+
+import _ "embed"
+
+// echartsLibrary is the ECharts library served at /assets, so charts work without internet access.
+// It is embedded by builds with the echarts_embed tag; run 'task fetch-assets' first.
+//
+//go:embed assets/echarts.min.js
+var echartsLibrary []byte
+
+// echartsAsset returns the embedded ECharts library
+func echartsAsset() []byte {
+	return echartsLibrary
+}
@@ -3,10 +3,15 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	_ "embed" // Required for go:embed
+	"encoding/json"
 	"fmt"
-	"os"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
@@ -32,10 +37,20 @@ var tooltipFormatter string
 //go:embed js/tooltipPositioner.js
 var tooltipPositioner string
 
+//go:embed js/liveCandleUpdater.js
+var liveCandleUpdater string
+
+// jsBlockCommentPat matches /* */ comments, which are stripped from inlined scripts
+var jsBlockCommentPat = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// liveChartRenderInterval is how often a live chart redraws while updates arrive
+const liveChartRenderInterval = 250 * time.Millisecond
+
 // TradeStat is where we store results from our VWAP trades query
 type TradeStat struct {
 	Timestamp   float64 `json:"ts" example:"1713644400"`       // Trade event timestamp as seconds from the epoch
 	VwapPrice   float64 `json:"vwap" example:"214.21"`         // VWAP price
+	Volume      float64 `json:"volume" example:"100"`          // Volume traded in the minute
 	VwapMA30min float64 `json:"vwapma_30min" example:"214.21"` // 30-minute moving average of VWAP
 	VwapMA1hour float64 `json:"vwapma_1hour" example:"214.21"` // 1-hour moving average of VWAP
 	VwapMA3hour float64 `json:"vwapma_3hour" example:"214.21"` // 3-hour moving average of VWAP
@@ -53,9 +68,14 @@ type TradeStat struct {
 //	@Param			ticker path string	true	"symbol to query" example(AAPL)
 //	@Param			start query string	false	"(optional) start of date range in ISO8601. Default is midnight Eastern." Format(ISO8601)
 //	@Param			end query string	false	"(optional) end of date range in ISO8601. Default is now." Format(ISO8601)
+//	@Param			live query boolean	false	"(optional) live-update the chart from the stream - default is true unless 'end' is given"
+//	@Param			inline query boolean	false	"(optional) inline the chart's JavaScript, returning a single-file offline page as an attachment. Implies live=false."
+//	@Param			indicators query string	false	"(optional) comma-separated indicators to draw instead of the VWAP lines: sma:N, ema:N, bb:N:K, rsi:N, macd:FAST:SLOW:SIGNAL, vwap:session" example(ema:20,bb:20:2,rsi:14)
 //	@Success		200	{object}	string "HTML page with candlestick chart"
+//	@Failure		400	{object}	error
 //	@Failure		404	{object}	error "dataset not found"
 //	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
 //	@Router			/charts/candles/{dataset}/{ticker} [get]
 func GetCandleChartByDatasetAndTicker(c *gin.Context) {
 	ticker := c.Param("ticker")
@@ -82,42 +102,115 @@ func GetCandleChartByDatasetAndTicker(c *gin.Context) {
 			return
 		}
 	}
+	live := true
 	if endStr := c.Query("end"); endStr == "" {
 		endTime = middleware.NowEST() // now in Eastern
 	} else {
+		live = false // a fixed range is a snapshot
 		endTime, err = iso8601.ParseString(endStr)
 		if err != nil {
 			middleware.BadRequestError(c, fmt.Errorf("invalid 'start' date format: %s. %w", endStr, err))
 			return
 		}
 	}
+	if liveStr := c.Query("live"); liveStr != "" {
+		live, err = strconv.ParseBool(liveStr)
+		if err != nil {
+			middleware.BadRequestError(c, fmt.Errorf("invalid 'live' value: %s. %w", liveStr, err))
+			return
+		}
+	}
+	inline, err := extractParamInline(c)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+	if inline {
+		live = false // an offline page has no stream
+	}
+	indicatorsStr := c.Query("indicators")
+	indicatorSpecs, err := parseIndicators(indicatorsStr)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
 
 	// query for candlesticks
-	queryStr := `SELECT timestamp, nanos, volume,
-CAST(open AS DOUBLE), CAST(high AS DOUBLE), CAST(low AS DOUBLE), CAST(close AS DOUBLE)
-FROM candles
-WHERE ticker = ? AND timestamp BETWEEN ? AND ? ORDER BY timestamp;`
-	rows, err := gDuckdbConn.QueryContext(context.Background(), queryStr, ticker, startTime.Unix(), endTime.Unix()+1)
+	candles, err := queryCandlesByDatasetAndTicker(c.Request.Context(), ticker, dataset, startTime, endTime)
 	if err != nil {
 		middleware.InternalError(c, fmt.Sprintf("candle query error for ticker:%s dataset:%s", ticker, dataset), err)
 		return
 	}
-	defer rows.Close()
 
-	var candles []*sdk.Candle
-	for rows.Next() {
-		candle := new(sdk.Candle)
-		err := rows.Scan(&candle.Timestamp, &candle.Nanos, &candle.Volume,
-			&candle.Open, &candle.High, &candle.Low, &candle.Close)
+	// query for trades statistics, unless indicators replace the VWAP lines
+	var tradeStats []TradeStat
+	if len(indicatorSpecs) == 0 {
+		tradeStats, err = queryTradeStats(c.Request.Context(), ticker, startTime, endTime)
+		if err != nil {
+			middleware.InternalError(c, fmt.Sprintf("tradeStats query error for ticker:%s dataset:%s", ticker, dataset), err)
+			return
+		}
+	}
+
+	// compute the requested indicators
+	var indicators []*sdk.IndicatorSeries
+	if len(indicatorSpecs) != 0 {
+		indicators, err = computeIndicators(c.Request.Context(), indicatorSpecs, ticker, candles, startTime, endTime)
 		if err != nil {
-			middleware.InternalError(c, fmt.Sprintf("candle scan error for ticker:%s dataset:%s", ticker, dataset), err)
+			middleware.InternalError(c, fmt.Sprintf("indicator error for ticker:%s dataset:%s", ticker, dataset), err)
 			return
 		}
-		candles = append(candles, candle)
 	}
 
-	// query for trades statistics
-	queryStr = `
+	// Continue the chart from the stream, starting after the queried data
+	var liveConfig *liveChartConfig
+	if live {
+		candlesCursor := uint64(startTime.Unix()) * 1_000_000_000
+		if len(candles) > 0 {
+			last := candles[len(candles)-1]
+			candlesCursor = uint64(last.Timestamp)*1_000_000_000 + uint64(last.Nanos)
+		}
+		tradesCursor := uint64(endTime.Unix()+2)*1_000_000_000 - 1 // the last second included by the query
+		liveConfig = &liveChartConfig{
+			StreamURL:        fmt.Sprintf("../../../stream/%s/%s", url.PathEscape(dataset), url.PathEscape(ticker)),
+			LastEventID:      streamCursor{trades: streamPosition{id: tradesCursor}, candles: streamPosition{id: candlesCursor}}.String(),
+			RenderIntervalMs: liveChartRenderInterval.Milliseconds(),
+		}
+		if len(indicatorSpecs) == 0 {
+			liveConfig.TradeStats = true
+			liveConfig.MovingAverages = []liveChartMovingAverage{
+				{Field: "vwapma_30min", Rows: 30},
+				{Field: "vwapma_1hour", Rows: 60},
+				{Field: "vwapma_3hour", Rows: 180},
+			}
+		} else {
+			// indicators are recomputed by the server as each candle closes
+			query := url.Values{}
+			query.Set("indicators", indicatorsStr)
+			query.Set("start", startTime.Format(time.RFC3339))
+			liveConfig.IndicatorsURL = fmt.Sprintf("../../../indicators/%s/%s?%s",
+				url.PathEscape(dataset), url.PathEscape(ticker), query.Encode())
+		}
+	}
+
+	// Create candlestick
+	_, renderSpan := middleware.Tracer.Start(c.Request.Context(), "render candle chart")
+	chartHTML, err := createCandleChartHTML(ticker, dataset, candles, tradeStats, indicators, liveConfig)
+	middleware.EndSpan(renderSpan, err)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("candle generation failed for ticker:%s dataset:%s", ticker, dataset), err)
+		return
+	}
+
+	// Transmit the page
+	if err := writeChartHTML(c, chartHTML, inline, attachmentFilename("html", dataset, ticker, "candles")); err != nil {
+		middleware.InternalError(c, fmt.Sprintf("candle inlining failed for ticker:%s dataset:%s", ticker, dataset), err)
+	}
+}
+
+// queryTradeStats returns the minute VWAP and its moving averages for the ticker's trades in the time range
+func queryTradeStats(ctx context.Context, ticker string, startTime time.Time, endTime time.Time) (tradeStats []TradeStat, err error) {
+	queryStr := `
 -- Calculate VWAP for each minute
 WITH minute_vwap AS (
   SELECT 
@@ -133,6 +226,7 @@ WITH minute_vwap AS (
 SELECT 
   epoch(minute_timestamp) AS timestamp,
   vwap,
+  volume,
   -- 30-minute moving average of VWAP
   AVG(vwap) OVER (
     ORDER BY minute_timestamp 
@@ -151,49 +245,49 @@ SELECT
 FROM minute_vwap
 ORDER BY minute_timestamp;`
 
-	rows, err = gDuckdbConn.QueryContext(context.Background(), queryStr, ticker, startTime.Unix(), endTime.Unix()+1)
+	ctx, span := middleware.StartQuerySpan(ctx, "query trade stats", queryStr)
+	defer func() { middleware.EndQuerySpan(span, len(tradeStats), err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, queryStr, ticker, startTime.Unix(), endTime.Unix()+1)
 	if err != nil {
-		middleware.InternalError(c, fmt.Sprintf("tradeStats query error for ticker:%s dataset:%s", ticker, dataset), err)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
-	var tradeStats []TradeStat
 	for rows.Next() {
 		tradeStat := TradeStat{}
-		err := rows.Scan(&tradeStat.Timestamp, &tradeStat.VwapPrice,
+		err := rows.Scan(&tradeStat.Timestamp, &tradeStat.VwapPrice, &tradeStat.Volume,
 			&tradeStat.VwapMA30min, &tradeStat.VwapMA1hour, &tradeStat.VwapMA3hour)
 		if err != nil {
-			middleware.InternalError(c, fmt.Sprintf("candle scan error for ticker:%s dataset:%s", ticker, dataset), err)
-			return
+			return nil, err
 		}
 		tradeStats = append(tradeStats, tradeStat)
 	}
+	return tradeStats, rows.Err()
+}
 
-	// Create candlestick
-	chartFilename, err := createCandleChartHTML(ticker, dataset, candles, tradeStats)
-	if err != nil {
-		middleware.InternalError(c, fmt.Sprintf("candle generation failed for ticker:%s dataset:%s", ticker, dataset), err)
-		return
-	}
-
-	// Transmit the file
-	c.Header("Content-Type", "text/html")
-	c.File(chartFilename)
+///////////////////////////////////////////////////////////////////////////////
 
-	// Delete the temporary file
-	err = os.Remove(chartFilename)
-	if err != nil {
-		c.Error(fmt.Errorf("failed to remove temp file: %s %w", chartFilename, err))
-		// this does not error-out the request though
-	}
+// liveChartConfig is passed to the liveCandleUpdater script of a live chart
+type liveChartConfig struct {
+	StreamURL        string                   `json:"streamUrl"`        // URL of the SSE stream, relative to the page
+	LastEventID      string                   `json:"lastEventId"`      // Stream cursor of the chart's data
+	TradeStats       bool                     `json:"tradeStats"`       // Whether to maintain the VWAP lines from trades
+	MovingAverages   []liveChartMovingAverage `json:"movingAverages"`   // VWAP moving averages to maintain
+	IndicatorsURL    string                   `json:"indicatorsUrl"`    // URL of the indicators to refetch as candles close, if any
+	RenderIntervalMs int64                    `json:"renderIntervalMs"` // Minimum time between redraws
 }
 
-///////////////////////////////////////////////////////////////////////////////
+// liveChartMovingAverage is a VWAP moving average field of the TradeStat dataset
+type liveChartMovingAverage struct {
+	Field string `json:"field"` // TradeStat JSON field
+	Rows  int    `json:"rows"`  // Number of minutes averaged
+}
 
 // createCandleChartHTML creates an ECharts chart HTML page with the given arguments.
-// Returns the temporary filename, or an error if any. It is the caller's responsibility to delete the file.
-func createCandleChartHTML(ticker string, dataset string, candles []*sdk.Candle, tradeStats []TradeStat) (string, error) {
+// If indicators are given, they are drawn instead of the VWAP lines of tradeStats.
+// If liveConfig is non-nil, the page updates itself from the stream.
+// Returns the rendered page, or an error if any.
+func createCandleChartHTML(ticker string, dataset string, candles []*sdk.Candle, tradeStats []TradeStat, indicators []*sdk.IndicatorSeries, liveConfig *liveChartConfig) ([]byte, error) {
 	// chart title and subtitle
 	chartTitle := fmt.Sprintf("%s Chart", ticker)
 	chartSubtitle := dataset
@@ -211,21 +305,41 @@ func createCandleChartHTML(ticker string, dataset string, candles []*sdk.Candle,
 		Source: candles,
 	}, opts.Dataset{
 		Source: tradeStats,
+	}, opts.Dataset{
+		Source: indicatorDatasetRows(candles, indicators),
 	})
+
+	// Indicators not overlaid on prices get their own pane below the volume
+	panes := indicatorPanes(indicators)
+	grids := []opts.Grid{
+		{Left: "5%", Right: "5%", Height: "50%"},
+		{Left: "5%", Right: "5%", Height: "15%", Top: "72%"},
+	}
+	pageHeight := "500px" // go-echarts default
+	zoomAxes := []int{0, 1}
+	if len(panes) > 0 {
+		// lay out in pixels, growing the page for each pane
+		grids = []opts.Grid{
+			{Left: "5%", Right: "5%", Top: "60", Height: "250"},
+			{Left: "5%", Right: "5%", Top: "340", Height: "75"},
+		}
+		for i := range panes {
+			top := 445 + i*(indicatorPaneHeight+indicatorPaneGap)
+			grids = append(grids, opts.Grid{Left: "5%", Right: "5%",
+				Top: strconv.Itoa(top), Height: strconv.Itoa(indicatorPaneHeight)})
+			zoomAxes = append(zoomAxes, 2+i)
+		}
+		pageHeight = fmt.Sprintf("%dpx", 445+len(panes)*(indicatorPaneHeight+indicatorPaneGap)+60)
+	}
+	initOpts := chartInitializationOpts(ticker)
+	initOpts.Height = pageHeight
 	klineChart.SetGlobalOptions(
-		charts.WithInitializationOpts(opts.Initialization{
-			PageTitle: ticker,
-			Theme:     "dark",
-			Width:     "100%",
-		}),
+		charts.WithInitializationOpts(initOpts),
 		charts.WithTitleOpts(opts.Title{
 			Title:    chartTitle,
 			Subtitle: chartSubtitle,
 		}),
-		charts.WithGridOpts(
-			opts.Grid{Left: "5%", Right: "5%", Height: "50%"},
-			opts.Grid{Left: "5%", Right: "5%", Height: "15%", Top: "72%"},
-		),
+		charts.WithGridOpts(grids...),
 		charts.WithXAxisOpts(opts.XAxis{
 			Type:      "category",
 			GridIndex: 0,
@@ -248,13 +362,13 @@ func createCandleChartHTML(ticker string, dataset string, candles []*sdk.Candle,
 		}),
 		charts.WithDataZoomOpts(opts.DataZoom{
 			Type:           "inside",
-			XAxisIndex:     []int{0, 1},
+			XAxisIndex:     zoomAxes,
 			LabelFormatter: opts.FuncStripCommentsOpts(zoomLabelFormatter),
 			Start:          0,
 			End:            100,
 		}, opts.DataZoom{
 			Type:           "slider",
-			XAxisIndex:     []int{0, 1},
+			XAxisIndex:     zoomAxes,
 			LabelFormatter: opts.FuncStripCommentsOpts(zoomLabelFormatter),
 			Start:          0,
 			End:            100,
@@ -310,66 +424,169 @@ func createCandleChartHTML(ticker string, dataset string, candles []*sdk.Candle,
 	// https://coolors.co/0b58b1-fbff2a-ffad05-ee793e-d92906
 	// color palette
 
-	vwapLineChart := charts.NewLine()
-	vwapLineChart.SetGlobalOptions(
-		charts.WithXAxisOpts(opts.XAxis{SplitNumber: 20, GridIndex: 0}),
-		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), GridIndex: 0}))
-	vwapLineChart.AddSeries("vwap", nil,
-		charts.WithLineStyleOpts(opts.LineStyle{Color: "#fbff2a", Opacity: 0.4, Type: "dashed"}),
-		charts.WithItemStyleOpts(opts.ItemStyle{Color: "#fbff2a", Opacity: 0.4}),
-		charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 0, YAxisIndex: 0}),
-		charts.WithDatasetIndex(1),
-		charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "vwap"}))
-	klineChart.Overlap(vwapLineChart)
-
-	vwapMA30minLineChart := charts.NewLine()
-	vwapMA30minLineChart.SetGlobalOptions(
-		charts.WithXAxisOpts(opts.XAxis{SplitNumber: 20, GridIndex: 0}),
-		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), GridIndex: 0}))
-	vwapMA30minLineChart.AddSeries("vwapMA-30min", nil,
-		charts.WithLineStyleOpts(opts.LineStyle{Color: "#ffad05", Opacity: 0.4, Type: "dashed"}),
-		charts.WithItemStyleOpts(opts.ItemStyle{Color: "#ffad05", Opacity: 0.4}),
-		charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 0, YAxisIndex: 0}),
-		charts.WithDatasetIndex(1),
-		charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "vwapma_30min"}))
-	klineChart.Overlap(vwapMA30minLineChart)
-
-	vwapMA1hourLineChart := charts.NewLine()
-	vwapMA1hourLineChart.SetGlobalOptions(
-		charts.WithXAxisOpts(opts.XAxis{SplitNumber: 20, GridIndex: 0}),
-		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), GridIndex: 0}))
-	vwapMA1hourLineChart.AddSeries("vwapMA-1hour", nil,
-		charts.WithLineStyleOpts(opts.LineStyle{Color: "#ee793e", Opacity: 0.4, Type: "dashed"}),
-		charts.WithItemStyleOpts(opts.ItemStyle{Color: "#ee793e", Opacity: 0.4}),
-		charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 0, YAxisIndex: 0}),
-		charts.WithDatasetIndex(1),
-		charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "vwapma_1hour"}))
-	klineChart.Overlap(vwapMA1hourLineChart)
-
-	vwapMA3hourLineChart := charts.NewLine()
-	vwapMA3hourLineChart.SetGlobalOptions(
-		charts.WithXAxisOpts(opts.XAxis{SplitNumber: 20, GridIndex: 0}),
-		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), GridIndex: 0}))
-	vwapMA3hourLineChart.AddSeries("vwapMA-3hour", nil,
-		charts.WithLineStyleOpts(opts.LineStyle{Color: "#d92906", Opacity: 0.4, Type: "dashed"}),
-		charts.WithItemStyleOpts(opts.ItemStyle{Color: "#d92906", Opacity: 0.4}),
-		charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 0, YAxisIndex: 0}),
-		charts.WithDatasetIndex(1),
-		charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "vwapma_3hour"}))
-	klineChart.Overlap(vwapMA3hourLineChart)
+	if len(indicators) == 0 {
+		vwapLineChart := charts.NewLine()
+		vwapLineChart.SetGlobalOptions(
+			charts.WithXAxisOpts(opts.XAxis{SplitNumber: 20, GridIndex: 0}),
+			charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), GridIndex: 0}))
+		vwapLineChart.AddSeries("vwap", nil,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: "#fbff2a", Opacity: 0.4, Type: "dashed"}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: "#fbff2a", Opacity: 0.4}),
+			charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 0, YAxisIndex: 0}),
+			charts.WithDatasetIndex(1),
+			charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "vwap"}))
+		klineChart.Overlap(vwapLineChart)
+
+		vwapMA30minLineChart := charts.NewLine()
+		vwapMA30minLineChart.SetGlobalOptions(
+			charts.WithXAxisOpts(opts.XAxis{SplitNumber: 20, GridIndex: 0}),
+			charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), GridIndex: 0}))
+		vwapMA30minLineChart.AddSeries("vwapMA-30min", nil,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: "#ffad05", Opacity: 0.4, Type: "dashed"}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: "#ffad05", Opacity: 0.4}),
+			charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 0, YAxisIndex: 0}),
+			charts.WithDatasetIndex(1),
+			charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "vwapma_30min"}))
+		klineChart.Overlap(vwapMA30minLineChart)
+
+		vwapMA1hourLineChart := charts.NewLine()
+		vwapMA1hourLineChart.SetGlobalOptions(
+			charts.WithXAxisOpts(opts.XAxis{SplitNumber: 20, GridIndex: 0}),
+			charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), GridIndex: 0}))
+		vwapMA1hourLineChart.AddSeries("vwapMA-1hour", nil,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: "#ee793e", Opacity: 0.4, Type: "dashed"}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: "#ee793e", Opacity: 0.4}),
+			charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 0, YAxisIndex: 0}),
+			charts.WithDatasetIndex(1),
+			charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "vwapma_1hour"}))
+		klineChart.Overlap(vwapMA1hourLineChart)
+
+		vwapMA3hourLineChart := charts.NewLine()
+		vwapMA3hourLineChart.SetGlobalOptions(
+			charts.WithXAxisOpts(opts.XAxis{SplitNumber: 20, GridIndex: 0}),
+			charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), GridIndex: 0}))
+		vwapMA3hourLineChart.AddSeries("vwapMA-3hour", nil,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: "#d92906", Opacity: 0.4, Type: "dashed"}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: "#d92906", Opacity: 0.4}),
+			charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 0, YAxisIndex: 0}),
+			charts.WithDatasetIndex(1),
+			charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "vwapma_3hour"}))
+		klineChart.Overlap(vwapMA3hourLineChart)
+	} else {
+		addIndicatorSeries(klineChart, indicators, panes)
+	}
+
+	// Follow the stream, if live
+	if liveConfig != nil {
+		liveConfigJSON, err := json.Marshal(liveConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal live config: %w", err)
+		}
+		updater := jsBlockCommentPat.ReplaceAllString(liveCandleUpdater, "")
+		klineChart.AddJSFuncStrs(types.FuncStr(fmt.Sprintf("(%s)(%%MY_ECHARTS%%, %s);", updater, liveConfigJSON)))
+	}
 
 	// Where the magic happens!
 	//
-	// Grab a temporary file for destination
-	tempFile, err := os.CreateTemp("", "charts-candle-*.html")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+	// Render the chart HTML page to memory
+	var chartHTML bytes.Buffer
+	if err := klineChart.Render(&chartHTML); err != nil {
+		return nil, fmt.Errorf("failed to render chart: %w", err)
 	}
-	// Render the chart HTML page to the file
-	err = klineChart.Render(tempFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to render chart: %w", err)
+	return chartHTML.Bytes(), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+const (
+	indicatorPaneHeight = 100 // height of an indicator pane, in pixels
+	indicatorPaneGap    = 30  // gap above an indicator pane, in pixels
+)
+
+// indicatorColors is the palette cycled through by indicator lines
+var indicatorColors = []string{"#0b58b1", "#fbff2a", "#ffad05", "#ee793e", "#d92906", "#9b5de5", "#00bbf9"}
+
+// indicatorDatasetRows returns a dataset row for each candle, with a field per indicator series.
+// Every row has every field, null where the indicator has no value, so ECharts finds each dimension.
+func indicatorDatasetRows(candles []*sdk.Candle, indicators []*sdk.IndicatorSeries) []map[string]any {
+	rows := make([]map[string]any, len(candles))
+	rowIndex := make(map[int64]int, len(candles))
+	for i, candle := range candles {
+		row := map[string]any{"ts": candle.Timestamp}
+		for _, series := range indicators {
+			row[series.Name] = nil
+		}
+		rows[i] = row
+		rowIndex[candle.Timestamp] = i
+	}
+	for _, series := range indicators {
+		for _, point := range series.Points {
+			if i, ok := rowIndex[point.Timestamp]; ok {
+				rows[i][series.Name] = point.Value
+			}
+		}
+	}
+	return rows
+}
+
+// indicatorPanes returns the distinct non-overlay panes of the indicators, in order
+func indicatorPanes(indicators []*sdk.IndicatorSeries) []string {
+	var panes []string
+	for _, series := range indicators {
+		if series.Pane != indicatorPaneOverlay && !slices.Contains(panes, series.Pane) {
+			panes = append(panes, series.Pane)
+		}
+	}
+	return panes
+}
+
+// addIndicatorSeries adds the indicators to the chart from dataset 2.
+// Overlays share the price axes; each pane gets its own axes, after the price and volume axes.
+func addIndicatorSeries(klineChart *charts.Kline, indicators []*sdk.IndicatorSeries, panes []string) {
+	for i := range panes {
+		gridIndex := 2 + i
+		klineChart.ExtendXAxis(opts.XAxis{
+			Type:      "category",
+			GridIndex: gridIndex,
+			AxisTick:  &opts.AxisTick{Show: opts.Bool(false)},
+			AxisLabel: &opts.AxisLabel{Show: opts.Bool(false)},
+		})
+		klineChart.ExtendYAxis(opts.YAxis{
+			Type:        "value",
+			Scale:       opts.Bool(true),
+			GridIndex:   gridIndex,
+			SplitNumber: 2,
+			AxisLabel:   &opts.AxisLabel{Show: opts.Bool(true)},
+			AxisLine:    &opts.AxisLine{Show: opts.Bool(true)},
+			SplitLine:   &opts.SplitLine{Show: opts.Bool(true)},
+		})
 	}
 
-	return tempFile.Name(), nil
+	for i, series := range indicators {
+		color := indicatorColors[i%len(indicatorColors)]
+		axisIndex := 0
+		if series.Pane != indicatorPaneOverlay {
+			axisIndex = 2 + slices.Index(panes, series.Pane)
+		}
+
+		if series.Line == "hist" {
+			histBarChart := charts.NewBar()
+			histBarChart.AddSeries(series.Name, nil,
+				charts.WithItemStyleOpts(opts.ItemStyle{Color: color, Opacity: 0.6}),
+				charts.WithBarChartOpts(opts.BarChart{XAxisIndex: axisIndex, YAxisIndex: axisIndex}),
+				charts.WithDatasetIndex(2),
+				charts.WithEncodeOpts(opts.Encode{X: "ts", Y: series.Name}))
+			klineChart.Overlap(histBarChart)
+			continue
+		}
+
+		lineChart := charts.NewLine()
+		lineChart.AddSeries(series.Name, nil,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: color, Opacity: 0.6}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: color, Opacity: 0.6}),
+			charts.WithLineChartOpts(opts.LineChart{XAxisIndex: axisIndex, YAxisIndex: axisIndex}),
+			charts.WithDatasetIndex(2),
+			charts.WithEncodeOpts(opts.Encode{X: "ts", Y: series.Name}))
+		klineChart.Overlap(lineChart)
+	}
 }
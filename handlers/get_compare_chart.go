@@ -0,0 +1,333 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	_ "embed" // Required for go:embed
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
+)
+
+//go:embed js/compareTooltipFormatter.js
+var compareTooltipFormatter string
+
+// maxCompareSymbols is the most symbols a comparison chart may plot
+const maxCompareSymbols = 10
+
+// compareRatioField is the dataset field of the relative-strength ratio
+const compareRatioField = "ratio"
+
+// CompareStat is the close of a ticker's candle and its percent change from the first close in range
+type CompareStat struct {
+	Timestamp     int64   // Candle timestamp as seconds from the epoch
+	Ticker        string  // Ticker of the candle
+	Close         float64 // Close price of the candle
+	PercentChange float64 // Percent change of Close from the first close in range
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Returns an HTML page comparing the percent change of several tickers of a dataset.
+//
+//	@Summary		Returns an HTML page comparing the percent change of several tickers of a dataset.
+//	@ID				GetCompareChartByDataset
+//	@Description	Returns an HTML page plotting each symbol's percent change from its first close in the range, on a shared time axis.
+//	@Description	With 'ratio', a second pane plots the relative strength of one symbol to another as the ratio of their closes.
+//	@Produce		html
+//	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
+//	@Param			symbols query string	true	"comma-separated symbols to compare" example(SPY,QQQ,IWM)
+//	@Param			ratio query string	false	"(optional) two comma-separated symbols, plotting the ratio of the first's close to the second's" example(QQQ,SPY)
+//	@Param			inline query boolean	false	"(optional) inline the chart's JavaScript, returning a single-file offline page as an attachment"
+//	@Param			start query string	false	"(optional) start of date range in ISO8601. Default is midnight Eastern." Format(ISO8601)
+//	@Param			end query string	false	"(optional) end of date range in ISO8601. Default is now." Format(ISO8601)
+//	@Success		200	{object}	string "HTML page with comparison chart"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error "dataset not found"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/charts/compare/{dataset} [get]
+func GetCompareChartByDataset(c *gin.Context) {
+	dataset := c.Param("dataset")
+	if dataset == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":dataset cannot be empty"))
+		return
+	}
+
+	symbols := parseSymbolList(c.Query("symbols"))
+	if len(symbols) == 0 {
+		middleware.BadRequestError(c, fmt.Errorf("'symbols' cannot be empty"))
+		return
+	}
+	if len(symbols) > maxCompareSymbols {
+		middleware.BadRequestError(c, fmt.Errorf("too many symbols, max is %d", maxCompareSymbols))
+		return
+	}
+
+	var ratio []string
+	if ratioStr := c.Query("ratio"); ratioStr != "" {
+		ratio = parseSymbolList(ratioStr)
+		if len(ratio) != 2 {
+			middleware.BadRequestError(c, fmt.Errorf("'ratio' must be two different symbols: %s", ratioStr))
+			return
+		}
+	}
+
+	startTime, endTime, err := extractParamsTimeRange(c)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+	inline, err := extractParamInline(c)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+
+	// the ratio's symbols are queried even if they are not plotted
+	tickers := slices.Clone(symbols)
+	for _, ticker := range ratio {
+		if !slices.Contains(tickers, ticker) {
+			tickers = append(tickers, ticker)
+		}
+	}
+	stats, err := queryCompareStats(c.Request.Context(), tickers, startTime, endTime)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("compare query error for symbols:%s dataset:%s", strings.Join(tickers, ","), dataset), err)
+		return
+	}
+
+	_, renderSpan := middleware.Tracer.Start(c.Request.Context(), "render compare chart")
+	chartHTML, err := createCompareChartHTML(dataset, symbols, ratio, stats)
+	middleware.EndSpan(renderSpan, err)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("compare generation failed for symbols:%s dataset:%s", strings.Join(tickers, ","), dataset), err)
+		return
+	}
+	if err := writeChartHTML(c, chartHTML, inline, attachmentFilename("html", dataset, strings.Join(symbols, "_"), "compare")); err != nil {
+		middleware.InternalError(c, fmt.Sprintf("compare inlining failed for symbols:%s dataset:%s", strings.Join(tickers, ","), dataset), err)
+	}
+}
+
+// parseSymbolList parses a comma-separated list of symbols, dropping blanks and duplicates
+func parseSymbolList(str string) []string {
+	var symbols []string
+	for _, symbol := range strings.Split(str, ",") {
+		symbol = strings.TrimSpace(symbol)
+		if symbol != "" && !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// queryCompareStats selects the candle closes of the tickers, with each close's
+// percent change from the ticker's first close in the time range.
+func queryCompareStats(ctx context.Context, tickers []string, startTime time.Time, endTime time.Time) (stats []CompareStat, err error) {
+	queryStr := `
+WITH closes AS (
+  SELECT timestamp, ticker, CAST(close AS DOUBLE) AS close
+  FROM candles
+  WHERE ticker IN (?` + strings.Repeat(", ?", len(tickers)-1) + `) AND timestamp BETWEEN ? AND ?
+),
+firsts AS (
+  SELECT ticker, arg_min(close, timestamp) AS first_close
+  FROM closes
+  GROUP BY ticker
+)
+SELECT closes.timestamp, closes.ticker, closes.close,
+  100 * (closes.close / NULLIF(firsts.first_close, 0) - 1) AS pct_change
+FROM closes JOIN firsts USING (ticker)
+ORDER BY closes.timestamp, closes.ticker;`
+
+	args := make([]any, 0, len(tickers)+2)
+	for _, ticker := range tickers {
+		args = append(args, ticker)
+	}
+	args = append(args, startTime.Unix(), endTime.Unix()+1)
+
+	var rowCount int
+	ctx, span := middleware.StartQuerySpan(ctx, "query compare stats", queryStr)
+	defer func() { middleware.EndQuerySpan(span, rowCount, err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rowCount++
+		var stat CompareStat
+		var pctChange *float64
+		if err := rows.Scan(&stat.Timestamp, &stat.Ticker, &stat.Close, &pctChange); err != nil {
+			return nil, err
+		}
+		if pctChange == nil {
+			continue // zero first close
+		}
+		stat.PercentChange = *pctChange
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// compareDatasetRows returns a dataset row per timestamp, with a "pct_<symbol>" field per symbol
+// and, if ratio is given, the ratio field.  Every row has every field, null where there is no value.
+func compareDatasetRows(symbols []string, ratio []string, stats []CompareStat) []map[string]any {
+	var rows []map[string]any
+	var closes map[string]float64 // closes of the current row's timestamp
+	for i, stat := range stats {
+		if i == 0 || stat.Timestamp != stats[i-1].Timestamp {
+			row := map[string]any{"ts": stat.Timestamp}
+			for _, symbol := range symbols {
+				row["pct_"+symbol] = nil
+			}
+			if len(ratio) == 2 {
+				row[compareRatioField] = nil
+			}
+			rows = append(rows, row)
+			closes = make(map[string]float64)
+		}
+		row := rows[len(rows)-1]
+		if _, ok := row["pct_"+stat.Ticker]; ok {
+			row["pct_"+stat.Ticker] = stat.PercentChange
+		}
+		closes[stat.Ticker] = stat.Close
+
+		if len(ratio) == 2 {
+			numerator, okNum := closes[ratio[0]]
+			denominator, okDenom := closes[ratio[1]]
+			if okNum && okDenom && denominator != 0 {
+				row[compareRatioField] = numerator / denominator
+			}
+		}
+	}
+	return rows
+}
+
+// createCompareChartHTML creates an ECharts chart HTML page of the symbols' percent change.
+// If ratio is given, the relative strength ratio is plotted in a second pane.
+// Returns the rendered page, or an error if any.
+func createCompareChartHTML(dataset string, symbols []string, ratio []string, stats []CompareStat) ([]byte, error) {
+	// chart title and subtitle
+	chartTitle := strings.Join(symbols, " vs ")
+	chartSubtitle := dataset
+	if len(stats) > 0 {
+		chartSubtitle += fmt.Sprintf(" -- %s to %s",
+			time.Unix(stats[0].Timestamp, 0).Format("2006-01-02 15:04:05"),
+			time.Unix(stats[len(stats)-1].Timestamp, 0).Format("2006-01-02 15:04:05"))
+	}
+
+	grids := []opts.Grid{{Left: "5%", Right: "5%", Height: "70%"}}
+	zoomAxes := []int{0}
+	if len(ratio) == 2 {
+		grids = []opts.Grid{
+			{Left: "5%", Right: "5%", Height: "50%"},
+			{Left: "5%", Right: "5%", Height: "15%", Top: "72%"},
+		}
+		zoomAxes = []int{0, 1}
+	}
+
+	lineChart := charts.NewLine()
+	lineChart.AddDataset(opts.Dataset{
+		Source: compareDatasetRows(symbols, ratio, stats),
+	})
+	lineChart.SetGlobalOptions(
+		charts.WithInitializationOpts(chartInitializationOpts(chartTitle)),
+		charts.WithTitleOpts(opts.Title{
+			Title:    chartTitle,
+			Subtitle: chartSubtitle,
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "5%"}),
+		charts.WithGridOpts(grids...),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type:      "category",
+			GridIndex: 0,
+			AxisLabel: &opts.AxisLabel{
+				Show:      opts.Bool(true),
+				Formatter: types.FuncStr(opts.FuncStripCommentsOpts(xAxisFormatter)),
+			},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Type:      "value",
+			Scale:     opts.Bool(true),
+			GridIndex: 0,
+			AxisLabel: &opts.AxisLabel{
+				Show:      opts.Bool(true),
+				Formatter: types.FuncStr(opts.FuncOpts("function (value) { return value.toFixed(1) + '%'; }")),
+			},
+		}),
+		charts.WithDataZoomOpts(opts.DataZoom{
+			Type:           "inside",
+			XAxisIndex:     zoomAxes,
+			LabelFormatter: opts.FuncStripCommentsOpts(zoomLabelFormatter),
+			Start:          0,
+			End:            100,
+		}, opts.DataZoom{
+			Type:           "slider",
+			XAxisIndex:     zoomAxes,
+			LabelFormatter: opts.FuncStripCommentsOpts(zoomLabelFormatter),
+			Start:          0,
+			End:            100,
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:        opts.Bool(true),
+			Trigger:     "axis",
+			AxisPointer: &opts.AxisPointer{Type: "line"},
+			Formatter:   types.FuncStr(opts.FuncStripCommentsOpts(compareTooltipFormatter)),
+		}),
+	)
+
+	// a percent change line per symbol, connected across minutes where it did not trade
+	for i, symbol := range symbols {
+		color := indicatorColors[i%len(indicatorColors)]
+		lineChart.AddSeries(symbol, nil,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: color}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: color}),
+			charts.WithLineChartOpts(opts.LineChart{ShowSymbol: opts.Bool(false), ConnectNulls: opts.Bool(true)}),
+			charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "pct_" + symbol}))
+	}
+
+	// relative strength ratio in its own pane
+	if len(ratio) == 2 {
+		lineChart.ExtendXAxis(opts.XAxis{
+			Type:      "category",
+			GridIndex: 1,
+			AxisTick:  &opts.AxisTick{Show: opts.Bool(false)},
+			AxisLabel: &opts.AxisLabel{Show: opts.Bool(false)},
+		})
+		lineChart.ExtendYAxis(opts.YAxis{
+			Type:        "value",
+			Scale:       opts.Bool(true),
+			GridIndex:   1,
+			SplitNumber: 2,
+			AxisLabel:   &opts.AxisLabel{Show: opts.Bool(true)},
+			AxisLine:    &opts.AxisLine{Show: opts.Bool(true)},
+			SplitLine:   &opts.SplitLine{Show: opts.Bool(true)},
+		})
+		lineChart.AddSeries(fmt.Sprintf("%s/%s", ratio[0], ratio[1]), nil,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: "#fbff2a", Opacity: 0.6}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: "#fbff2a", Opacity: 0.6}),
+			charts.WithLineChartOpts(opts.LineChart{XAxisIndex: 1, YAxisIndex: 1,
+				ShowSymbol: opts.Bool(false), ConnectNulls: opts.Bool(true)}),
+			charts.WithEncodeOpts(opts.Encode{X: "ts", Y: compareRatioField}))
+	}
+
+	// Render the chart HTML page to memory
+	var chartHTML bytes.Buffer
+	if err := lineChart.Render(&chartHTML); err != nil {
+		return nil, fmt.Errorf("failed to render chart: %w", err)
+	}
+	return chartHTML.Bytes(), nil
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+const (
+	defaultDashboardRefresh = 30 * time.Second // default time between dashboard reloads
+	dashboardTileWidth      = "320px"
+	dashboardTileHeight     = "180px"
+	dashboardBackground     = "#100c2a" // ECharts dark theme background
+)
+
+// WatchlistTile is the session summary of a symbol shown on the dashboard
+type WatchlistTile struct {
+	Ticker        string    // Ticker of the symbol
+	Timestamps    []int64   // Candle timestamps as seconds from the epoch
+	Closes        []float64 // Candle closes, for the sparkline
+	Open          float64   // Open of the session's first candle
+	Last          float64   // Close of the session's last candle
+	Change        float64   // Last minus Open
+	ChangePercent float64   // Change as a percent of Open
+	Volume        uint64    // Session volume
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// GetDashboardByDataset returns an HTML page with a tile for each subscribed symbol of the dataset.
+// Each tile has a sparkline of the session's closes, the last price, change and volume,
+// and links to the symbol's candlestick chart.  The page reloads itself every 'refresh'
+// seconds (default 30, 0 to disable).  The session starts at midnight Eastern.
+func GetDashboardByDataset(c *gin.Context) {
+	dataset := c.Param("dataset")
+	if dataset == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":dataset cannot be empty"))
+		return
+	}
+
+	refresh := defaultDashboardRefresh
+	if refreshStr := c.Query("refresh"); refreshStr != "" {
+		seconds, err := strconv.Atoi(refreshStr)
+		if err != nil || seconds < 0 {
+			middleware.BadRequestError(c, fmt.Errorf("invalid 'refresh' value: %s", refreshStr))
+			return
+		}
+		refresh = time.Duration(seconds) * time.Second
+	}
+
+	liveClient := getLiveDataClient(dataset)
+	if liveClient == nil {
+		middleware.NotFoundError(c, fmt.Errorf("dataset not found: %s", dataset))
+		return
+	}
+	symbols := liveClient.Subscriptions()
+
+	year, month, day := middleware.NowEST().Date()
+	sessionStart := time.Date(year, month, day, 0, 0, 0, 0, middleware.EasternLocation())
+	tiles, err := queryWatchlistTiles(c.Request.Context(), symbols, sessionStart)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("dashboard query error for dataset:%s", dataset), err)
+		return
+	}
+
+	_, renderSpan := middleware.Tracer.Start(c.Request.Context(), "render dashboard")
+	pageHTML, err := createDashboardHTML(dataset, tiles, refresh)
+	middleware.EndSpan(renderSpan, err)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("dashboard generation failed for dataset:%s", dataset), err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", pageHTML)
+}
+
+// queryWatchlistTiles summarizes the candles of each symbol since sessionStart.
+// Returns a tile per symbol, in order, including symbols without candles.
+func queryWatchlistTiles(ctx context.Context, symbols []string, sessionStart time.Time) (tiles []*WatchlistTile, err error) {
+	tiles = make([]*WatchlistTile, len(symbols))
+	tileByTicker := make(map[string]*WatchlistTile, len(symbols))
+	for i, symbol := range symbols {
+		tiles[i] = &WatchlistTile{Ticker: symbol}
+		tileByTicker[symbol] = tiles[i]
+	}
+	if len(symbols) == 0 {
+		return tiles, nil
+	}
+
+	queryStr := `SELECT ticker, timestamp, CAST(open AS DOUBLE), CAST(close AS DOUBLE), volume
+FROM candles
+WHERE ticker IN (?` + strings.Repeat(", ?", len(symbols)-1) + `) AND timestamp >= ?
+ORDER BY ticker, timestamp;`
+	args := make([]any, 0, len(symbols)+1)
+	for _, symbol := range symbols {
+		args = append(args, symbol)
+	}
+	args = append(args, sessionStart.Unix())
+
+	var rowCount int
+	ctx, span := middleware.StartQuerySpan(ctx, "query watchlist tiles", queryStr)
+	defer func() { middleware.EndQuerySpan(span, rowCount, err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rowCount++
+		var ticker string
+		var timestamp int64
+		var open, close float64
+		var volume uint64
+		if err := rows.Scan(&ticker, &timestamp, &open, &close, &volume); err != nil {
+			return nil, err
+		}
+		tile := tileByTicker[ticker]
+		if len(tile.Closes) == 0 {
+			tile.Open = open
+		}
+		tile.Timestamps = append(tile.Timestamps, timestamp)
+		tile.Closes = append(tile.Closes, close)
+		tile.Last = close
+		tile.Volume += volume
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, tile := range tiles {
+		if len(tile.Closes) != 0 && tile.Open != 0 {
+			tile.Change = tile.Last - tile.Open
+			tile.ChangePercent = 100 * tile.Change / tile.Open
+		}
+	}
+	return tiles, nil
+}
+
+// createDashboardHTML creates a page of sparkline tiles, reloading every refresh if non-zero.
+// Returns the rendered page, or an error if any.
+func createDashboardHTML(dataset string, tiles []*WatchlistTile, refresh time.Duration) ([]byte, error) {
+	page := components.NewPage()
+	page.SetPageTitle(fmt.Sprintf("%s Dashboard", dataset))
+	page.SetAssetsHost(chartAssetsHost())
+	page.SetLayout(components.PageFlexLayout)
+	page.AddCustomizedHeaders(fmt.Sprintf(`<style>body { background-color: %s; }</style>`, dashboardBackground))
+	if refresh > 0 {
+		page.AddCustomizedHeaders(fmt.Sprintf(`<meta http-equiv="refresh" content="%d">`, int(refresh.Seconds())))
+	}
+
+	if len(tiles) == 0 {
+		emptyChart := charts.NewLine()
+		emptyChart.SetGlobalOptions(
+			charts.WithInitializationOpts(chartInitializationOpts(dataset)),
+			charts.WithTitleOpts(opts.Title{Title: dataset, Subtitle: "no symbols subscribed"}),
+		)
+		page.AddCharts(emptyChart)
+	}
+	for _, tile := range tiles {
+		page.AddCharts(createWatchlistTileChart(dataset, tile))
+	}
+
+	var pageHTML bytes.Buffer
+	if err := page.Render(&pageHTML); err != nil {
+		return nil, fmt.Errorf("failed to render dashboard: %w", err)
+	}
+	return pageHTML.Bytes(), nil
+}
+
+// createWatchlistTileChart creates a tile's sparkline chart, titled with its summary and
+// linked to the symbol's candlestick chart
+func createWatchlistTileChart(dataset string, tile *WatchlistTile) *charts.Line {
+	// https://coolors.co/0b58b1-fbff2a-ffad05-ee793e-d92906
+	color, subtitle := "#7fbe9e", "no data this session"
+	if len(tile.Closes) != 0 {
+		if tile.Change < 0 {
+			color = "#ec0000"
+		} else {
+			color = "#00da3c"
+		}
+		subtitle = fmt.Sprintf("%.2f   %+.2f (%+.2f%%)   vol %s",
+			tile.Last, tile.Change, tile.ChangePercent, formatVolume(tile.Volume))
+	}
+
+	initOpts := chartInitializationOpts(tile.Ticker)
+	initOpts.Width, initOpts.Height = dashboardTileWidth, dashboardTileHeight
+
+	chartURL := fmt.Sprintf("../api/v1/charts/candles/%s/%s", url.PathEscape(dataset), url.PathEscape(tile.Ticker))
+	lineChart := charts.NewLine()
+	lineChart.SetGlobalOptions(
+		charts.WithInitializationOpts(initOpts),
+		charts.WithTitleOpts(opts.Title{
+			Title:      tile.Ticker,
+			Link:       chartURL,
+			Target:     "self",
+			Subtitle:   subtitle,
+			SubLink:    chartURL,
+			SubTarget:  "self",
+			TitleStyle: &opts.TextStyle{Color: color},
+		}),
+		charts.WithGridOpts(opts.Grid{Left: "5", Right: "5", Top: "60", Bottom: "5"}),
+		charts.WithXAxisOpts(opts.XAxis{Show: opts.Bool(false)}),
+		charts.WithYAxisOpts(opts.YAxis{Show: opts.Bool(false), Scale: opts.Bool(true)}),
+	)
+
+	closes := make([]opts.LineData, len(tile.Closes))
+	for i, close := range tile.Closes {
+		closes[i] = opts.LineData{Value: close}
+	}
+	lineChart.SetXAxis(tile.Timestamps).
+		AddSeries(tile.Ticker, closes,
+			charts.WithLineStyleOpts(opts.LineStyle{Color: color, Width: 1.5}),
+			charts.WithAreaStyleOpts(opts.AreaStyle{Color: color, Opacity: 0.15}),
+			charts.WithLineChartOpts(opts.LineChart{ShowSymbol: opts.Bool(false)}))
+	return lineChart
+}
+
+// formatVolume formats a volume compactly, e.g. 1234567 as "1.2M"
+func formatVolume(volume uint64) string {
+	switch {
+	case volume >= 1_000_000_000:
+		return fmt.Sprintf("%.1fB", float64(volume)/1e9)
+	case volume >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(volume)/1e6)
+	case volume >= 1_000:
+		return fmt.Sprintf("%.1fK", float64(volume)/1e3)
+	default:
+		return strconv.FormatUint(volume, 10)
+	}
+}
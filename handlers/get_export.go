@@ -0,0 +1,162 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxExportRows is the most rows one export may write, bounding its scratch file
+const maxExportRows = 1_000_000
+
+// exportFormat is a file format DuckDB can COPY to
+type exportFormat struct {
+	copyOptions string // COPY ... TO ... WITH options
+	contentType string // HTTP Content-Type of the file
+}
+
+// exportFormats are the export formats, by 'format' parameter and file extension
+var exportFormats = map[string]exportFormat{
+	"csv":     {copyOptions: "FORMAT csv, HEADER true", contentType: "text/csv"},
+	"xlsx":    {copyOptions: "FORMAT xlsx, HEADER true", contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	"parquet": {copyOptions: "FORMAT parquet", contentType: "application/vnd.apache.parquet"},
+	"json":    {copyOptions: "FORMAT json, ARRAY true", contentType: "application/json"},
+}
+
+// exportTableQueries select the exported columns of each table, by 'table' parameter.
+// Each takes the ticker and a timestamp range.
+var exportTableQueries = map[string]string{
+	"trades": `SELECT MAKE_TIMESTAMP(CAST(timestamp AS BIGINT)*1_000_000 + nanos // 1_000) AS time, publisher, ticker,
+  CAST(price AS DOUBLE) AS price, shares
+FROM trades
+WHERE ticker = ? AND timestamp BETWEEN ? AND ?
+ORDER BY timestamp, nanos`,
+	"candles": `SELECT MAKE_TIMESTAMP(CAST(timestamp AS BIGINT)*1_000_000 + nanos // 1_000) AS time, publisher, ticker,
+  CAST(open AS DOUBLE) AS open, CAST(high AS DOUBLE) AS high, CAST(low AS DOUBLE) AS low, CAST(close AS DOUBLE) AS close, volume
+FROM candles
+WHERE ticker = ? AND timestamp BETWEEN ? AND ?
+ORDER BY timestamp, nanos`,
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Returns a file of trades or candles for a Dataset, Ticker and time range.
+//
+//	@Summary		Export trades or candles for a Dataset and Ticker
+//	@ID				GetExportByDatasetAndTicker
+//	@Description	Returns a file of trades or candles for a Dataset, Ticker and time range, as an attachment.
+//	@Description	At most 1,000,000 rows are exported; the X-Row-Count header has the number of rows in the file.
+//	@Produce		text/csv
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Produce		application/vnd.apache.parquet
+//	@Produce		json
+//	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
+//	@Param			ticker path string	true	"symbol to query" example(AAPL)
+//	@Param			table query string	false	"(optional) table to export - default is candles" Enums(trades, candles)
+//	@Param			format query string	false	"(optional) file format - default is csv" Enums(csv, xlsx, parquet, json)
+//	@Param			start query string	false	"(optional) start of date range in ISO8601. Default is midnight Eastern." Format(ISO8601)
+//	@Param			end query string	false	"(optional) end of date range in ISO8601. Default is now." Format(ISO8601)
+//	@Param			limit query integer	false	"(optional) maximum rows to export - default and maximum is 1000000"
+//	@Success		200	string      string "file of trades or candles"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error "dataset not found"
+//	@Failure		500	{object}	error
+//	@Failure		503	{object}	error "too many concurrent exports"
+//	@Security		ApiKeyAuth
+//	@Router			/export/{dataset}/{ticker} [get]
+func GetExportByDatasetAndTicker(c *gin.Context) {
+	ticker := c.Param("ticker")
+	if ticker == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":ticker cannot be empty"))
+		return
+	}
+
+	dataset := c.Param("dataset")
+	if dataset == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":dataset cannot be empty"))
+		return
+	}
+
+	table := c.DefaultQuery("table", "candles")
+	if _, ok := exportTableQueries[table]; !ok {
+		middleware.BadRequestError(c, fmt.Errorf("invalid 'table' value: %s. Must be trades or candles", table))
+		return
+	}
+
+	formatName := c.DefaultQuery("format", "csv")
+	format, ok := exportFormats[formatName]
+	if !ok {
+		middleware.BadRequestError(c, fmt.Errorf("invalid 'format' value: %s. Must be csv, xlsx, parquet or json", formatName))
+		return
+	}
+
+	startTime, endTime, err := extractParamsTimeRange(c)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+
+	limit := maxExportRows
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = middleware.ValidatePositiveNonzeroInteger(limitStr)
+		if err != nil {
+			middleware.BadRequestError(c, fmt.Errorf("invalid 'limit' value: %s. %w", limitStr, err))
+			return
+		}
+		limit = min(limit, maxExportRows)
+	}
+
+	// Trace the export, from reserving its file to transmitting it
+	ctx, exportSpan := middleware.Tracer.Start(c.Request.Context(), "export file", trace.WithAttributes(
+		attribute.String("export.table", table),
+		attribute.String("export.format", formatName),
+	))
+	defer exportSpan.End()
+
+	// Reserve an export file, deleted after transmitting
+	exportFile, release, ok := acquireExportFile(c, fmt.Sprintf("%s-*.%s", table, formatName))
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Perform the query
+	rowCount, err := copyTableRangeFormatted(ctx, format, exportFile, table, ticker, startTime, endTime, limit)
+	if err != nil {
+		errorMsg := fmt.Sprintf("export error for ticker:%s dataset:%s", ticker, dataset)
+		middleware.InternalError(c, errorMsg, err)
+		return
+	}
+
+	// Transmit the file
+	exportSpan.SetAttributes(attribute.Int64("db.row_count", rowCount))
+	filename := attachmentFilename(formatName, dataset, ticker, table,
+		startTime.UTC().Format("20060102T150405Z"), endTime.UTC().Format("20060102T150405Z"))
+	c.Header("Content-Type", format.contentType)
+	c.Header("X-Row-Count", strconv.FormatInt(rowCount, 10))
+	c.FileAttachment(exportFile, filename)
+}
+
+// copyTableRangeFormatted copies at most limit rows of the table for the ticker and time range
+// to filename in the given format.  Returns the number of rows copied and an error, if any.
+func copyTableRangeFormatted(ctx context.Context, format exportFormat, filename string, table string, ticker string, startTime time.Time, endTime time.Time, limit int) (rowCount int64, err error) {
+	queryStr := fmt.Sprintf(`COPY (%s LIMIT ?)
+TO '%s' WITH (%s);`, exportTableQueries[table], filename, format.copyOptions)
+
+	// execute the command on global DuckDB connection
+	ctx, span := middleware.StartQuerySpan(ctx, "copy "+table, queryStr)
+	defer func() { middleware.EndQuerySpan(span, int(rowCount), err) }()
+	result, err := gDuckdbConn.ExecContext(ctx, queryStr, ticker, startTime.Unix(), endTime.Unix()+1, limit)
+	if err != nil {
+		return 0, fmt.Errorf("DuckDB query failed: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthConfig is the configuration of the /readyz probe
+type HealthConfig struct {
+	Staleness time.Duration `yaml:"staleness"` // Maximum time since a live session's last record, 0 disables the check
+}
+
+// DefaultHealthConfig is the /readyz configuration unless SetHealthConfig is called
+var DefaultHealthConfig = HealthConfig{
+	Staleness: 2 * time.Minute,
+}
+
+// Global storage of the /readyz configuration
+var (
+	gHealthMutex  sync.RWMutex
+	gHealthConfig = DefaultHealthConfig
+)
+
+const probeTimeout = 2 * time.Second // time DuckDB has to answer a probe
+
+// ReadyCheck is the result of a readiness check
+type ReadyCheck struct {
+	Name    string `json:"name"`              // "duckdb" or "live:" and the dataset
+	Ready   bool   `json:"ready"`             // True if the check passed
+	Message string `json:"message,omitempty"` // Reason the check failed
+}
+
+// ReadyResponse is the response of /readyz
+type ReadyResponse struct {
+	Ready  bool         `json:"ready"`  // True if every check passed
+	Checks []ReadyCheck `json:"checks"` // Checks of DuckDB and each live session
+}
+
+// SetHealthConfig sets the /readyz configuration
+func SetHealthConfig(config HealthConfig) {
+	gHealthMutex.Lock()
+	gHealthConfig = config
+	gHealthMutex.Unlock()
+}
+
+// getHealthConfig returns the /readyz configuration
+func getHealthConfig() HealthConfig {
+	gHealthMutex.RLock()
+	defer gHealthMutex.RUnlock()
+	return gHealthConfig
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// GetHealthz reports that the process is up, whatever the state of its data
+func GetHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GetReadyz reports whether the server can serve fresh data: DuckDB answers, and every
+// live session is authenticated and following, and has received a record within the
+// staleness window.  Responds 503 with the failed checks otherwise.
+func GetReadyz(c *gin.Context) {
+	config := getHealthConfig()
+	response := ReadyResponse{Ready: true}
+
+	dbCheck := ReadyCheck{Name: "duckdb", Ready: true}
+	if err := probeDuckDB(c.Request.Context()); err != nil {
+		dbCheck.Ready, dbCheck.Message = false, err.Error()
+	}
+	response.Checks = append(response.Checks, dbCheck)
+
+	now := time.Now()
+	for _, liveClient := range getLiveDataClients() {
+		status := liveClient.Status()
+		check := ReadyCheck{Name: "live:" + liveClient.Dataset(), Ready: true}
+		switch {
+		case !status.Following:
+			check.Ready, check.Message = false, "live session is not connected"
+		case config.Staleness <= 0:
+		case status.LastRecordTime.IsZero():
+			check.Ready, check.Message = false, "no data received yet"
+		case now.Sub(status.LastRecordTime) > config.Staleness:
+			check.Ready, check.Message = false, fmt.Sprintf("no data received for %s", now.Sub(status.LastRecordTime).Truncate(time.Second))
+		}
+		response.Checks = append(response.Checks, check)
+	}
+
+	statusCode := http.StatusOK
+	for _, check := range response.Checks {
+		if !check.Ready {
+			response.Ready = false
+			statusCode = http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(statusCode, response)
+}
+
+// probeDuckDB returns an error if DuckDB does not answer a query within probeTimeout
+func probeDuckDB(ctx context.Context) error {
+	if gDuckdbConn == nil {
+		return fmt.Errorf("no database")
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	var one int
+	if err := gDuckdbConn.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("database did not answer: %w", err)
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Returns the state of the server, its live datasets and its database.
+//
+//	@Summary		GET server status
+//	@ID				GetStatus
+//	@Description	Returns the server's build, the subscriptions of each live dataset with the time of its latest event and its lag from the server's clock, the row count of each table, and the size of the database file.
+//	@Produce		json
+//	@Success		200	{object}	sdk.Status "Status of the server"
+//	@Failure		500	{object}	error
+//	@Failure		504	{object}	error "database did not answer in time"
+//	@Security		ApiKeyAuth
+//	@Router			/status [get]
+func GetStatus(c *gin.Context) {
+	status := sdk.Status{
+		Version:  middleware.BuildVcsSha(),
+		Datasets: []sdk.DatasetStatus{},
+	}
+
+	now := time.Now()
+	for _, liveClient := range getLiveDataClients() {
+		if !middleware.DatasetAllowed(c, liveClient.Dataset()) {
+			continue
+		}
+		clientStatus := liveClient.Status()
+		datasetStatus := sdk.DatasetStatus{
+			Dataset:   liveClient.Dataset(),
+			Schemas:   liveClient.Schemas(),
+			Symbols:   liveClient.Subscriptions(),
+			Connected: clientStatus.Following,
+		}
+		if !clientStatus.LastRecordTime.IsZero() {
+			datasetStatus.LastRecordTime = clientStatus.LastRecordTime.Unix()
+		}
+		if !clientStatus.LastEventTime.IsZero() {
+			datasetStatus.LastEventTime = clientStatus.LastEventTime.UnixNano()
+			datasetStatus.LagSeconds = now.Sub(clientStatus.LastEventTime).Seconds()
+		}
+		status.Datasets = append(status.Datasets, datasetStatus)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), probeTimeout)
+	defer cancel()
+	tables, err := queryTableStatus(ctx, gDuckdbConn)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			middleware.GatewayTimeoutError(c, fmt.Errorf("database did not answer in %s", probeTimeout))
+			return
+		}
+		middleware.InternalError(c, "failed to count table rows", err)
+		return
+	}
+	status.Tables = tables
+
+	var dbFile sql.NullString
+	err = gDuckdbConn.QueryRowContext(ctx, "SELECT path FROM duckdb_databases() WHERE database_name = current_database()").Scan(&dbFile)
+	if err != nil {
+		middleware.InternalError(c, "failed to query database file", err)
+		return
+	}
+	if dbFile.Valid && dbFile.String != "" {
+		status.DBFile = dbFile.String
+		for _, path := range []string{dbFile.String, dbFile.String + ".wal"} {
+			if info, err := os.Stat(path); err == nil {
+				status.DBSize += info.Size()
+			}
+		}
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// queryTableStatus returns the row count of each table of the current database, sorted by name
+func queryTableStatus(ctx context.Context, conn *sql.DB) ([]sdk.TableStatus, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT table_name FROM duckdb_tables()
+WHERE database_name = current_database() AND schema_name = current_schema() AND NOT internal
+ORDER BY table_name`)
+	if err != nil {
+		return nil, err
+	}
+	tables := []sdk.TableStatus{}
+	for rows.Next() {
+		var table sdk.TableStatus
+		if err := rows.Scan(&table.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range tables {
+		query := fmt.Sprintf(`SELECT count(*) FROM "%s"`, tables[i].Name)
+		if err := conn.QueryRowContext(ctx, query).Scan(&tables[i].RowCount); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
@@ -0,0 +1,428 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+	"github.com/relvacode/iso8601"
+)
+
+const (
+	maxIndicators        = 12        // max indicators per request
+	maxIndicatorPeriod   = 1000      // max period of an indicator, in candles
+	indicatorPaneOverlay = "overlay" // IndicatorSeries.Pane of lines drawn over prices
+)
+
+// IndicatorSpec is a parsed technical indicator specification, e.g. "bb:20:2"
+type IndicatorSpec struct {
+	Spec   string    // Original specification
+	Kind   string    // Indicator kind: sma, ema, bb, rsi, macd or vwap
+	Params []float64 // Numeric parameters
+	Mode   string    // Non-numeric parameter, e.g. "session" for vwap
+}
+
+// Returns technical indicators computed over the candles of a Dataset and Ticker.
+//
+//	@Summary		Get technical indicators for a Dataset and Ticker
+//	@ID				GetIndicatorsByDatasetAndTicker
+//	@Description	Returns technical indicators computed over the candles of a Dataset and Ticker.
+//	@Description	Indicators are comma-separated: sma:N, ema:N, bb:N:K, rsi:N, macd:FAST:SLOW:SIGNAL, vwap:session
+//	@Produce		json
+//	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
+//	@Param			ticker path string	true	"symbol to query" example(AAPL)
+//	@Param			indicators query string	true	"comma-separated indicators" example(ema:20,bb:20:2,rsi:14)
+//	@Param			start query string	false	"(optional) start of date range in ISO8601. Default is midnight Eastern." Format(ISO8601)
+//	@Param			end query string	false	"(optional) end of date range in ISO8601. Default is now." Format(ISO8601)
+//	@Success		200	{object}	[]sdk.IndicatorSeries "array of IndicatorSeries"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error "dataset not found"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/indicators/{dataset}/{ticker} [get]
+func GetIndicatorsByDatasetAndTicker(c *gin.Context) {
+	ticker := c.Param("ticker")
+	if ticker == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":ticker cannot be empty"))
+		return
+	}
+
+	dataset := c.Param("dataset")
+	if dataset == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":dataset cannot be empty"))
+		return
+	}
+
+	specs, err := parseIndicators(c.Query("indicators"))
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+	if len(specs) == 0 {
+		middleware.BadRequestError(c, fmt.Errorf("'indicators' cannot be empty"))
+		return
+	}
+
+	startTime, endTime, err := extractParamsTimeRange(c)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+
+	candles, err := queryCandlesByDatasetAndTicker(c.Request.Context(), ticker, dataset, startTime, endTime)
+	if err != nil {
+		errorMsg := fmt.Sprintf("query error for ticker:%s dataset:%s", ticker, dataset)
+		middleware.InternalError(c, errorMsg, err)
+		return
+	}
+
+	series, err := computeIndicators(c.Request.Context(), specs, ticker, candles, startTime, endTime)
+	if err != nil {
+		errorMsg := fmt.Sprintf("indicator error for ticker:%s dataset:%s", ticker, dataset)
+		middleware.InternalError(c, errorMsg, err)
+		return
+	}
+	c.JSON(http.StatusOK, series)
+}
+
+// extractParamsTimeRange extracts the 'start' and 'end' query parameters as ISO8601 times.
+// The default range is from midnight Eastern until now.
+func extractParamsTimeRange(c *gin.Context) (startTime time.Time, endTime time.Time, err error) {
+	if startStr := c.Query("start"); startStr == "" {
+		year, month, day := middleware.NowEST().Date() // now in Eastern
+		startTime = time.Date(year, month, day, 0, 0, 0, 0, middleware.EasternLocation())
+	} else {
+		startTime, err = iso8601.ParseString(startStr)
+		if err != nil {
+			return startTime, endTime, fmt.Errorf("invalid 'start' date format: %s. %w", startStr, err)
+		}
+	}
+	if endStr := c.Query("end"); endStr == "" {
+		endTime = middleware.NowEST() // now in Eastern
+	} else {
+		endTime, err = iso8601.ParseString(endStr)
+		if err != nil {
+			return startTime, endTime, fmt.Errorf("invalid 'end' date format: %s. %w", endStr, err)
+		}
+	}
+	return startTime, endTime, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// parseIndicators parses a comma-separated list of indicator specifications,
+// e.g. "ema:20,sma:50,bb:20:2,rsi:14,macd:12:26:9,vwap:session".
+func parseIndicators(str string) ([]IndicatorSpec, error) {
+	var specs []IndicatorSpec
+	for _, specStr := range strings.Split(str, ",") {
+		specStr = strings.TrimSpace(specStr)
+		if specStr == "" {
+			continue
+		}
+		spec, err := parseIndicator(specStr)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(specs, func(s IndicatorSpec) bool { return s.name() == spec.name() }) {
+			continue // duplicate
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) > maxIndicators {
+		return nil, fmt.Errorf("too many indicators, max is %d", maxIndicators)
+	}
+	return specs, nil
+}
+
+// parseIndicator parses a single indicator specification, filling in default parameters.
+func parseIndicator(specStr string) (IndicatorSpec, error) {
+	parts := strings.Split(strings.ToLower(specStr), ":")
+	spec := IndicatorSpec{Spec: specStr, Kind: parts[0]}
+	args := parts[1:]
+
+	// numParams parses the numeric args, using defaults for missing trailing args
+	numParams := func(defaults []float64, required int) error {
+		if len(args) < required || len(args) > len(defaults) {
+			return fmt.Errorf("invalid indicator '%s': expected %d to %d parameters", specStr, required, len(defaults))
+		}
+		spec.Params = append([]float64(nil), defaults...)
+		for i, arg := range args {
+			val, err := strconv.ParseFloat(arg, 64)
+			if err != nil || val <= 0 {
+				return fmt.Errorf("invalid indicator '%s': parameter '%s' must be a positive number", specStr, arg)
+			}
+			spec.Params[i] = val
+		}
+		return nil
+	}
+
+	var err error
+	switch spec.Kind {
+	case "sma", "ema":
+		err = numParams([]float64{0}, 1)
+	case "rsi":
+		err = numParams([]float64{14}, 0)
+	case "bb":
+		err = numParams([]float64{20, 2}, 0)
+	case "macd":
+		err = numParams([]float64{12, 26, 9}, 0)
+		if err == nil && spec.Params[0] >= spec.Params[1] {
+			err = fmt.Errorf("invalid indicator '%s': fast period must be less than slow period", specStr)
+		}
+	case "vwap":
+		spec.Mode = "session"
+		if len(args) > 1 || (len(args) == 1 && args[0] != "session") {
+			err = fmt.Errorf("invalid indicator '%s': only 'vwap:session' is supported", specStr)
+		}
+	default:
+		err = fmt.Errorf("invalid indicator '%s': unknown kind '%s'", specStr, spec.Kind)
+	}
+	if err != nil {
+		return spec, err
+	}
+
+	// periods are whole numbers of candles; the Bollinger width K may be fractional
+	for i, param := range spec.Params {
+		if spec.Kind == "bb" && i == 1 {
+			continue
+		}
+		if param != math.Trunc(param) || param > maxIndicatorPeriod {
+			return spec, fmt.Errorf("invalid indicator '%s': periods must be whole numbers up to %d", specStr, maxIndicatorPeriod)
+		}
+	}
+	return spec, nil
+}
+
+// name returns the spec as a field name, e.g. "bb_20_2"
+func (spec IndicatorSpec) name() string {
+	name := spec.Kind
+	for _, param := range spec.Params {
+		name += "_" + strconv.FormatFloat(param, 'f', -1, 64)
+	}
+	if spec.Mode != "" {
+		name += "_" + spec.Mode
+	}
+	return strings.ReplaceAll(name, ".", "p")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// computeIndicators computes the indicators over the candles.
+// vwap:session is computed in DuckDB from the same time range; the rest are computed here.
+func computeIndicators(ctx context.Context, specs []IndicatorSpec, ticker string, candles []*sdk.Candle, startTime time.Time, endTime time.Time) ([]*sdk.IndicatorSeries, error) {
+	closes := make([]float64, len(candles))
+	for i, candle := range candles {
+		closes[i] = candle.Close
+	}
+
+	var series []*sdk.IndicatorSeries
+	addSeries := func(spec IndicatorSpec, line string, pane string, values []float64) {
+		name := spec.name()
+		if line != "" {
+			name += "_" + line
+		}
+		s := &sdk.IndicatorSeries{Name: name, Indicator: spec.Spec, Line: line, Pane: pane, Points: []sdk.IndicatorPoint{}}
+		for i, val := range values {
+			if !math.IsNaN(val) {
+				s.Points = append(s.Points, sdk.IndicatorPoint{Timestamp: candles[i].Timestamp, Value: val})
+			}
+		}
+		series = append(series, s)
+	}
+
+	for _, spec := range specs {
+		switch spec.Kind {
+		case "sma":
+			addSeries(spec, "", indicatorPaneOverlay, computeSMA(closes, int(spec.Params[0])))
+		case "ema":
+			addSeries(spec, "", indicatorPaneOverlay, computeEMA(closes, int(spec.Params[0])))
+		case "bb":
+			upper, middle, lower := computeBollinger(closes, int(spec.Params[0]), spec.Params[1])
+			addSeries(spec, "upper", indicatorPaneOverlay, upper)
+			addSeries(spec, "middle", indicatorPaneOverlay, middle)
+			addSeries(spec, "lower", indicatorPaneOverlay, lower)
+		case "rsi":
+			addSeries(spec, "", spec.name(), computeRSI(closes, int(spec.Params[0])))
+		case "macd":
+			macd, signal, hist := computeMACD(closes, int(spec.Params[0]), int(spec.Params[1]), int(spec.Params[2]))
+			addSeries(spec, "", spec.name(), macd)
+			addSeries(spec, "signal", spec.name(), signal)
+			addSeries(spec, "hist", spec.name(), hist)
+		case "vwap":
+			vwap, err := querySessionVwap(ctx, ticker, candles, startTime, endTime)
+			if err != nil {
+				return nil, err
+			}
+			addSeries(spec, "", indicatorPaneOverlay, vwap)
+		}
+	}
+	return series, nil
+}
+
+// querySessionVwap returns the session VWAP at each candle, from the candles' typical prices.
+// The session is the candle's Eastern trading date, not the UTC date stored with it.
+func querySessionVwap(ctx context.Context, ticker string, candles []*sdk.Candle, startTime time.Time, endTime time.Time) (values []float64, err error) {
+	queryStr := `SELECT timestamp,
+  SUM((CAST(high AS DOUBLE) + CAST(low AS DOUBLE) + CAST(close AS DOUBLE)) / 3 * volume) OVER session
+    / NULLIF(SUM(volume) OVER session, 0) AS vwap
+FROM (
+  SELECT *, CAST(timezone(?, to_timestamp(timestamp)) AS DATE) AS session_date
+  FROM candles
+  WHERE ticker = ? AND timestamp BETWEEN ? AND ?
+)
+WINDOW session AS (PARTITION BY session_date ORDER BY timestamp ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)
+ORDER BY timestamp;`
+	var rowCount int
+	ctx, span := middleware.StartQuerySpan(ctx, "query session vwap", queryStr)
+	defer func() { middleware.EndQuerySpan(span, rowCount, err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, queryStr, middleware.EasternLocation().String(),
+		ticker, startTime.Unix(), endTime.Unix()+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vwapByTimestamp := make(map[int64]float64, len(candles))
+	for rows.Next() {
+		rowCount++
+		var timestamp int64
+		var vwap *float64
+		if err := rows.Scan(&timestamp, &vwap); err != nil {
+			return nil, err
+		}
+		if vwap != nil {
+			vwapByTimestamp[timestamp] = *vwap
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	values = make([]float64, len(candles))
+	for i, candle := range candles {
+		vwap, ok := vwapByTimestamp[candle.Timestamp]
+		if !ok {
+			vwap = math.NaN()
+		}
+		values[i] = vwap
+	}
+	return values, nil
+}
+
+// nanSlice returns a slice of n NaNs
+func nanSlice(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	return values
+}
+
+// computeSMA returns the simple moving average of period n; NaN during warm-up
+func computeSMA(values []float64, n int) []float64 {
+	out := nanSlice(len(values))
+	sum := 0.0
+	for i, val := range values {
+		sum += val
+		if i >= n {
+			sum -= values[i-n]
+		}
+		if i >= n-1 {
+			out[i] = sum / float64(n)
+		}
+	}
+	return out
+}
+
+// computeEMA returns the exponential moving average of period n, seeded with the SMA
+// of the first n non-NaN values; NaN during warm-up
+func computeEMA(values []float64, n int) []float64 {
+	out := nanSlice(len(values))
+	alpha := 2.0 / float64(n+1)
+	count, sum, ema := 0, 0.0, 0.0
+	for i, val := range values {
+		if math.IsNaN(val) {
+			continue
+		}
+		count++
+		switch {
+		case count < n:
+			sum += val
+		case count == n:
+			sum += val
+			ema = sum / float64(n)
+			out[i] = ema
+		default:
+			ema = alpha*val + (1-alpha)*ema
+			out[i] = ema
+		}
+	}
+	return out
+}
+
+// computeBollinger returns the Bollinger Bands of period n and width k standard deviations
+func computeBollinger(values []float64, n int, k float64) (upper []float64, middle []float64, lower []float64) {
+	middle = computeSMA(values, n)
+	upper, lower = nanSlice(len(values)), nanSlice(len(values))
+	for i := n - 1; i < len(values); i++ {
+		variance := 0.0
+		for _, val := range values[i-n+1 : i+1] {
+			variance += (val - middle[i]) * (val - middle[i])
+		}
+		stddev := math.Sqrt(variance / float64(n))
+		upper[i] = middle[i] + k*stddev
+		lower[i] = middle[i] - k*stddev
+	}
+	return upper, middle, lower
+}
+
+// computeRSI returns the Relative Strength Index of period n, with Wilder's smoothing
+func computeRSI(values []float64, n int) []float64 {
+	out := nanSlice(len(values))
+	var avgGain, avgLoss float64
+	for i := 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain, loss := math.Max(change, 0), math.Max(-change, 0)
+		if i <= n {
+			avgGain += gain / float64(n)
+			avgLoss += loss / float64(n)
+			if i < n {
+				continue
+			}
+		} else {
+			avgGain = (avgGain*float64(n-1) + gain) / float64(n)
+			avgLoss = (avgLoss*float64(n-1) + loss) / float64(n)
+		}
+		if avgLoss == 0 {
+			out[i] = 100
+		} else {
+			out[i] = 100 - 100/(1+avgGain/avgLoss)
+		}
+	}
+	return out
+}
+
+// computeMACD returns the MACD line, its signal line and their difference histogram
+func computeMACD(values []float64, fast int, slow int, signalPeriod int) (macd []float64, signal []float64, hist []float64) {
+	fastEMA, slowEMA := computeEMA(values, fast), computeEMA(values, slow)
+	macd = nanSlice(len(values))
+	for i := range values {
+		macd[i] = fastEMA[i] - slowEMA[i] // NaN until both are warm
+	}
+	signal = computeEMA(macd, signalPeriod)
+	hist = nanSlice(len(values))
+	for i := range values {
+		hist[i] = macd[i] - signal[i]
+	}
+	return macd, signal, hist
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2025 Neomantra Corp
+// get_indicators_test.go
+//
+// Tests of the technical indicator math against known values, and of the session VWAP's sessions.
+
+package handlers
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+)
+
+// nan marks a warm-up value in expected series
+var nan = math.NaN()
+
+// wilderCloses are the closes of the RSI example of Wilder and StockCharts
+var wilderCloses = []float64{
+	44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+	45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64,
+}
+
+// assertSeries fails the test if got does not match want within tolerance; NaNs must match NaNs
+func assertSeries(t *testing.T, name string, got []float64, want []float64, tolerance float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d values, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if math.IsNaN(want[i]) != math.IsNaN(got[i]) || math.Abs(got[i]-want[i]) > tolerance {
+			t.Fatalf("%s[%d]: got %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestComputeSMA(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		n      int
+		want   []float64
+	}{
+		{"period 3", []float64{1, 2, 3, 4, 5, 6}, 3, []float64{nan, nan, 2, 3, 4, 5}},
+		{"period 1", []float64{3, 1, 4}, 1, []float64{3, 1, 4}},
+		{"uneven", []float64{2, 4, 9, 1}, 2, []float64{nan, 3, 6.5, 5}},
+		{"too short", []float64{1, 2}, 3, []float64{nan, nan}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSeries(t, "sma", computeSMA(tt.values, tt.n), tt.want, 1e-9)
+		})
+	}
+}
+
+func TestComputeEMA(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		n      int
+		want   []float64
+	}{
+		// alpha is 0.5, seeded with the SMA of the first 3 values
+		{"period 3", []float64{2, 4, 6, 8, 12}, 3, []float64{nan, nan, 4, 6, 9}},
+		// leading NaNs are skipped, as for the MACD signal line
+		{"leading NaNs", []float64{nan, 2, 4, 6, 8}, 3, []float64{nan, nan, nan, 4, 6}},
+		{"too short", []float64{1, 2}, 3, []float64{nan, nan}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSeries(t, "ema", computeEMA(tt.values, tt.n), tt.want, 1e-9)
+		})
+	}
+}
+
+func TestComputeBollinger(t *testing.T) {
+	tests := []struct {
+		name                 string
+		values               []float64
+		n                    int
+		k                    float64
+		upper, middle, lower []float64
+	}{
+		// mean 5, population standard deviation 2
+		{"population stddev", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2,
+			[]float64{nan, nan, nan, nan, nan, nan, nan, 9},
+			[]float64{nan, nan, nan, nan, nan, nan, nan, 5},
+			[]float64{nan, nan, nan, nan, nan, nan, nan, 1}},
+		{"flat", []float64{3, 3, 3}, 2, 2,
+			[]float64{nan, 3, 3}, []float64{nan, 3, 3}, []float64{nan, 3, 3}},
+		{"rolling", []float64{1, 3, 5}, 2, 1,
+			[]float64{nan, 3, 5}, []float64{nan, 2, 4}, []float64{nan, 1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upper, middle, lower := computeBollinger(tt.values, tt.n, tt.k)
+			assertSeries(t, "upper", upper, tt.upper, 1e-9)
+			assertSeries(t, "middle", middle, tt.middle, 1e-9)
+			assertSeries(t, "lower", lower, tt.lower, 1e-9)
+		})
+	}
+}
+
+func TestComputeRSI(t *testing.T) {
+	warmup := []float64{nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan}
+	tests := []struct {
+		name      string
+		values    []float64
+		n         int
+		want      []float64
+		tolerance float64
+	}{
+		// StockCharts publishes 70.53, 66.32, ... from rounded averages; these are unrounded
+		{"wilder", wilderCloses, 14, append(warmup, 70.4641, 66.2496, 66.4809, 69.3469, 66.2947, 57.9150), 1e-4},
+		{"wilder smoothing", []float64{1, 2, 3, 2}, 2, []float64{nan, nan, 100, 50}, 1e-9},
+		{"no losses", []float64{1, 2, 3, 4}, 2, []float64{nan, nan, 100, 100}, 1e-9},
+		{"no gains", []float64{4, 3, 2, 1}, 2, []float64{nan, nan, 0, 0}, 1e-9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSeries(t, "rsi", computeRSI(tt.values, tt.n), tt.want, tt.tolerance)
+		})
+	}
+}
+
+func TestComputeMACD(t *testing.T) {
+	tests := []struct {
+		name                 string
+		values               []float64
+		fast, slow, signal   int
+		wantMACD, wantSignal []float64
+		wantHist             []float64
+	}{
+		// a linear series has a constant MACD once both EMAs are warm
+		{"linear", []float64{1, 2, 3, 4, 5, 6}, 2, 3, 2,
+			[]float64{nan, nan, 0.5, 0.5, 0.5, 0.5},
+			[]float64{nan, nan, nan, 0.5, 0.5, 0.5},
+			[]float64{nan, nan, nan, 0, 0, 0}},
+		{"turning", []float64{1, 3, 5, 3, 1}, 2, 3, 2,
+			[]float64{nan, nan, 1, 1.0 / 3, -2.0 / 9},
+			[]float64{nan, nan, nan, 2.0 / 3, 2.0 / 27},
+			[]float64{nan, nan, nan, -1.0 / 3, -8.0 / 27}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			macd, signal, hist := computeMACD(tt.values, tt.fast, tt.slow, tt.signal)
+			assertSeries(t, "macd", macd, tt.wantMACD, 1e-9)
+			assertSeries(t, "signal", signal, tt.wantSignal, 1e-9)
+			assertSeries(t, "hist", hist, tt.wantHist, 1e-9)
+		})
+	}
+}
+
+func TestQuerySessionVwapEasternSession(t *testing.T) {
+	setTestReplayDB(t, time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC))
+	if _, err := gDuckdbConn.Exec(`DELETE FROM candles;`); err != nil {
+		t.Fatalf("failed to reset candles: %s", err)
+	}
+
+	// the evening candle of the 2nd is on the next UTC date, but in the session of the 2nd
+	var candles []*sdk.Candle
+	for _, candle := range []struct {
+		date  string
+		ts    time.Time
+		price float64
+	}{
+		{"2025-01-02", time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC), 100}, // 10:00 Eastern on the 2nd
+		{"2025-01-03", time.Date(2025, 1, 3, 1, 0, 0, 0, time.UTC), 200},  // 20:00 Eastern on the 2nd
+		{"2025-01-03", time.Date(2025, 1, 3, 15, 0, 0, 0, time.UTC), 400}, // 10:00 Eastern on the 3rd
+	} {
+		if _, err := gDuckdbConn.Exec(`INSERT INTO candles VALUES (?, ?, 0, 1, 'AAPL', ?, ?, ?, ?, 10);`,
+			candle.date, candle.ts.Unix(), candle.price, candle.price, candle.price, candle.price); err != nil {
+			t.Fatalf("failed to insert candle: %s", err)
+		}
+		candles = append(candles, &sdk.Candle{Timestamp: candle.ts.Unix()})
+	}
+
+	start := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	vwap, err := querySessionVwap(context.Background(), "AAPL", candles, start, start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("querySessionVwap: %s", err)
+	}
+	assertSeries(t, "vwap", vwap, []float64{100, 150, 400}, 1e-9)
+}
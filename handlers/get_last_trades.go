@@ -4,9 +4,9 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
 	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
@@ -15,18 +15,26 @@ import (
 
 const defaultCountArg = 25
 
+// lastTradesQuery selects the trades of a ticker, limited to a count
+const lastTradesQuery = `SELECT timestamp, nanos, publisher, ticker, CAST(price AS DOUBLE) AS price, shares FROM trades
+WHERE ticker = ? ORDER BY timestamp LIMIT ?;`
+
 // Returns the last N trades by Dataset and Ticker.
 //
 //	@Summary		GET last N trades by market and ticker
 //	@ID				GetLastTradesByDatasetAndTicker
 //	@Description	Returns the last N trades by dataset and ticker.
+//	@Description	Requests with 'Accept: application/vnd.apache.arrow.stream' get an Arrow IPC stream.
 //	@Produce		json
+//	@Produce		application/vnd.apache.arrow.stream
 //	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
 //	@Param			ticker path string	true	"symbol to query" example(AAPL)
 //	@Param			count query integer	false	"(optional) number of trades to return - default is 25"
 //	@Success		200	{object}	[]sdk.TradeTick "array of TradeTicks"
 //	@Failure		404	{object}	error "dataset not found"
+//	@Failure		406	{object}	error "Arrow not supported by this build"
 //	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
 //	@Router			/last-trades/json/{dataset}/{ticker} [get]
 func GetLastTradesByDatasetAndTicker(c *gin.Context) {
 	// extract parameters
@@ -36,8 +44,15 @@ func GetLastTradesByDatasetAndTicker(c *gin.Context) {
 		return
 	}
 
+	// stream Arrow straight from DuckDB if requested
+	if acceptsArrowStream(c) {
+		errorMsg := fmt.Sprintf("query error for ticker:%s dataset:%s", ticker, dataset)
+		respondArrowStream(c, errorMsg, lastTradesQuery, ticker, count)
+		return
+	}
+
 	// perform the query
-	results, err := queryLastTradesByDatasetAndTicker(ticker, dataset, count)
+	results, err := queryLastTradesByDatasetAndTicker(c.Request.Context(), ticker, dataset, count)
 	if err != nil {
 		errorMsg := fmt.Sprintf("query error for ticker:%s dataset:%s", ticker, dataset)
 		middleware.InternalError(c, errorMsg, err)
@@ -61,6 +76,7 @@ func GetLastTradesByDatasetAndTicker(c *gin.Context) {
 //	@Success		200	string      string "CSV file with last N trades"
 //	@Failure		404	{object}	error "dataset not found"
 //	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
 //	@Router			/last-trades/csv/{dataset}/{ticker} [get]
 func GetLastTradesByDatasetAndTickerCSV(c *gin.Context) {
 	// Extract parameters
@@ -70,22 +86,22 @@ func GetLastTradesByDatasetAndTickerCSV(c *gin.Context) {
 		return
 	}
 
+	// Reserve an export file, deleted after transmitting
+	exportFile, release, ok := acquireExportFile(c, "trades-*.csv")
+	if !ok {
+		return
+	}
+	defer release()
+
 	// Perform the query
-	tempFile, err := copyLastTradesByDatasetAndTickerFormatted("csv", ticker, dataset, count)
+	err = copyLastTradesByDatasetAndTickerFormatted(c.Request.Context(), "csv", exportFile, ticker, dataset, count)
 	if err != nil {
 		errorMsg := fmt.Sprintf("query error for ticker:%s dataset:%s", ticker, dataset)
 		middleware.InternalError(c, errorMsg, err)
 		return
 	}
 	c.Header("Content-Type", "text/csv")
-	c.File(tempFile)
-
-	// Delete the temporary file
-	err = os.Remove(tempFile)
-	if err != nil {
-		c.Error(fmt.Errorf("failed to remove temp file: %s %w", tempFile, err))
-		// this does not error-out the request though
-	}
+	c.File(exportFile)
 }
 
 // Returns Excel file with last N trades by Dataset and Ticker
@@ -100,6 +116,7 @@ func GetLastTradesByDatasetAndTickerCSV(c *gin.Context) {
 //	@Success		200	string      string "Excel file with last N trades"
 //	@Failure		404	{object}	error "dataset not found"
 //	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
 //	@Router			/last-trades/excel/{dataset}/{ticker} [get]
 func GetLastTradesByDatasetAndTickerExcel(c *gin.Context) {
 	// Extract parameters
@@ -109,8 +126,15 @@ func GetLastTradesByDatasetAndTickerExcel(c *gin.Context) {
 		return
 	}
 
+	// Reserve an export file, deleted after transmitting
+	exportFile, release, ok := acquireExportFile(c, "trades-*.xlsx")
+	if !ok {
+		return
+	}
+	defer release()
+
 	// Perform the query
-	tempFile, err := copyLastTradesByDatasetAndTickerFormatted("xlsx", ticker, dataset, count)
+	err = copyLastTradesByDatasetAndTickerFormatted(c.Request.Context(), "xlsx", exportFile, ticker, dataset, count)
 	if err != nil {
 		errorMsg := fmt.Sprintf("query error for ticker:%s dataset:%s", ticker, dataset)
 		middleware.InternalError(c, errorMsg, err)
@@ -119,14 +143,27 @@ func GetLastTradesByDatasetAndTickerExcel(c *gin.Context) {
 
 	// Transmit the file
 	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.File(tempFile)
+	c.File(exportFile)
+}
 
-	// Delete the temporary file
-	err = os.Remove(tempFile)
+// acquireExportFile reserves a file in the export scratch directory.
+// On failure it responds to the request and returns false.
+func acquireExportFile(c *gin.Context, pattern string) (filename string, release func(), ok bool) {
+	scratchDir, err := getScratchDir()
 	if err != nil {
-		c.Error(fmt.Errorf("failed to remove temp file: %s %w", tempFile, err))
-		// this does not error-out the request though
+		middleware.InternalError(c, "export unavailable", err)
+		return "", nil, false
+	}
+	filename, release, err = scratchDir.Acquire(c.Request.Context(), pattern)
+	if err != nil {
+		if errors.Is(err, middleware.ErrScratchBusy) {
+			middleware.ServiceUnavailableError(c, err, ScratchAcquireTimeout)
+		} else {
+			middleware.InternalError(c, "export unavailable", err)
+		}
+		return "", nil, false
 	}
+	return filename, release, true
 }
 
 // extractParamsTickerDatasetCount extracts the ticker, dataset, and count from the Param's request context.
@@ -156,21 +193,19 @@ func extractParamsTickerDatasetCount(c *gin.Context) (ticker string, dataset str
 }
 
 // queryLastTradesByDatasetAndTicker selects the trades from the database and returns it as an array of TradeTicks.
-func queryLastTradesByDatasetAndTicker(ticker string, dataset string, count int) ([]*sdk.TradeTick, error) {
+func queryLastTradesByDatasetAndTicker(ctx context.Context, ticker string, dataset string, count int) (ticks []*sdk.TradeTick, err error) {
 	if count <= 0 {
 		count = defaultCountArg
 	}
-	queryStr := `SELECT timestamp, nanos, publisher, ticker, CAST(price AS DOUBLE) AS price, shares FROM trades
-WHERE ticker = ? ORDER BY timestamp LIMIT ?;`
-
 	// query the global DuckDB connection
-	rows, err := gDuckdbConn.QueryContext(context.Background(), queryStr, ticker, count)
+	ctx, span := middleware.StartQuerySpan(ctx, "query last trades", lastTradesQuery)
+	defer func() { middleware.EndQuerySpan(span, len(ticks), err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, lastTradesQuery, ticker, count)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var ticks []*sdk.TradeTick
 	for rows.Next() {
 		tick := new(sdk.TradeTick)
 		err := rows.Scan(&tick.Timestamp, &tick.Nanos, &tick.PublisherID, &tick.Ticker, &tick.Price, &tick.Shares)
@@ -182,28 +217,25 @@ WHERE ticker = ? ORDER BY timestamp LIMIT ?;`
 	return ticks, nil
 }
 
-// copyLastTradesByDatasetAndTickerFormatted selects the trades from the database and copies it to the given format.
-// Returns the temporary filename, or an error if any. It is the caller's responsibility to delete the file.
-func copyLastTradesByDatasetAndTickerFormatted(format string, ticker string, dataset string, count int) (string, error) {
-	// Grab a temporary file for destination
-	tempFile, err := os.CreateTemp("", fmt.Sprintf("trades-*.%s", format))
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
-	}
-
+// copyLastTradesByDatasetAndTickerFormatted selects the trades from the database and copies it
+// to the given format in filename.  Returns an error, if any.
+func copyLastTradesByDatasetAndTickerFormatted(ctx context.Context, format string, filename string, ticker string, dataset string, count int) error {
 	// Perform the query
 	if count <= 0 {
 		count = defaultCountArg
 	}
 	queryStr := fmt.Sprintf(`COPY (SELECT MAKE_TIMESTAMP(CAST(timestamp AS BIGINT)*1_000_000) AS time, publisher, ticker, CAST(price AS DOUBLE) AS price, shares FROM trades
 WHERE ticker = ? ORDER BY timestamp LIMIT ?)
-TO '%s' WITH (FORMAT %s, HEADER true);`, tempFile.Name(), format)
+TO '%s' WITH (FORMAT %s, HEADER true);`, filename, format)
 
 	// execute the command on global DuckDB connection
-	_, err = gDuckdbConn.ExecContext(context.Background(), queryStr, ticker, count)
+	ctx, span := middleware.StartQuerySpan(ctx, "copy last trades", queryStr)
+	result, err := gDuckdbConn.ExecContext(ctx, queryStr, ticker, count)
 	if err != nil {
-		return "", fmt.Errorf("DuckDB query failed: %w", err)
+		middleware.EndQuerySpan(span, 0, err)
+		return fmt.Errorf("DuckDB query failed: %w", err)
 	}
-
-	return tempFile.Name(), nil
+	rowCount, _ := result.RowsAffected()
+	middleware.EndQuerySpan(span, int(rowCount), nil)
+	return nil
 }
@@ -14,19 +14,29 @@ import (
 	"github.com/relvacode/iso8601"
 )
 
+// candlesQuery selects the candles of a ticker in a timestamp range
+const candlesQuery = `SELECT timestamp, nanos, publisher, ticker, volume,
+CAST(open AS DOUBLE) AS open, CAST(high AS DOUBLE) AS high, CAST(low AS DOUBLE) AS low, CAST(close AS DOUBLE) AS close
+FROM candles
+WHERE ticker = ? AND timestamp BETWEEN ? AND ? ORDER BY timestamp;`
+
 // Get a time range of OHLCV for a Dataset and Ticker
 //
 //	@Summary		Get a time range of OHLCV for a Dataset and Ticker
 //	@ID				GetOhlcvByDatasetAndTicker
-//	@Description	Returns a time range of OHLCV for a Dataset and Ticker
+//	@Description	Returns a time range of OHLCV for a Dataset and Ticker.
+//	@Description	Requests with 'Accept: application/vnd.apache.arrow.stream' get an Arrow IPC stream.
 //	@Produce		json
+//	@Produce		application/vnd.apache.arrow.stream
 //	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
 //	@Param			ticker path string	true	"symbol to query" example(AAPL)
 //	@Param			start query string	false	"(optional) start of date range in ISO8601. Default is midnight Eastern." Format(ISO8601)
 //	@Param			end query string	false	"(optional) end of date range in ISO8601. Default is now." Format(ISO8601)
 //	@Success		200	{object}	[]sdk.Candle "array of Candles"
 //	@Failure		404	{object}	error "dataset not found"
+//	@Failure		406	{object}	error "Arrow not supported by this build"
 //	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
 //	@Router			/candles/{dataset}/{ticker} [get]
 func GetOhlcvByDatasetAndTicker(c *gin.Context) {
 	ticker := c.Param("ticker")
@@ -63,8 +73,15 @@ func GetOhlcvByDatasetAndTicker(c *gin.Context) {
 		}
 	}
 
+	// stream Arrow straight from DuckDB if requested
+	if acceptsArrowStream(c) {
+		errorMsg := fmt.Sprintf("query error for ticker:%s dataset:%s", ticker, dataset)
+		respondArrowStream(c, errorMsg, candlesQuery, ticker, startTime.Unix(), endTime.Unix()+1)
+		return
+	}
+
 	// query for candlesticks
-	candles, err := queryCandlesByDatasetAndTicker(ticker, dataset, startTime, endTime)
+	candles, err := queryCandlesByDatasetAndTicker(c.Request.Context(), ticker, dataset, startTime, endTime)
 	if err != nil {
 		errorMsg := fmt.Sprintf("query error for ticker:%s dataset:%s", ticker, dataset)
 		middleware.InternalError(c, errorMsg, err)
@@ -78,18 +95,15 @@ func GetOhlcvByDatasetAndTicker(c *gin.Context) {
 }
 
 // queryCandlesByDatasetAndTicker selects the candles from the database
-func queryCandlesByDatasetAndTicker(ticker string, dataset string, startTime time.Time, endTime time.Time) ([]*sdk.Candle, error) {
-	queryStr := `SELECT timestamp, nanos, publisher, ticker, volume,
-CAST(open AS DOUBLE), CAST(high AS DOUBLE), CAST(low AS DOUBLE), CAST(close AS DOUBLE)
-FROM candles
-WHERE ticker = ? AND timestamp BETWEEN ? AND ? ORDER BY timestamp;`
-	rows, err := gDuckdbConn.QueryContext(context.Background(), queryStr, ticker, startTime.Unix(), endTime.Unix()+1)
+func queryCandlesByDatasetAndTicker(ctx context.Context, ticker string, dataset string, startTime time.Time, endTime time.Time) (candles []*sdk.Candle, err error) {
+	ctx, span := middleware.StartQuerySpan(ctx, "query candles", candlesQuery)
+	defer func() { middleware.EndQuerySpan(span, len(candles), err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, candlesQuery, ticker, startTime.Unix(), endTime.Unix()+1)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var candles []*sdk.Candle
 	for rows.Next() {
 		candle := new(sdk.Candle)
 		err := rows.Scan(&candle.Timestamp, &candle.Nanos, &candle.PublisherID, &candle.Ticker, &candle.Volume,
@@ -99,5 +113,5 @@ WHERE ticker = ? AND timestamp BETWEEN ? AND ? ORDER BY timestamp;`
 		}
 		candles = append(candles, candle)
 	}
-	return candles, nil
+	return candles, rows.Err()
 }
@@ -0,0 +1,416 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStreamSchemas   = livedata.SchemaTrades + "," + livedata.SchemaOhlcv1m
+	streamBufferSize       = 1024             // events buffered per stream before it is dropped
+	streamReplayPageSize   = 10_000           // max rows per schema queried for each page of a replay
+	streamKeepaliveTimeout = 15 * time.Second // interval of SSE keepalive comments
+)
+
+// Streams trades and candles for a Dataset and Ticker as Server-Sent Events.
+//
+//	@Summary		Stream trades and candles for a Dataset and Ticker as Server-Sent Events
+//	@ID				GetStreamByDatasetAndTicker
+//	@Description	Streams live trades and candles for a Dataset and Ticker as Server-Sent Events.
+//	@Description	Each event's type is its schema and its data is an sdk.TradeTick or sdk.Candle as JSON.
+//	@Description	The event ID is a cursor of the last trades and candle ts_event nanoseconds, as '<trades>-<ohlcv-1m>'.
+//	@Description	Events sharing a ts_event are numbered from 0 in ingestion order, which follows a ts_event as '.<n>' if not 0.
+//	@Description	Clients reconnecting with a Last-Event-ID header first receive all the events they missed from DuckDB, in order.
+//	@Produce		text/event-stream
+//	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
+//	@Param			ticker path string	true	"symbol to stream" example(AAPL)
+//	@Param			schemas query string	false	"(optional) comma-separated schemas to stream - default is 'trades,ohlcv-1m'"
+//	@Param			Last-Event-ID header string	false	"(optional) ID of the last event received; missed events are replayed"
+//	@Param			lastEventId query string	false	"(optional) same as the Last-Event-ID header, which takes precedence"
+//	@Success		200	{object}	string "stream of Server-Sent Events"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error "dataset not found"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream/{dataset}/{ticker} [get]
+func GetStreamByDatasetAndTicker(c *gin.Context) {
+	ticker := c.Param("ticker")
+	if ticker == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":ticker cannot be empty"))
+		return
+	}
+
+	dataset := c.Param("dataset")
+	if dataset == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":dataset cannot be empty"))
+		return
+	}
+
+	schemas, err := parseStreamSchemas(c.DefaultQuery("schemas", defaultStreamSchemas))
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+
+	lastEventStr := c.GetHeader("Last-Event-ID")
+	if lastEventStr == "" {
+		lastEventStr = c.Query("lastEventId") // EventSource cannot set headers on its first request
+	}
+	cursor, err := parseStreamCursor(lastEventStr)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+
+	liveClient := getLiveDataClient(dataset)
+	if liveClient == nil {
+		middleware.NotFoundError(c, fmt.Errorf("dataset not found: %s", dataset))
+		return
+	}
+
+	// Subscribe before replaying so nothing is missed in between
+	broker := liveClient.Broker()
+	sub := broker.Subscribe(streamBufferSize, func(ev *livedata.Event) bool {
+		return ev.Ticker == ticker && slices.Contains(schemas, ev.Schema)
+	})
+	defer broker.Unsubscribe(sub)
+
+	// The first page of the replay is queried before responding, so its errors get a status
+	ctx := c.Request.Context()
+	var replay []livedata.Event
+	moreReplay := false
+	if cursor.isSet() {
+		replay, moreReplay, err = queryStreamReplay(ctx, dataset, ticker, schemas, cursor, streamReplayPageSize)
+		if err != nil {
+			errorMsg := fmt.Sprintf("replay query error for ticker:%s dataset:%s", ticker, dataset)
+			middleware.InternalError(c, errorMsg, err)
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering
+	c.Status(http.StatusOK)
+
+	keepalive := time.NewTicker(streamKeepaliveTimeout)
+	defer keepalive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		// Send the missed events first, a page at a time, then switch to live
+		if len(replay) != 0 || moreReplay {
+			for _, ev := range replay {
+				cursor.advance(&ev)
+				if err := writeStreamEvent(w, &ev, cursor); err != nil {
+					return false
+				}
+			}
+			replay = nil
+			if moreReplay {
+				var err error
+				replay, moreReplay, err = queryStreamReplay(ctx, dataset, ticker, schemas, cursor, streamReplayPageSize)
+				if err != nil {
+					middleware.GetGinLogger(c).Warn("stream replay failed",
+						zap.String("dataset", dataset), zap.String("ticker", ticker), zap.Error(err))
+					return false // the client resumes from its last event ID
+				}
+			}
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-gStreamsClosed:
+			return false
+		case ev, ok := <-sub.C:
+			if !ok {
+				if err := sub.Err(); err != nil {
+					middleware.GetGinLogger(c).Warn("stream dropped",
+						zap.String("dataset", dataset), zap.String("ticker", ticker), zap.Error(err))
+				}
+				return false
+			}
+			if !cursor.advance(&ev) {
+				return true // already sent during replay
+			}
+			return writeStreamEvent(w, &ev, cursor) == nil
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		}
+	})
+}
+
+// parseStreamSchemas parses and validates a comma-separated list of schemas.
+func parseStreamSchemas(schemasStr string) ([]string, error) {
+	var schemas []string
+	for _, schema := range strings.Split(schemasStr, ",") {
+		schema = strings.TrimSpace(schema)
+		switch schema {
+		case "":
+			continue
+		case livedata.SchemaTrades, livedata.SchemaOhlcv1m:
+			schemas = append(schemas, schema)
+		default:
+			return nil, fmt.Errorf("invalid 'schemas' value: %s", schema)
+		}
+	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("'schemas' cannot be empty")
+	}
+	return schemas, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// streamCursor is the position of a stream: the last event sent for trades and candles.
+// Candles are published when their interval closes, after later trades, so each schema
+// needs its own position.  It is sent as the SSE event ID in the form "<trades>-<ohlcv-1m>".
+type streamCursor struct {
+	trades  streamPosition
+	candles streamPosition
+}
+
+// streamPosition is the ts_event and Seq of the last event sent of a schema.
+// Seq orders the events sharing a ts_event, so none of them are skipped.
+// It is formatted as "<ts_event>", or "<ts_event>.<seq>" if seq is not 0.
+type streamPosition struct {
+	id  uint64
+	seq uint32
+}
+
+// parseStreamPosition parses a streamPosition formatted by its String method
+func parseStreamPosition(str string) (streamPosition, error) {
+	idStr, seqStr, found := strings.Cut(str, ".")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return streamPosition{}, err
+	}
+	var seq uint64
+	if found {
+		if seq, err = strconv.ParseUint(seqStr, 10, 32); err != nil {
+			return streamPosition{}, err
+		}
+	}
+	return streamPosition{id: id, seq: uint32(seq)}, nil
+}
+
+// String returns the position as it is formatted in an SSE event ID
+func (sp streamPosition) String() string {
+	if sp.seq == 0 {
+		return strconv.FormatUint(sp.id, 10)
+	}
+	return fmt.Sprintf("%d.%d", sp.id, sp.seq)
+}
+
+// compare returns -1, 0 or 1 as sp is before, at or after other
+func (sp streamPosition) compare(other streamPosition) int {
+	if c := cmp.Compare(sp.id, other.id); c != 0 {
+		return c
+	}
+	return cmp.Compare(sp.seq, other.seq)
+}
+
+// eventPosition returns the streamPosition of the Event
+func eventPosition(ev *livedata.Event) streamPosition {
+	return streamPosition{id: ev.ID, seq: ev.Seq}
+}
+
+// parseStreamCursor parses a Last-Event-ID header value.
+// A single position is used as the position of both schemas.
+func parseStreamCursor(str string) (streamCursor, error) {
+	if str == "" {
+		return streamCursor{}, nil
+	}
+	tradesStr, candlesStr, found := strings.Cut(str, "-")
+	if !found {
+		candlesStr = tradesStr
+	}
+	trades, err := parseStreamPosition(tradesStr)
+	if err != nil {
+		return streamCursor{}, fmt.Errorf("invalid 'Last-Event-ID': %s. %w", str, err)
+	}
+	candles, err := parseStreamPosition(candlesStr)
+	if err != nil {
+		return streamCursor{}, fmt.Errorf("invalid 'Last-Event-ID': %s. %w", str, err)
+	}
+	return streamCursor{trades: trades, candles: candles}, nil
+}
+
+// isSet returns true if the cursor has a position
+func (sc streamCursor) isSet() bool {
+	return sc.trades != streamPosition{} || sc.candles != streamPosition{}
+}
+
+// String returns the cursor as an SSE event ID
+func (sc streamCursor) String() string {
+	return sc.trades.String() + "-" + sc.candles.String()
+}
+
+// advance moves the cursor to the Event.
+// Returns false if the Event is at or before the cursor's position.
+func (sc *streamCursor) advance(ev *livedata.Event) bool {
+	pos := &sc.trades
+	if ev.Schema == livedata.SchemaOhlcv1m {
+		pos = &sc.candles
+	}
+	evPos := eventPosition(ev)
+	if evPos.compare(*pos) <= 0 {
+		return false
+	}
+	*pos = evPos
+	return true
+}
+
+// writeStreamEvent writes the Event to w as a Server-Sent Event, with the cursor as its ID
+func writeStreamEvent(w io.Writer, ev *livedata.Event, cursor streamCursor) error {
+	var data any
+	switch ev.Schema {
+	case livedata.SchemaTrades:
+		data = ev.Trade
+	case livedata.SchemaOhlcv1m:
+		data = ev.Candle
+	}
+	return sse.Encode(w, sse.Event{
+		Id:    cursor.String(),
+		Event: ev.Schema,
+		Data:  data,
+	})
+}
+
+// queryStreamReplay selects a page of the events after the cursor from the database, ordered by position.
+// Up to pageSize rows of each schema are queried.  A schema with a full page may have more rows,
+// so events after the last of a full page are left for the next page, keeping the order across schemas.
+// Returns the events and true if there are more to replay after them, and an error, if any.
+func queryStreamReplay(ctx context.Context, dataset string, ticker string, schemas []string, cursor streamCursor, pageSize int) ([]livedata.Event, bool, error) {
+	var events []livedata.Event
+	more, end := false, streamPosition{} // end is the position of the page's last event, if more
+	addPage := func(page []livedata.Event) {
+		if len(page) == pageSize {
+			if last := eventPosition(&page[len(page)-1]); !more || last.compare(end) < 0 {
+				end = last
+			}
+			more = true
+		}
+		events = append(events, page...)
+	}
+	if slices.Contains(schemas, livedata.SchemaTrades) {
+		tradeEvents, err := queryTradeReplay(ctx, dataset, ticker, cursor.trades, pageSize)
+		if err != nil {
+			return nil, false, err
+		}
+		addPage(tradeEvents)
+	}
+	if slices.Contains(schemas, livedata.SchemaOhlcv1m) {
+		candleEvents, err := queryCandleReplay(ctx, dataset, ticker, cursor.candles, pageSize)
+		if err != nil {
+			return nil, false, err
+		}
+		addPage(candleEvents)
+	}
+	if more {
+		events = slices.DeleteFunc(events, func(ev livedata.Event) bool { return eventPosition(&ev).compare(end) > 0 })
+	}
+
+	slices.SortStableFunc(events, func(a, b livedata.Event) int {
+		return eventPosition(&a).compare(eventPosition(&b))
+	})
+	return events, more, nil
+}
+
+// streamReplaySeq numbers the rows sharing a ts_event in insertion order, matching the Seq of live Events.
+// The rows at the position's ts_event are selected, so the ones after its seq are numbered the same on every page.
+const streamReplaySeq = `CAST(timestamp AS BIGINT)*1_000_000_000 + nanos AS ts_event,
+    ROW_NUMBER() OVER (PARTITION BY timestamp, nanos ORDER BY rowid) - 1 AS seq`
+
+// queryTradeReplay selects up to limit trade events after the position from the database, ordered by position.
+func queryTradeReplay(ctx context.Context, dataset string, ticker string, pos streamPosition, limit int) (events []livedata.Event, err error) {
+	queryStr := `SELECT timestamp, nanos, publisher, ticker, price, shares, seq FROM (
+  SELECT timestamp, nanos, publisher, ticker, CAST(price AS DOUBLE) AS price, shares,
+    ` + streamReplaySeq + `
+  FROM trades
+  WHERE ticker = ? AND CAST(timestamp AS BIGINT)*1_000_000_000 + nanos >= ?
+)
+WHERE ts_event > ? OR seq > ?
+ORDER BY ts_event, seq LIMIT ?;`
+	ctx, span := middleware.StartQuerySpan(ctx, "query trade replay", queryStr)
+	defer func() { middleware.EndQuerySpan(span, len(events), err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, queryStr, ticker, pos.id, pos.id, pos.seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		tick := new(sdk.TradeTick)
+		var seq uint32
+		err := rows.Scan(&tick.Timestamp, &tick.Nanos, &tick.PublisherID, &tick.Ticker, &tick.Price, &tick.Shares, &seq)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, livedata.Event{
+			ID:      uint64(tick.Timestamp)*1_000_000_000 + uint64(tick.Nanos),
+			Seq:     seq,
+			Dataset: dataset,
+			Schema:  livedata.SchemaTrades,
+			Ticker:  tick.Ticker,
+			Trade:   tick,
+		})
+	}
+	return events, rows.Err()
+}
+
+// queryCandleReplay selects up to limit candle events after the position from the database, ordered by position.
+func queryCandleReplay(ctx context.Context, dataset string, ticker string, pos streamPosition, limit int) (events []livedata.Event, err error) {
+	queryStr := `SELECT timestamp, nanos, publisher, ticker, volume, open, high, low, close, seq FROM (
+  SELECT timestamp, nanos, publisher, ticker, volume,
+    CAST(open AS DOUBLE) AS open, CAST(high AS DOUBLE) AS high, CAST(low AS DOUBLE) AS low, CAST(close AS DOUBLE) AS close,
+    ` + streamReplaySeq + `
+  FROM candles
+  WHERE ticker = ? AND CAST(timestamp AS BIGINT)*1_000_000_000 + nanos >= ?
+)
+WHERE ts_event > ? OR seq > ?
+ORDER BY ts_event, seq LIMIT ?;`
+	ctx, span := middleware.StartQuerySpan(ctx, "query candle replay", queryStr)
+	defer func() { middleware.EndQuerySpan(span, len(events), err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, queryStr, ticker, pos.id, pos.id, pos.seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		candle := new(sdk.Candle)
+		var seq uint32
+		err := rows.Scan(&candle.Timestamp, &candle.Nanos, &candle.PublisherID, &candle.Ticker, &candle.Volume,
+			&candle.Open, &candle.High, &candle.Low, &candle.Close, &seq)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, livedata.Event{
+			ID:      uint64(candle.Timestamp)*1_000_000_000 + uint64(candle.Nanos),
+			Seq:     seq,
+			Dataset: dataset,
+			Schema:  livedata.SchemaOhlcv1m,
+			Ticker:  candle.Ticker,
+			Candle:  candle,
+		})
+	}
+	return events, rows.Err()
+}
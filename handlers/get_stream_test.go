@@ -0,0 +1,184 @@
+// Copyright (c) 2025 Neomantra Corp
+// get_stream_test.go
+//
+// Tests of the SSE stream's replay of missed events from DuckDB.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+// setTestReplayDB sets the module connection to an in-memory DuckDB with trades every
+// 10 seconds and candles every minute for an hour, restoring it after the test
+func setTestReplayDB(t *testing.T, start time.Time) (trades int, candles int) {
+	t.Helper()
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, migration := range map[string]string{
+		"trades":  middleware.TradeMigrationTemplate,
+		"candles": middleware.CandlesMigrationTemplate,
+	} {
+		info := middleware.MigrationInfo{MigrationName: table, TableName: table}
+		if err := middleware.RunMigration(db, migration, info); err != nil {
+			t.Fatalf("failed to run migration: %s", err)
+		}
+	}
+	for ts := start; ts.Before(start.Add(time.Hour)); ts = ts.Add(10 * time.Second) {
+		if _, err := db.Exec(`INSERT INTO trades VALUES (?, ?, 0, 1, 'AAPL', 200, 100);`, ts, ts.Unix()); err != nil {
+			t.Fatalf("failed to insert trade: %s", err)
+		}
+		trades++
+		if ts.Second() == 0 {
+			if _, err := db.Exec(`INSERT INTO candles VALUES (?, ?, 0, 1, 'AAPL', 200, 201, 199, 200, 1000);`, ts, ts.Unix()); err != nil {
+				t.Fatalf("failed to insert candle: %s", err)
+			}
+			candles++
+		}
+	}
+
+	prevConn := gDuckdbConn
+	gDuckdbConn = db
+	t.Cleanup(func() {
+		gDuckdbConn = prevConn
+		db.Close()
+	})
+	return trades, candles
+}
+
+func TestQueryStreamReplayPages(t *testing.T) {
+	start := time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC)
+	numTrades, numCandles := setTestReplayDB(t, start)
+	schemas := []string{livedata.SchemaTrades, livedata.SchemaOhlcv1m}
+
+	for _, pageSize := range []int{1, 7, 50, streamReplayPageSize} {
+		// resume from just after the first events, as a reconnecting client would
+		first := uint64(start.UnixNano())
+		cursor := streamCursor{trades: streamPosition{id: first}, candles: streamPosition{id: first}}
+		trades, candles, pages := 0, 0, 0
+		prevID := uint64(0)
+		for more := true; more; {
+			var events []livedata.Event
+			var err error
+			events, more, err = queryStreamReplay(context.Background(), "DBEQ.BASIC", "AAPL", schemas, cursor, pageSize)
+			if err != nil {
+				t.Fatalf("queryStreamReplay: %s", err)
+			}
+			if more && len(events) == 0 {
+				t.Fatalf("page size %d: empty page with more to replay", pageSize)
+			}
+			pages++
+			for _, ev := range events {
+				if ev.ID < prevID {
+					t.Fatalf("page size %d: event %d replayed after %d", pageSize, ev.ID, prevID)
+				}
+				prevID = ev.ID
+				if !cursor.advance(&ev) {
+					t.Fatalf("page size %d: event %d of %s replayed twice", pageSize, ev.ID, ev.Schema)
+				}
+				if ev.Schema == livedata.SchemaTrades {
+					trades++
+				} else {
+					candles++
+				}
+			}
+		}
+		if trades != numTrades-1 || candles != numCandles-1 {
+			t.Errorf("page size %d: replayed %d trades and %d candles, want %d and %d",
+				pageSize, trades, candles, numTrades-1, numCandles-1)
+		}
+		if pageSize == 1 && pages < numTrades {
+			t.Errorf("page size 1: replayed in %d pages, want at least %d", pages, numTrades)
+		}
+	}
+}
+
+func TestQueryStreamReplayTies(t *testing.T) {
+	start := time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC)
+	setTestReplayDB(t, start)
+	// the unique index keeps one trade of a ticker per second; ties are stored without it
+	if _, err := gDuckdbConn.Exec(`DROP INDEX trades_ticker_timestamp_idx; DELETE FROM trades;`); err != nil {
+		t.Fatalf("failed to reset trades: %s", err)
+	}
+	tieTs := start.Add(time.Second)
+	for _, trade := range []struct {
+		ts        time.Time
+		publisher int
+	}{
+		{start, 1}, {tieTs, 1}, {tieTs, 2}, {tieTs, 3}, {start.Add(2 * time.Second), 1},
+	} {
+		if _, err := gDuckdbConn.Exec(`INSERT INTO trades VALUES (?, ?, 0, ?, 'AAPL', 200, 100);`,
+			trade.ts, trade.ts.Unix(), trade.publisher); err != nil {
+			t.Fatalf("failed to insert trade: %s", err)
+		}
+	}
+
+	// pages of 2 end inside the tie
+	var cursor streamCursor
+	var replayed []string
+	for more := true; more; {
+		var events []livedata.Event
+		var err error
+		events, more, err = queryStreamReplay(context.Background(), "DBEQ.BASIC", "AAPL",
+			[]string{livedata.SchemaTrades}, cursor, 2)
+		if err != nil {
+			t.Fatalf("queryStreamReplay: %s", err)
+		}
+		for _, ev := range events {
+			if !cursor.advance(&ev) {
+				t.Fatalf("event %s replayed twice", eventPosition(&ev))
+			}
+			replayed = append(replayed, fmt.Sprintf("%s:%d", eventPosition(&ev), ev.Trade.PublisherID))
+		}
+	}
+	tieID := uint64(tieTs.UnixNano())
+	want := []string{
+		fmt.Sprintf("%d:1", start.UnixNano()),
+		fmt.Sprintf("%d:1", tieID), fmt.Sprintf("%d.1:2", tieID), fmt.Sprintf("%d.2:3", tieID),
+		fmt.Sprintf("%d:1", start.Add(2*time.Second).UnixNano()),
+	}
+	if strings.Join(replayed, ",") != strings.Join(want, ",") {
+		t.Errorf("replayed %v, want %v", replayed, want)
+	}
+}
+
+func TestStreamCursor(t *testing.T) {
+	cursor, err := parseStreamCursor("100.2-90")
+	if err != nil {
+		t.Fatalf("parseStreamCursor: %s", err)
+	}
+	if want := (streamCursor{trades: streamPosition{id: 100, seq: 2}, candles: streamPosition{id: 90}}); cursor != want {
+		t.Fatalf("parsed %+v, want %+v", cursor, want)
+	}
+	if str := cursor.String(); str != "100.2-90" {
+		t.Errorf("String() = %q", str)
+	}
+	for _, tt := range []struct {
+		id      uint64
+		seq     uint32
+		advance bool
+	}{
+		{100, 1, false}, {100, 2, false}, {100, 3, true}, {101, 0, true}, {101, 0, false}, {101, 1, true},
+	} {
+		ev := livedata.Event{ID: tt.id, Seq: tt.seq, Schema: livedata.SchemaTrades}
+		if got := cursor.advance(&ev); got != tt.advance {
+			t.Errorf("advance to %d.%d = %v, want %v", tt.id, tt.seq, got, tt.advance)
+		}
+	}
+	for _, str := range []string{"x", "1.x", "1-2.99999999999", "1..2"} {
+		if _, err := parseStreamCursor(str); err == nil {
+			t.Errorf("parseStreamCursor(%q) succeeded, want error", str)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+)
+
+// Returns the live subscriptions of each dataset.
+//
+//	@Summary		GET live subscriptions
+//	@ID				GetSubscriptions
+//	@Description	Returns the datasets the server ingests, with their schemas and subscribed symbols, sorted by dataset.
+//	@Produce		json
+//	@Success		200	{object}	[]sdk.Subscription "array of Subscriptions"
+//	@Security		ApiKeyAuth
+//	@Router			/subscriptions [get]
+func GetSubscriptions(c *gin.Context) {
+	subscriptions := []sdk.Subscription{}
+	for _, liveClient := range getLiveDataClients() {
+		if !middleware.DatasetAllowed(c, liveClient.Dataset()) {
+			continue
+		}
+		subscriptions = append(subscriptions, sdk.Subscription{
+			Dataset: liveClient.Dataset(),
+			Schemas: liveClient.Schemas(),
+			Symbols: liveClient.Subscriptions(),
+		})
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
@@ -0,0 +1,458 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
+)
+
+const (
+	volumeProfileLevels       = 50   // price levels of a volume profile with an automatic bucket size
+	defaultValueAreaPercent   = 70.0 // percent of session volume in the value area
+	maxTapeTrades             = 5000 // most recent trades plotted by a tape chart
+	tapeTradeSymbolSizeScript = "function (value, params) { return Math.min(40, 3 + Math.sqrt(params.data.sz)); }"
+)
+
+// VolumeAtPrice is the volume traded in a price bucket
+type VolumeAtPrice struct {
+	Price  float64 // Low price of the bucket
+	Volume int64   // Shares traded in the bucket
+}
+
+// VolumeProfile is the volume at each price of a session, with its point of control and value area
+type VolumeProfile struct {
+	Bucket        float64         // Price bucket size
+	Levels        []VolumeAtPrice // Buckets in ascending price order
+	POC           int             // Index of the point of control, the level with the most volume
+	ValueAreaLow  int             // Index of the value area's lowest level
+	ValueAreaHigh int             // Index of the value area's highest level
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Returns an HTML page volume profile chart of a session for the given dataset and ticker.
+//
+//	@Summary		Returns an HTML page volume profile chart of a session for the given dataset and ticker.
+//	@ID				GetVolumeProfileChartByDatasetAndTicker
+//	@Description	Returns an HTML page histogram of the shares traded at each price in a session,
+//	@Description	highlighting the point of control (the price with the most volume) and the value area around it.
+//	@Produce		html
+//	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
+//	@Param			ticker path string	true	"symbol to query" example(AAPL)
+//	@Param			inline query boolean	false	"(optional) inline the chart's JavaScript, returning a single-file offline page as an attachment"
+//	@Param			date query string	false	"(optional) session date as YYYY-MM-DD, from midnight to midnight Eastern. Default is today in Eastern." example(2024-04-20)
+//	@Param			bucket query number	false	"(optional) price bucket size. Default divides the session's range into 50 levels." example(0.05)
+//	@Param			va query number	false	"(optional) percent of volume in the value area. Default is 70." example(70)
+//	@Success		200	{object}	string "HTML page with volume profile chart"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error "dataset not found"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/charts/volume-profile/{dataset}/{ticker} [get]
+func GetVolumeProfileChartByDatasetAndTicker(c *gin.Context) {
+	ticker := c.Param("ticker")
+	if ticker == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":ticker cannot be empty"))
+		return
+	}
+
+	dataset := c.Param("dataset")
+	if dataset == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":dataset cannot be empty"))
+		return
+	}
+
+	// The session is the Eastern calendar day, which the UTC 'date' column does not match
+	year, month, day := middleware.NowEST().Date()
+	sessionStart := time.Date(year, month, day, 0, 0, 0, 0, middleware.EasternLocation())
+	if dateStr := c.Query("date"); dateStr != "" {
+		date, err := time.ParseInLocation(time.DateOnly, dateStr, middleware.EasternLocation())
+		if err != nil {
+			middleware.BadRequestError(c, fmt.Errorf("invalid 'date' format: %s. %w", dateStr, err))
+			return
+		}
+		sessionStart = date
+	}
+	sessionEnd := sessionStart.AddDate(0, 0, 1)
+	sessionDate := sessionStart.Format(time.DateOnly)
+
+	inline, err := extractParamInline(c)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+
+	bucket := 0.0 // automatic
+	if bucketStr := c.Query("bucket"); bucketStr != "" {
+		bucket, err = strconv.ParseFloat(bucketStr, 64)
+		if err != nil || bucket <= 0 || math.IsInf(bucket, 0) {
+			middleware.BadRequestError(c, fmt.Errorf("invalid 'bucket' value: %s", bucketStr))
+			return
+		}
+	}
+
+	valueAreaPercent := defaultValueAreaPercent
+	if vaStr := c.Query("va"); vaStr != "" {
+		valueAreaPercent, err = strconv.ParseFloat(vaStr, 64)
+		if err != nil || valueAreaPercent <= 0 || valueAreaPercent > 100 {
+			middleware.BadRequestError(c, fmt.Errorf("invalid 'va' value: %s. Must be in (0, 100]", vaStr))
+			return
+		}
+	}
+
+	profile, err := queryVolumeProfile(c.Request.Context(), ticker, sessionStart, sessionEnd, bucket, valueAreaPercent)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("volume profile query error for ticker:%s dataset:%s", ticker, dataset), err)
+		return
+	}
+
+	_, renderSpan := middleware.Tracer.Start(c.Request.Context(), "render volume profile chart")
+	chartHTML, err := createVolumeProfileChartHTML(ticker, dataset, sessionDate, profile)
+	middleware.EndSpan(renderSpan, err)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("volume profile generation failed for ticker:%s dataset:%s", ticker, dataset), err)
+		return
+	}
+	if err := writeChartHTML(c, chartHTML, inline, attachmentFilename("html", dataset, ticker, "volume-profile", sessionDate)); err != nil {
+		middleware.InternalError(c, fmt.Sprintf("volume profile inlining failed for ticker:%s dataset:%s", ticker, dataset), err)
+	}
+}
+
+// queryVolumeProfile aggregates the trades of the session [sessionStart, sessionEnd) into price buckets
+// of the given size, or an automatic size if bucket is 0, and finds the point of control and value area.
+func queryVolumeProfile(ctx context.Context, ticker string, sessionStart time.Time, sessionEnd time.Time, bucket float64, valueAreaPercent float64) (profile *VolumeProfile, err error) {
+	if bucket <= 0 {
+		var low, high *float64
+		queryStr := `SELECT CAST(MIN(price) AS DOUBLE), CAST(MAX(price) AS DOUBLE) FROM trades WHERE ticker = ? AND timestamp >= ? AND timestamp < ?;`
+		rangeCtx, rangeSpan := middleware.StartQuerySpan(ctx, "query price range", queryStr)
+		err := gDuckdbConn.QueryRowContext(rangeCtx, queryStr, ticker, sessionStart.Unix(), sessionEnd.Unix()).Scan(&low, &high)
+		middleware.EndQuerySpan(rangeSpan, 1, err)
+		if err != nil {
+			return nil, err
+		}
+		bucket = 0.01
+		if low != nil && high != nil {
+			bucket = niceBucket((*high - *low) / volumeProfileLevels)
+		}
+	}
+
+	queryStr := `SELECT CAST(FLOOR(CAST(price AS DOUBLE) / ? + 1e-9) AS BIGINT) AS level, SUM(shares) AS volume
+FROM trades
+WHERE ticker = ? AND timestamp >= ? AND timestamp < ?
+GROUP BY level
+ORDER BY level;`
+	var rowCount int
+	ctx, span := middleware.StartQuerySpan(ctx, "query volume profile", queryStr)
+	defer func() { middleware.EndQuerySpan(span, rowCount, err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, queryStr, bucket, ticker, sessionStart.Unix(), sessionEnd.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profile = &VolumeProfile{Bucket: bucket}
+	for rows.Next() {
+		rowCount++
+		var level, volume int64
+		if err := rows.Scan(&level, &volume); err != nil {
+			return nil, err
+		}
+		profile.Levels = append(profile.Levels, VolumeAtPrice{Price: float64(level) * bucket, Volume: volume})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	profile.findValueArea(valueAreaPercent)
+	return profile, nil
+}
+
+// niceBucket rounds a bucket size up to 1, 2, 2.5 or 5 times a power of ten, of at least a cent
+func niceBucket(raw float64) float64 {
+	if raw <= 0.01 {
+		return 0.01
+	}
+	magnitude := math.Pow10(int(math.Floor(math.Log10(raw))))
+	for _, step := range []float64{1, 2, 2.5, 5} {
+		if step*magnitude >= raw-1e-12 {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// findValueArea finds the point of control, then grows the value area from it one level at a time,
+// toward the neighbor with more volume, until it holds valueAreaPercent of the total volume.
+func (vp *VolumeProfile) findValueArea(valueAreaPercent float64) {
+	if len(vp.Levels) == 0 {
+		return
+	}
+	var total int64
+	for i, level := range vp.Levels {
+		total += level.Volume
+		if level.Volume > vp.Levels[vp.POC].Volume {
+			vp.POC = i
+		}
+	}
+
+	target := float64(total) * valueAreaPercent / 100
+	low, high := vp.POC, vp.POC
+	volume := float64(vp.Levels[vp.POC].Volume)
+	for volume < target && (low > 0 || high < len(vp.Levels)-1) {
+		var below, above int64 = -1, -1
+		if low > 0 {
+			below = vp.Levels[low-1].Volume
+		}
+		if high < len(vp.Levels)-1 {
+			above = vp.Levels[high+1].Volume
+		}
+		if above >= below {
+			high++
+			volume += float64(above)
+		} else {
+			low--
+			volume += float64(below)
+		}
+	}
+	vp.ValueAreaLow, vp.ValueAreaHigh = low, high
+}
+
+// createVolumeProfileChartHTML creates an ECharts horizontal bar chart HTML page of the volume profile.
+// Returns the rendered page, or an error if any.
+func createVolumeProfileChartHTML(ticker string, dataset string, sessionDate string, profile *VolumeProfile) ([]byte, error) {
+	// price labels need as many decimals as the bucket size
+	decimals := 2
+	for ; decimals < 6; decimals++ {
+		scaled := profile.Bucket * math.Pow10(decimals)
+		if math.Abs(scaled-math.Round(scaled)) < 1e-9 {
+			break
+		}
+	}
+	formatPrice := func(price float64) string {
+		return strconv.FormatFloat(price, 'f', decimals, 64)
+	}
+
+	chartTitle := fmt.Sprintf("%s Volume Profile", ticker)
+	chartSubtitle := fmt.Sprintf("%s -- %s", dataset, sessionDate)
+	if len(profile.Levels) > 0 {
+		chartSubtitle += fmt.Sprintf(" -- POC %s, value area %s to %s",
+			formatPrice(profile.Levels[profile.POC].Price),
+			formatPrice(profile.Levels[profile.ValueAreaLow].Price),
+			formatPrice(profile.Levels[profile.ValueAreaHigh].Price+profile.Bucket))
+	}
+
+	prices := make([]string, len(profile.Levels))
+	volumes := make([]opts.BarData, len(profile.Levels))
+	for i, level := range profile.Levels {
+		prices[i] = formatPrice(level.Price)
+
+		// https://coolors.co/0b58b1-fbff2a-ffad05-ee793e-d92906
+		color := "#0b58b1" // outside the value area
+		switch {
+		case i == profile.POC:
+			color = "#fbff2a"
+		case i >= profile.ValueAreaLow && i <= profile.ValueAreaHigh:
+			color = "#ffad05"
+		}
+		volumes[i] = opts.BarData{Value: level.Volume, ItemStyle: &opts.ItemStyle{Color: color}}
+	}
+
+	barChart := charts.NewBar()
+	barChart.SetGlobalOptions(
+		charts.WithInitializationOpts(chartInitializationOpts(ticker)),
+		charts.WithTitleOpts(opts.Title{
+			Title:    chartTitle,
+			Subtitle: chartSubtitle,
+		}),
+		charts.WithGridOpts(opts.Grid{Left: "10%", Right: "5%", Top: "15%"}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Type:      "category",
+			AxisLabel: &opts.AxisLabel{Show: opts.Bool(true)},
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type:      "value",
+			AxisLabel: &opts.AxisLabel{Show: opts.Bool(true)},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:        opts.Bool(true),
+			Trigger:     "axis",
+			AxisPointer: &opts.AxisPointer{Type: "shadow"},
+		}),
+	)
+	barChart.SetXAxis(prices).
+		AddSeries("volume", volumes).
+		XYReversal()
+
+	// Render the chart HTML page to memory
+	var chartHTML bytes.Buffer
+	if err := barChart.Render(&chartHTML); err != nil {
+		return nil, fmt.Errorf("failed to render chart: %w", err)
+	}
+	return chartHTML.Bytes(), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Returns an HTML page time-and-sales chart of trades for the given dataset and ticker.
+//
+//	@Summary		Returns an HTML page time-and-sales chart of trades for the given dataset and ticker.
+//	@ID				GetTapeChartByDatasetAndTicker
+//	@Description	Returns an HTML page scatter chart of individual trades, each sized by its shares.
+//	@Description	At most the 5000 most recent trades of the range are plotted.
+//	@Produce		html
+//	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
+//	@Param			ticker path string	true	"symbol to query" example(AAPL)
+//	@Param			inline query boolean	false	"(optional) inline the chart's JavaScript, returning a single-file offline page as an attachment"
+//	@Param			start query string	false	"(optional) start of date range in ISO8601. Default is midnight Eastern." Format(ISO8601)
+//	@Param			end query string	false	"(optional) end of date range in ISO8601. Default is now." Format(ISO8601)
+//	@Success		200	{object}	string "HTML page with trade tape chart"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error "dataset not found"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/charts/tape/{dataset}/{ticker} [get]
+func GetTapeChartByDatasetAndTicker(c *gin.Context) {
+	ticker := c.Param("ticker")
+	if ticker == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":ticker cannot be empty"))
+		return
+	}
+
+	dataset := c.Param("dataset")
+	if dataset == "" {
+		middleware.BadRequestError(c, fmt.Errorf(":dataset cannot be empty"))
+		return
+	}
+
+	startTime, endTime, err := extractParamsTimeRange(c)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+	inline, err := extractParamInline(c)
+	if err != nil {
+		middleware.BadRequestError(c, err)
+		return
+	}
+
+	trades, err := queryTapeTrades(c.Request.Context(), ticker, startTime, endTime)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("tape query error for ticker:%s dataset:%s", ticker, dataset), err)
+		return
+	}
+
+	_, renderSpan := middleware.Tracer.Start(c.Request.Context(), "render tape chart")
+	chartHTML, err := createTapeChartHTML(ticker, dataset, trades)
+	middleware.EndSpan(renderSpan, err)
+	if err != nil {
+		middleware.InternalError(c, fmt.Sprintf("tape generation failed for ticker:%s dataset:%s", ticker, dataset), err)
+		return
+	}
+	if err := writeChartHTML(c, chartHTML, inline, attachmentFilename("html", dataset, ticker, "tape")); err != nil {
+		middleware.InternalError(c, fmt.Sprintf("tape inlining failed for ticker:%s dataset:%s", ticker, dataset), err)
+	}
+}
+
+// queryTapeTrades selects the most recent trades of the time range, in time order
+func queryTapeTrades(ctx context.Context, ticker string, startTime time.Time, endTime time.Time) (ticks []*sdk.TradeTick, err error) {
+	queryStr := `SELECT * FROM (
+  SELECT timestamp, nanos, publisher, ticker, CAST(price AS DOUBLE) AS price, shares FROM trades
+  WHERE ticker = ? AND timestamp BETWEEN ? AND ?
+  ORDER BY timestamp DESC, nanos DESC LIMIT ?
+) ORDER BY timestamp, nanos;`
+	ctx, span := middleware.StartQuerySpan(ctx, "query tape trades", queryStr)
+	defer func() { middleware.EndQuerySpan(span, len(ticks), err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, queryStr, ticker, startTime.Unix(), endTime.Unix()+1, maxTapeTrades)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tick := new(sdk.TradeTick)
+		err := rows.Scan(&tick.Timestamp, &tick.Nanos, &tick.PublisherID, &tick.Ticker, &tick.Price, &tick.Shares)
+		if err != nil {
+			return nil, err
+		}
+		ticks = append(ticks, tick)
+	}
+	return ticks, rows.Err()
+}
+
+// createTapeChartHTML creates an ECharts scatter chart HTML page of the trades.
+// Returns the rendered page, or an error if any.
+func createTapeChartHTML(ticker string, dataset string, trades []*sdk.TradeTick) ([]byte, error) {
+	chartTitle := fmt.Sprintf("%s Trades", ticker)
+	chartSubtitle := dataset
+	if len(trades) > 0 {
+		chartSubtitle += fmt.Sprintf(" -- %s to %s",
+			time.Unix(trades[0].Timestamp, 0).Format("2006-01-02 15:04:05"),
+			time.Unix(trades[len(trades)-1].Timestamp, 0).Format("2006-01-02 15:04:05"))
+	}
+
+	scatterChart := charts.NewScatter()
+	scatterChart.AddDataset(opts.Dataset{
+		Source: trades,
+	})
+	scatterChart.SetGlobalOptions(
+		charts.WithInitializationOpts(chartInitializationOpts(ticker)),
+		charts.WithTitleOpts(opts.Title{
+			Title:    chartTitle,
+			Subtitle: chartSubtitle,
+		}),
+		charts.WithGridOpts(opts.Grid{Left: "5%", Right: "5%", Height: "70%"}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type: "category",
+			AxisLabel: &opts.AxisLabel{
+				Show:      opts.Bool(true),
+				Formatter: types.FuncStr(opts.FuncStripCommentsOpts(xAxisFormatter)),
+			},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Type:      "value",
+			Scale:     opts.Bool(true),
+			AxisLabel: &opts.AxisLabel{Show: opts.Bool(true)},
+		}),
+		charts.WithDataZoomOpts(opts.DataZoom{
+			Type:           "inside",
+			XAxisIndex:     []int{0},
+			LabelFormatter: opts.FuncStripCommentsOpts(zoomLabelFormatter),
+			Start:          0,
+			End:            100,
+		}, opts.DataZoom{
+			Type:           "slider",
+			XAxisIndex:     []int{0},
+			LabelFormatter: opts.FuncStripCommentsOpts(zoomLabelFormatter),
+			Start:          0,
+			End:            100,
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:        opts.Bool(true),
+			Trigger:     "axis",
+			AxisPointer: &opts.AxisPointer{Type: "line"},
+			Formatter:   types.FuncStr(opts.FuncStripCommentsOpts(tooltipFormatter)),
+		}),
+	)
+	scatterChart.AddSeries("trades", nil,
+		charts.WithItemStyleOpts(opts.ItemStyle{Color: "#7fbe9e", Opacity: 0.6}),
+		charts.WithScatterChartOpts(opts.ScatterChart{SymbolSize: opts.FuncOpts(tapeTradeSymbolSizeScript)}),
+		charts.WithEncodeOpts(opts.Encode{X: "ts", Y: "px"}))
+
+	// Render the chart HTML page to memory
+	var chartHTML bytes.Buffer
+	if err := scatterChart.Render(&chartHTML); err != nil {
+		return nil, fmt.Errorf("failed to render chart: %w", err)
+	}
+	return chartHTML.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2025 Neomantra Corp
+// get_trade_charts_test.go
+//
+// Tests that the volume profile's session is the Eastern calendar day.
+
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+)
+
+func TestQueryVolumeProfileEasternSession(t *testing.T) {
+	numTrades, _ := setTestReplayDB(t, time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC))
+
+	// an evening trade of the session is on the next UTC date, and one of the day before on the same UTC date
+	for _, trade := range []struct {
+		date   string
+		ts     time.Time
+		price  float64
+		shares int
+	}{
+		{"2025-01-03", time.Date(2025, 1, 3, 3, 0, 0, 0, time.UTC), 300, 50}, // 22:00 Eastern on the 2nd
+		{"2025-01-02", time.Date(2025, 1, 2, 2, 0, 0, 0, time.UTC), 100, 70}, // 21:00 Eastern on the 1st
+		{"2025-01-03", time.Date(2025, 1, 3, 5, 0, 0, 0, time.UTC), 400, 90}, // midnight Eastern on the 3rd
+	} {
+		if _, err := gDuckdbConn.Exec(`INSERT INTO trades VALUES (?, ?, 0, 1, 'AAPL', ?, ?);`,
+			trade.date, trade.ts.Unix(), trade.price, trade.shares); err != nil {
+			t.Fatalf("failed to insert trade: %s", err)
+		}
+	}
+
+	sessionStart := time.Date(2025, 1, 2, 0, 0, 0, 0, middleware.EasternLocation())
+	profile, err := queryVolumeProfile(context.Background(), "AAPL", sessionStart, sessionStart.AddDate(0, 0, 1), 1, defaultValueAreaPercent)
+	if err != nil {
+		t.Fatalf("queryVolumeProfile: %s", err)
+	}
+	volumes := make(map[float64]int64)
+	for _, level := range profile.Levels {
+		volumes[level.Price] = level.Volume
+	}
+	want := map[float64]int64{200: int64(numTrades) * 100, 300: 50}
+	if len(volumes) != len(want) || volumes[200] != want[200] || volumes[300] != want[300] {
+		t.Errorf("volume profile levels = %v, want %v", volumes, want)
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	wsSendBufferSize    = 256              // messages buffered per connection before it is a slow consumer
+	wsMaxRequestSize    = 64 * 1024        // max size of a client request message
+	wsWriteTimeout      = 10 * time.Second // time allowed to write a message
+	wsPongTimeout       = 60 * time.Second // time allowed between client pongs
+	wsPingInterval      = 25 * time.Second // interval of WebSocket pings, must be less than wsPongTimeout
+	wsHeartbeatInterval = 15 * time.Second // interval of "heartbeat" messages
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin: func(r *http.Request) bool {
+		return middleware.IsOriginAllowed(r.Header.Get("Origin"))
+	},
+}
+
+// GetWebSocketStream upgrades the request to the WebSocket market-data gateway.
+//
+// Clients send sdk.StreamRequest messages to subscribe and unsubscribe to
+// (dataset, schema, symbols) and receive sdk.StreamMessage messages with
+// each sdk.TradeTick and sdk.Candle as it is ingested, plus periodic heartbeats.
+// A connection whose send buffer fills is disconnected as a slow consumer.
+func GetWebSocketStream(c *gin.Context) {
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		c.Error(fmt.Errorf("websocket upgrade failed: %w", err))
+		return // Upgrade already responded with an error
+	}
+
+	wc := &wsConnection{
+		conn:   conn,
+		logger: middleware.GetGinLogger(c),
+		allowsDataset: func(dataset string) bool {
+			return middleware.DatasetAllowed(c, dataset)
+		},
+		send:       make(chan sdk.StreamMessage, wsSendBufferSize),
+		done:       make(chan struct{}),
+		topics:     make(map[wsTopic]struct{}),
+		brokerSubs: make(map[string]*livedata.Subscription),
+	}
+	go wc.writePump()
+	wc.readPump() // blocks until the connection closes
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// wsTopic is a single subscribed (dataset, schema, symbol) of a connection
+type wsTopic struct {
+	dataset string
+	schema  string
+	symbol  string
+}
+
+// wsConnection is the state of one WebSocket gateway client
+type wsConnection struct {
+	conn          *websocket.Conn
+	logger        *zap.Logger
+	allowsDataset func(dataset string) bool // the API key of the connection allows the dataset
+
+	send      chan sdk.StreamMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	closeCode int
+	closeText string
+
+	mu     sync.RWMutex // guards topics, which the broker filters read while publishing
+	topics map[wsTopic]struct{}
+
+	subsMu     sync.Mutex                        // guards brokerSubs, never held by the broker
+	brokerSubs map[string]*livedata.Subscription // keyed by dataset
+}
+
+// readPump reads and handles client requests until the connection closes
+func (wc *wsConnection) readPump() {
+	defer wc.shutdown()
+
+	wc.conn.SetReadLimit(wsMaxRequestSize)
+	wc.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	wc.conn.SetPongHandler(func(string) error {
+		return wc.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		var req sdk.StreamRequest
+		if err := wc.conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				wc.logger.Debug("websocket read failed", zap.Error(err))
+			}
+			return
+		}
+		wc.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		var resp sdk.StreamMessage
+		switch req.Type {
+		case sdk.StreamRequestSubscribe:
+			resp = wc.subscribe(&req)
+		case sdk.StreamRequestUnsubscribe:
+			resp = wc.unsubscribe(&req)
+		case sdk.StreamRequestPing:
+			resp = sdk.StreamMessage{Type: sdk.StreamMessagePong, ID: req.ID, Time: time.Now().Unix()}
+		default:
+			resp = wsErrorMessage(req.ID, fmt.Errorf("invalid request type: %s", req.Type))
+		}
+		if !wc.enqueue(resp) {
+			return
+		}
+	}
+}
+
+// writePump writes queued messages, heartbeats and pings until the connection closes
+func (wc *wsConnection) writePump() {
+	pingTicker := time.NewTicker(wsPingInterval)
+	heartbeatTicker := time.NewTicker(wsHeartbeatInterval)
+	defer func() {
+		pingTicker.Stop()
+		heartbeatTicker.Stop()
+		wc.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-wc.done:
+			if wc.closeCode != 0 {
+				msg := websocket.FormatCloseMessage(wc.closeCode, wc.closeText)
+				wc.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+			}
+			return
+		case <-gStreamsClosed:
+			wc.closeWith(websocket.CloseGoingAway, "server shutting down")
+		case msg := <-wc.send:
+			wc.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := wc.conn.WriteJSON(msg); err != nil {
+				wc.shutdown()
+				return
+			}
+		case <-heartbeatTicker.C:
+			wc.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			msg := sdk.StreamMessage{Type: sdk.StreamMessageHeartbeat, Time: time.Now().Unix()}
+			if err := wc.conn.WriteJSON(msg); err != nil {
+				wc.shutdown()
+				return
+			}
+		case <-pingTicker.C:
+			if err := wc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				wc.shutdown()
+				return
+			}
+		}
+	}
+}
+
+// enqueue queues a message for the client without blocking.
+// A full queue disconnects the client as a slow consumer; returns false if the connection is closed.
+func (wc *wsConnection) enqueue(msg sdk.StreamMessage) bool {
+	if wc.isClosed() {
+		return false
+	}
+	select {
+	case wc.send <- msg:
+		return true
+	default:
+		wc.logger.Warn("websocket slow consumer disconnected")
+		wc.closeWith(websocket.CloseTryAgainLater, livedata.ErrSlowConsumer.Error())
+		return false
+	}
+}
+
+// isClosed returns true if the connection is closing
+func (wc *wsConnection) isClosed() bool {
+	select {
+	case <-wc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeWith closes the connection with the WebSocket close code and reason
+func (wc *wsConnection) closeWith(code int, text string) {
+	wc.closeOnce.Do(func() {
+		wc.closeCode, wc.closeText = code, text
+		close(wc.done)
+	})
+}
+
+// shutdown closes the connection and releases its broker subscriptions
+func (wc *wsConnection) shutdown() {
+	wc.closeWith(0, "")
+	wc.subsMu.Lock()
+	for dataset := range wc.brokerSubs {
+		wc.releaseBrokerSubLocked(dataset)
+	}
+	wc.subsMu.Unlock()
+}
+
+// releaseBrokerSubLocked unsubscribes the dataset's broker subscription. wc.subsMu must be held.
+func (wc *wsConnection) releaseBrokerSubLocked(dataset string) {
+	sub, ok := wc.brokerSubs[dataset]
+	if !ok {
+		return
+	}
+	delete(wc.brokerSubs, dataset)
+	if liveClient := getLiveDataClient(dataset); liveClient != nil {
+		liveClient.Broker().Unsubscribe(sub)
+	}
+}
+
+// subscribe handles a "subscribe" request
+func (wc *wsConnection) subscribe(req *sdk.StreamRequest) sdk.StreamMessage {
+	if err := validateStreamRequest(req); err != nil {
+		return wsErrorMessage(req.ID, err)
+	}
+	if !wc.allowsDataset(req.Dataset) {
+		return wsErrorMessage(req.ID, fmt.Errorf("API key may not access dataset %s", req.Dataset))
+	}
+	liveClient := getLiveDataClient(req.Dataset)
+	if liveClient == nil {
+		return wsErrorMessage(req.ID, fmt.Errorf("dataset not found: %s", req.Dataset))
+	}
+
+	wc.mu.Lock()
+	for _, symbol := range req.Symbols {
+		wc.topics[wsTopic{dataset: req.Dataset, schema: req.Schema, symbol: symbol}] = struct{}{}
+	}
+	wc.mu.Unlock()
+
+	wc.subsMu.Lock()
+	if _, ok := wc.brokerSubs[req.Dataset]; !ok && !wc.isClosed() {
+		sub := liveClient.Broker().Subscribe(wsSendBufferSize, wc.wants)
+		wc.brokerSubs[req.Dataset] = sub
+		go wc.forward(sub)
+	}
+	wc.subsMu.Unlock()
+
+	return sdk.StreamMessage{Type: sdk.StreamMessageSubscribed, ID: req.ID,
+		Dataset: req.Dataset, Schema: req.Schema, Symbols: req.Symbols}
+}
+
+// unsubscribe handles an "unsubscribe" request
+func (wc *wsConnection) unsubscribe(req *sdk.StreamRequest) sdk.StreamMessage {
+	if err := validateStreamRequest(req); err != nil {
+		return wsErrorMessage(req.ID, err)
+	}
+
+	wc.mu.Lock()
+	for _, symbol := range req.Symbols {
+		delete(wc.topics, wsTopic{dataset: req.Dataset, schema: req.Schema, symbol: symbol})
+	}
+	stillUsed := false
+	for topic := range wc.topics {
+		if topic.dataset == req.Dataset {
+			stillUsed = true
+			break
+		}
+	}
+	wc.mu.Unlock()
+
+	// release the dataset's broker subscription once nothing is left on it
+	if !stillUsed {
+		wc.subsMu.Lock()
+		wc.releaseBrokerSubLocked(req.Dataset)
+		wc.subsMu.Unlock()
+	}
+
+	return sdk.StreamMessage{Type: sdk.StreamMessageUnsubscribed, ID: req.ID,
+		Dataset: req.Dataset, Schema: req.Schema, Symbols: req.Symbols}
+}
+
+// wants is the livedata.EventFilter of the connection's broker subscriptions
+func (wc *wsConnection) wants(ev *livedata.Event) bool {
+	wc.mu.RLock()
+	_, ok := wc.topics[wsTopic{dataset: ev.Dataset, schema: ev.Schema, symbol: ev.Ticker}]
+	wc.mu.RUnlock()
+	return ok
+}
+
+// forward relays a broker subscription's Events to the client until it closes
+func (wc *wsConnection) forward(sub *livedata.Subscription) {
+	for ev := range sub.C {
+		msg := sdk.StreamMessage{Dataset: ev.Dataset, Schema: ev.Schema}
+		switch ev.Schema {
+		case livedata.SchemaTrades:
+			msg.Type, msg.Trade = sdk.StreamMessageTrade, ev.Trade
+		case livedata.SchemaOhlcv1m:
+			msg.Type, msg.Candle = sdk.StreamMessageCandle, ev.Candle
+		}
+		if !wc.enqueue(msg) {
+			return
+		}
+	}
+	if sub.Err() != nil {
+		wc.logger.Warn("websocket slow consumer disconnected", zap.Error(sub.Err()))
+		wc.closeWith(websocket.CloseTryAgainLater, sub.Err().Error())
+	}
+}
+
+// validateStreamRequest checks the fields of a (un)subscribe request
+func validateStreamRequest(req *sdk.StreamRequest) error {
+	if req.Dataset == "" {
+		return fmt.Errorf("'dataset' cannot be empty")
+	}
+	if !slices.Contains([]string{livedata.SchemaTrades, livedata.SchemaOhlcv1m}, req.Schema) {
+		return fmt.Errorf("invalid 'schema': %s", req.Schema)
+	}
+	if len(req.Symbols) == 0 {
+		return fmt.Errorf("'symbols' cannot be empty")
+	}
+	return nil
+}
+
+// wsErrorMessage returns an "error" StreamMessage for the request ID and error
+func wsErrorMessage(id string, err error) sdk.StreamMessage {
+	return sdk.StreamMessage{Type: sdk.StreamMessageError, ID: id, Message: err.Error()}
+}
@@ -0,0 +1,295 @@
+// Copyright (c) 2025 Neomantra Corp
+// get_ws_stream_test.go
+//
+// Tests of the WebSocket market-data gateway with the SDK's StreamClient.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	_ "github.com/marcboeker/go-duckdb/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	wsTestDataset = "DBEQ.BASIC"
+	wsTestTimeout = 5 * time.Second
+)
+
+// addTestLiveClient registers a detached LiveDataClient of wsTestDataset for the test
+func addTestLiveClient(t *testing.T) *livedata.LiveDataClient {
+	t.Helper()
+	client := livedata.NewDetachedLiveDataClient(livedata.LiveDataConfig{Dataset: wsTestDataset})
+	AddLiveDataClient(client)
+	t.Cleanup(func() {
+		gLiveClientsMutex.Lock()
+		delete(gLiveClients, wsTestDataset)
+		gLiveClientsMutex.Unlock()
+	})
+	return client
+}
+
+// dialTestGateway starts a server of handler and dials its /ws/v1 route
+func dialTestGateway(t *testing.T, handler http.Handler, header http.Header) (*sdk.StreamClient, error) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), wsTestTimeout)
+	defer cancel()
+	return sdk.DialStream(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws/v1", header)
+}
+
+// nextMessage returns the client's next message other than a heartbeat
+func nextMessage(t *testing.T, sc *sdk.StreamClient) sdk.StreamMessage {
+	t.Helper()
+	timeout := time.After(wsTestTimeout)
+	for {
+		select {
+		case msg, ok := <-sc.Messages():
+			if !ok {
+				t.Fatalf("connection closed: %v", sc.Err())
+			}
+			if msg.Type != sdk.StreamMessageHeartbeat {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for a message")
+		}
+	}
+}
+
+// expectMessage fails the test unless the client's next message is of the type and request ID
+func expectMessage(t *testing.T, sc *sdk.StreamClient, msgType string, id string) sdk.StreamMessage {
+	t.Helper()
+	msg := nextMessage(t, sc)
+	if msg.Type != msgType || msg.ID != id {
+		t.Fatalf("got %+v, want a %q message of ID %q", msg, msgType, id)
+	}
+	return msg
+}
+
+// waitClosed waits for the client's connection to end and returns its error
+func waitClosed(t *testing.T, sc *sdk.StreamClient) error {
+	t.Helper()
+	timeout := time.After(wsTestTimeout)
+	for {
+		select {
+		case _, ok := <-sc.Messages():
+			if !ok {
+				return sc.Err()
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for the connection to close")
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestWebSocketSubscribe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	liveClient := addTestLiveClient(t)
+	sc, err := dialTestGateway(t, RegisterWebSocketApi(gin.New()), nil)
+	if err != nil {
+		t.Fatalf("DialStream: %s", err)
+	}
+	defer sc.Close()
+
+	id, _ := sc.Ping()
+	expectMessage(t, sc, sdk.StreamMessagePong, id)
+
+	id, _ = sc.Subscribe(wsTestDataset, livedata.SchemaTrades, "AAPL")
+	msg := expectMessage(t, sc, sdk.StreamMessageSubscribed, id)
+	if msg.Dataset != wsTestDataset || msg.Schema != livedata.SchemaTrades || len(msg.Symbols) != 1 || msg.Symbols[0] != "AAPL" {
+		t.Errorf("subscribed ack = %+v", msg)
+	}
+	if n := liveClient.Broker().NumSubscriptions(); n != 1 {
+		t.Fatalf("broker has %d subscriptions, want 1", n)
+	}
+
+	// only the subscribed (schema, symbol) is delivered
+	broker := liveClient.Broker()
+	broker.Publish(livedata.Event{ID: 1, Dataset: wsTestDataset, Schema: livedata.SchemaTrades, Ticker: "MSFT",
+		Trade: &sdk.TradeTick{Ticker: "MSFT"}})
+	broker.Publish(livedata.Event{ID: 2, Dataset: wsTestDataset, Schema: livedata.SchemaOhlcv1m, Ticker: "AAPL",
+		Candle: &sdk.Candle{Ticker: "AAPL"}})
+	broker.Publish(livedata.Event{ID: 3, Dataset: wsTestDataset, Schema: livedata.SchemaTrades, Ticker: "AAPL",
+		Trade: &sdk.TradeTick{Ticker: "AAPL", Price: 200}})
+	msg = expectMessage(t, sc, sdk.StreamMessageTrade, "")
+	if msg.Trade == nil || msg.Trade.Ticker != "AAPL" || msg.Trade.Price != 200 {
+		t.Errorf("trade message = %+v", msg)
+	}
+
+	// unsubscribing the last symbol releases the broker subscription
+	id, _ = sc.Unsubscribe(wsTestDataset, livedata.SchemaTrades, "AAPL")
+	expectMessage(t, sc, sdk.StreamMessageUnsubscribed, id)
+	if n := broker.NumSubscriptions(); n != 0 {
+		t.Errorf("broker has %d subscriptions after unsubscribe, want 0", n)
+	}
+
+	if err := sc.Close(); err != nil {
+		t.Errorf("Close: %s", err)
+	}
+	if err := waitClosed(t, sc); err != nil {
+		t.Errorf("Err after Close = %v, want nil", err)
+	}
+}
+
+func TestWebSocketInvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	addTestLiveClient(t)
+	sc, err := dialTestGateway(t, RegisterWebSocketApi(gin.New()), nil)
+	if err != nil {
+		t.Fatalf("DialStream: %s", err)
+	}
+	defer sc.Close()
+
+	for _, tt := range []struct {
+		name    string
+		dataset string
+		schema  string
+		symbols []string
+	}{
+		{"empty dataset", "", livedata.SchemaTrades, []string{"AAPL"}},
+		{"unknown dataset", "XNAS.ITCH", livedata.SchemaTrades, []string{"AAPL"}},
+		{"invalid schema", wsTestDataset, "mbo", []string{"AAPL"}},
+		{"no symbols", wsTestDataset, livedata.SchemaTrades, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := sc.Subscribe(tt.dataset, tt.schema, tt.symbols...)
+			if err != nil {
+				t.Fatalf("Subscribe: %s", err)
+			}
+			if msg := expectMessage(t, sc, sdk.StreamMessageError, id); msg.Message == "" {
+				t.Errorf("error message is empty")
+			}
+		})
+	}
+
+	// the connection stays open after errors
+	id, _ := sc.Ping()
+	expectMessage(t, sc, sdk.StreamMessagePong, id)
+}
+
+func TestWebSocketDatasetAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	addTestLiveClient(t)
+	keysDB, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keysDB.Close()
+	store, err := middleware.NewDuckDBKeyStore(keysDB, "api_keys")
+	if err != nil {
+		t.Fatalf("NewDuckDBKeyStore: %s", err)
+	}
+	newKey := func(id string, datasets []string) http.Header {
+		key, token, err := middleware.NewAPIKey(id, []string{middleware.ScopeRead}, datasets)
+		if err != nil {
+			t.Fatalf("NewAPIKey: %s", err)
+		}
+		if err := store.AddKey(context.Background(), key); err != nil {
+			t.Fatalf("AddKey: %s", err)
+		}
+		return http.Header{"Authorization": []string{"Bearer " + token}}
+	}
+	readHeader := newKey("reader", []string{wsTestDataset})
+	otherHeader := newKey("other", []string{"XNAS.ITCH"})
+	middleware.SetKeyStore(store)
+	t.Cleanup(func() { middleware.SetKeyStore(nil) })
+
+	router := RegisterWebSocketApi(gin.New())
+	if _, err := dialTestGateway(t, router, nil); err == nil {
+		t.Errorf("DialStream without key succeeded, want error")
+	}
+
+	sc, err := dialTestGateway(t, router, otherHeader)
+	if err != nil {
+		t.Fatalf("DialStream with key of another dataset: %s", err)
+	}
+	defer sc.Close()
+	id, _ := sc.Subscribe(wsTestDataset, livedata.SchemaTrades, "AAPL")
+	if msg := expectMessage(t, sc, sdk.StreamMessageError, id); !strings.Contains(msg.Message, wsTestDataset) {
+		t.Errorf("error message = %q, want it to name %s", msg.Message, wsTestDataset)
+	}
+
+	sc, err = dialTestGateway(t, router, readHeader)
+	if err != nil {
+		t.Fatalf("DialStream with key of the dataset: %s", err)
+	}
+	defer sc.Close()
+	id, _ = sc.Subscribe(wsTestDataset, livedata.SchemaTrades, "AAPL")
+	expectMessage(t, sc, sdk.StreamMessageSubscribed, id)
+}
+
+func TestWebSocketSlowConsumer(t *testing.T) {
+	// serveConnection returns a handler running a connection with a one-message send queue,
+	// which fill is called with before the connection writes
+	serveConnection := func(fill func(wc *wsConnection)) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := wsUpgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			wc := &wsConnection{
+				conn:          conn,
+				logger:        zap.NewNop(),
+				allowsDataset: func(string) bool { return true },
+				send:          make(chan sdk.StreamMessage, 1),
+				done:          make(chan struct{}),
+				topics:        make(map[wsTopic]struct{}),
+				brokerSubs:    make(map[string]*livedata.Subscription),
+			}
+			fill(wc)
+			wc.writePump()
+		})
+	}
+
+	for _, tt := range []struct {
+		name string
+		fill func(t *testing.T, wc *wsConnection)
+	}{
+		{"send queue full", func(t *testing.T, wc *wsConnection) {
+			if !wc.enqueue(sdk.StreamMessage{Type: sdk.StreamMessagePong}) {
+				t.Errorf("first enqueue failed")
+			}
+			if wc.enqueue(sdk.StreamMessage{Type: sdk.StreamMessagePong}) {
+				t.Errorf("enqueue to a full queue succeeded")
+			}
+		}},
+		{"broker subscription dropped", func(t *testing.T, wc *wsConnection) {
+			broker := livedata.NewBroker()
+			sub := broker.Subscribe(1, nil)
+			broker.Publish(livedata.Event{ID: 1, Schema: livedata.SchemaTrades, Trade: &sdk.TradeTick{}})
+			broker.Publish(livedata.Event{ID: 2, Schema: livedata.SchemaTrades, Trade: &sdk.TradeTick{}})
+			wc.forward(sub) // returns once the dropped subscription is drained
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := dialTestGateway(t, serveConnection(func(wc *wsConnection) { tt.fill(t, wc) }), nil)
+			if err != nil {
+				t.Fatalf("DialStream: %s", err)
+			}
+			defer sc.Close()
+			err = waitClosed(t, sc)
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseTryAgainLater ||
+				closeErr.Text != livedata.ErrSlowConsumer.Error() {
+				t.Errorf("Err = %v, want a slow consumer close", err)
+			}
+		})
+	}
+}
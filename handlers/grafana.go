@@ -0,0 +1,285 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// The /grafana routes implement the JSON datasource protocol of Grafana's
+// JSON (simpod-json-datasource) and SimpleJSON plugins.  Targets name a time
+// series as 'DATASET:TICKER:FIELD', such as 'DBEQ.BASIC:SPY:close'.
+
+const (
+	grafanaDefaultField      = "close"          // field of targets without one
+	grafanaMinCandleInterval = 60 * time.Second // candles are one minute
+	grafanaMinTradeInterval  = time.Second      // trade timestamps are seconds
+)
+
+// grafanaFieldQueries select a field of the candles or trades tables in time buckets,
+// by target field.  Each takes the bucket size in seconds twice, the ticker and a timestamp range.
+var grafanaFieldQueries = map[string]string{
+	"open":   grafanaCandlesQuery("arg_min(CAST(open AS DOUBLE), timestamp)"),
+	"high":   grafanaCandlesQuery("max(CAST(high AS DOUBLE))"),
+	"low":    grafanaCandlesQuery("min(CAST(low AS DOUBLE))"),
+	"close":  grafanaCandlesQuery("arg_max(CAST(close AS DOUBLE), timestamp)"),
+	"volume": grafanaCandlesQuery("CAST(sum(volume) AS DOUBLE)"),
+	"vwap":   grafanaTradesQuery("CAST(sum(price * shares) / sum(shares) AS DOUBLE)"),
+	"trades": grafanaTradesQuery("CAST(count(*) AS DOUBLE)"),
+}
+
+// grafanaCandleFields are the fields of grafanaFieldQueries backed by the candles table
+var grafanaCandleFields = []string{"open", "high", "low", "close", "volume"}
+
+// grafanaFields are the fields of grafanaFieldQueries, in the order they are listed by search
+var grafanaFields = []string{"open", "high", "low", "close", "volume", "vwap", "trades"}
+
+func grafanaCandlesQuery(aggregate string) string {
+	return fmt.Sprintf(`SELECT timestamp // ? * ? AS bucket, %s AS value
+FROM candles
+WHERE ticker = ? AND timestamp BETWEEN ? AND ?
+GROUP BY bucket ORDER BY bucket;`, aggregate)
+}
+
+func grafanaTradesQuery(aggregate string) string {
+	return fmt.Sprintf(`SELECT timestamp // ? * ? AS bucket, %s AS value
+FROM trades
+WHERE ticker = ? AND timestamp BETWEEN ? AND ?
+GROUP BY bucket ORDER BY bucket;`, aggregate)
+}
+
+// GrafanaRange is the time range of a Grafana request
+type GrafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// GrafanaSearchRequest is a Grafana request for the targets matching a string
+type GrafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+// GrafanaQueryTarget is a target of a GrafanaQueryRequest
+type GrafanaQueryTarget struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId"`
+	Type   string `json:"type"` // "timeserie" or "table"
+	Hide   bool   `json:"hide"`
+}
+
+// GrafanaQueryRequest is a Grafana request for time series
+type GrafanaQueryRequest struct {
+	Range         GrafanaRange         `json:"range"`
+	IntervalMs    int64                `json:"intervalMs"`
+	MaxDataPoints int64                `json:"maxDataPoints"`
+	Targets       []GrafanaQueryTarget `json:"targets"`
+}
+
+// GrafanaTimeSeries is a time series response to a GrafanaQueryTarget
+type GrafanaTimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"` // [value, milliseconds from the epoch]
+}
+
+// GrafanaTable is a table response to a GrafanaQueryTarget of type "table"
+type GrafanaTable struct {
+	Type    string               `json:"type"` // "table"
+	Columns []GrafanaTableColumn `json:"columns"`
+	Rows    [][]any              `json:"rows"`
+}
+
+// GrafanaTableColumn is a column of a GrafanaTable
+type GrafanaTableColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"` // "time" or "number"
+}
+
+// GrafanaAnnotationRequest is a Grafana request for annotations
+type GrafanaAnnotationRequest struct {
+	Range      GrafanaRange `json:"range"`
+	Annotation struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+	} `json:"annotation"`
+}
+
+// GrafanaAnnotation is an event shown on Grafana panels
+type GrafanaAnnotation struct {
+	Time    int64    `json:"time"` // milliseconds from the epoch
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Title   string   `json:"title"`
+	Text    string   `json:"text"`
+	Tags    []string `json:"tags"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// GetGrafanaTest responds OK to Grafana's datasource connection test
+func GetGrafanaTest(c *gin.Context) {
+	c.String(http.StatusOK, "OK")
+}
+
+// PostGrafanaSearch returns the targets containing the request's target string, case-insensitively.
+// There is a target for each field of each subscribed symbol of each dataset.
+func PostGrafanaSearch(c *gin.Context) {
+	var request GrafanaSearchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.BadRequestError(c, fmt.Errorf("invalid search request: %w", err))
+		return
+	}
+	search := strings.ToLower(request.Target)
+
+	targets := []string{}
+	for _, liveClient := range getLiveDataClients() {
+		if !middleware.DatasetAllowed(c, liveClient.Dataset()) {
+			continue
+		}
+		for _, ticker := range liveClient.Subscriptions() {
+			for _, field := range grafanaFields {
+				target := fmt.Sprintf("%s:%s:%s", liveClient.Dataset(), ticker, field)
+				if strings.Contains(strings.ToLower(target), search) {
+					targets = append(targets, target)
+				}
+			}
+		}
+	}
+	c.JSON(http.StatusOK, targets)
+}
+
+// PostGrafanaQuery returns a time series, or table, for each target of the request.
+// Values are aggregated into buckets of the request's interval, at least a minute for
+// candle fields and a second for trade fields, and few enough for maxDataPoints.
+func PostGrafanaQuery(c *gin.Context) {
+	var request GrafanaQueryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.BadRequestError(c, fmt.Errorf("invalid query request: %w", err))
+		return
+	}
+	if request.Range.To.Before(request.Range.From) {
+		middleware.BadRequestError(c, fmt.Errorf("invalid 'range': 'to' is before 'from'"))
+		return
+	}
+
+	responses := []any{}
+	for _, target := range request.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		dataset, ticker, field, err := parseGrafanaTarget(target.Target)
+		if err != nil {
+			middleware.BadRequestError(c, err)
+			return
+		}
+		if !middleware.DatasetAllowed(c, dataset) {
+			middleware.ForbiddenError(c, fmt.Errorf("API key may not access dataset %s", dataset))
+			return
+		}
+		if getLiveDataClient(dataset) == nil {
+			middleware.NotFoundError(c, fmt.Errorf("dataset not found: %s", dataset))
+			return
+		}
+
+		interval := grafanaBucketInterval(request, field)
+		datapoints, err := queryGrafanaDatapoints(c.Request.Context(), ticker, field, interval,
+			request.Range.From, request.Range.To)
+		if err != nil {
+			middleware.InternalError(c, fmt.Sprintf("grafana query error for target:%s", target.Target), err)
+			return
+		}
+
+		if target.Type == "table" {
+			table := GrafanaTable{
+				Type:    "table",
+				Columns: []GrafanaTableColumn{{Text: "Time", Type: "time"}, {Text: target.Target, Type: "number"}},
+				Rows:    make([][]any, len(datapoints)),
+			}
+			for i, datapoint := range datapoints {
+				table.Rows[i] = []any{int64(datapoint[1]), datapoint[0]}
+			}
+			responses = append(responses, table)
+		} else {
+			responses = append(responses, GrafanaTimeSeries{Target: target.Target, Datapoints: datapoints})
+		}
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// PostGrafanaAnnotations returns the annotations in the request's time range.
+// The service records no halts or alerts yet, so there are none.
+func PostGrafanaAnnotations(c *gin.Context) {
+	var request GrafanaAnnotationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.BadRequestError(c, fmt.Errorf("invalid annotation request: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, []GrafanaAnnotation{})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// parseGrafanaTarget splits a 'DATASET:TICKER:FIELD' target, where FIELD defaults to close.
+// Returns an error if it is malformed.
+func parseGrafanaTarget(target string) (dataset string, ticker string, field string, err error) {
+	parts := strings.Split(target, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", fmt.Errorf("invalid target: %s. Must be DATASET:TICKER:FIELD", target)
+	}
+	field = grafanaDefaultField
+	if len(parts) == 3 && parts[2] != "" {
+		field = strings.ToLower(parts[2])
+	}
+	if _, ok := grafanaFieldQueries[field]; !ok {
+		return "", "", "", fmt.Errorf("invalid target field: %s. Must be one of %s", field, strings.Join(grafanaFields, ", "))
+	}
+	return parts[0], parts[1], field, nil
+}
+
+// grafanaBucketInterval returns the bucket size of a field for the request, in whole seconds
+func grafanaBucketInterval(request GrafanaQueryRequest, field string) time.Duration {
+	interval := time.Duration(request.IntervalMs) * time.Millisecond
+	if request.MaxDataPoints > 0 {
+		interval = max(interval, request.Range.To.Sub(request.Range.From)/time.Duration(request.MaxDataPoints))
+	}
+	if slices.Contains(grafanaCandleFields, field) {
+		interval = max(interval, grafanaMinCandleInterval)
+	} else {
+		interval = max(interval, grafanaMinTradeInterval)
+	}
+	return interval.Truncate(time.Second)
+}
+
+// queryGrafanaDatapoints selects a field of the ticker in buckets of interval over the time range.
+// Returns the datapoints as [value, milliseconds from the epoch] and an error, if any.
+func queryGrafanaDatapoints(ctx context.Context, ticker string, field string, interval time.Duration, startTime time.Time, endTime time.Time) (datapoints [][2]float64, err error) {
+	seconds := int64(interval / time.Second)
+	ctx, span := middleware.StartQuerySpan(ctx, "query grafana "+field, grafanaFieldQueries[field])
+	defer func() { middleware.EndQuerySpan(span, len(datapoints), err) }()
+	rows, err := gDuckdbConn.QueryContext(ctx, grafanaFieldQueries[field],
+		seconds, seconds, ticker, startTime.Unix(), endTime.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	datapoints = [][2]float64{}
+	for rows.Next() {
+		var bucket int64
+		var value sql.NullFloat64 // vwap is NULL for buckets of zero shares
+		if err := rows.Scan(&bucket, &value); err != nil {
+			return nil, err
+		}
+		if value.Valid {
+			datapoints = append(datapoints, [2]float64{value.Float64, float64(bucket * 1000)})
+		}
+	}
+	return datapoints, rows.Err()
+}
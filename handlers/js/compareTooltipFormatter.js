@@ -0,0 +1,20 @@
+function (params) {
+    // one row per series, at the hovered timestamp
+    if (params.length === 0) {
+        return '';
+    }
+    let tooltip = new Date(1000*params[0].value.ts).toLocaleString('en-US') + '<table>';
+    for (let i = 0; i < params.length; i++) {
+        let param = params[i];
+        let field = param.dimensionNames[param.encode.y[0]];
+        let value = param.value[field];
+        if (value === null || value === undefined) {
+            continue;
+        }
+        // percent-change fields are prefixed with 'pct_'; the ratio is unitless
+        let valueStr = field.startsWith('pct_') ? value.toFixed(2) + '%' : value.toFixed(4);
+        tooltip += `<tr><td>${param.marker}${param.seriesName}</td><td>${valueStr}</td></tr>`;
+    }
+    tooltip += '</table>';
+    return tooltip;
+}
@@ -0,0 +1,175 @@
+function (chart, config) {
+    /* Live-updates a candlestick chart from the Server-Sent Events stream.
+     * Trades update the forming candle and the minute VWAP and its moving averages;
+     * ohlcv-1m candles replace the forming candle when its interval closes,
+     * and refetch the server's indicators, if any.
+     * NOTE: this is flattened to one line, so only block comments and explicit semicolons */
+    if (!window.EventSource) {
+        return;
+    }
+
+    let option = chart.getOption();
+    let candles = option.dataset[0].source.slice();
+    let stats = option.dataset[1].source.slice();
+    let statIndex = {};
+    for (let i = 0; i < stats.length; i++) {
+        statIndex[stats[i].ts] = i;
+    }
+
+    /* indicator values by candle timestamp, and the null row of every indicator field */
+    let indicatorRows = (option.dataset.length > 2) ? option.dataset[2].source : [];
+    let indicatorValues = {};
+    let indicatorNulls = {};
+    for (let i = 0; i < indicatorRows.length; i++) {
+        indicatorValues[indicatorRows[i].ts] = indicatorRows[i];
+    }
+    if (indicatorRows.length > 0) {
+        for (let field in indicatorRows[0]) {
+            if (field !== 'ts') {
+                indicatorNulls[field] = null;
+            }
+        }
+    }
+
+    function updateMovingAverages(idx) {
+        for (let j = 0; j < config.movingAverages.length; j++) {
+            let ma = config.movingAverages[j];
+            let from = Math.max(0, idx - ma.rows + 1);
+            let sum = 0;
+            for (let k = from; k <= idx; k++) {
+                sum += stats[k].vwap;
+            }
+            stats[idx][ma.field] = sum / (idx - from + 1);
+        }
+    }
+
+    function onTrade(trade) {
+        let minute = Math.floor(trade.ts / 60) * 60;
+
+        let last = candles.length > 0 ? candles[candles.length - 1] : null;
+        if (last === null || last.ts < minute) {
+            candles.push({ts: minute, ns: 0, pub: trade.pub, open: trade.px, high: trade.px,
+                low: trade.px, close: trade.px, volume: trade.sz, provisional: true});
+        } else if (last.ts === minute && last.provisional) {
+            last.high = Math.max(last.high, trade.px);
+            last.low = Math.min(last.low, trade.px);
+            last.close = trade.px;
+            last.volume += trade.sz;
+        }
+
+        if (!config.tradeStats) {
+            return;
+        }
+        let idx = statIndex[minute];
+        if (idx === undefined) {
+            if (stats.length > 0 && stats[stats.length - 1].ts > minute) {
+                return;
+            }
+            stats.push({ts: minute, vwap: trade.px, volume: trade.sz, pv: trade.px * trade.sz});
+            idx = stats.length - 1;
+            statIndex[minute] = idx;
+        } else {
+            let stat = stats[idx];
+            let pv = (stat.pv !== undefined) ? stat.pv : stat.vwap * stat.volume;
+            stat.pv = pv + trade.px * trade.sz;
+            stat.volume += trade.sz;
+            stat.vwap = stat.pv / stat.volume;
+        }
+        updateMovingAverages(idx);
+    }
+
+    function onCandle(candle) {
+        for (let i = candles.length - 1; i >= 0; i--) {
+            if (candles[i].ts === candle.ts) {
+                candles[i] = candle;
+                return;
+            }
+            if (candles[i].ts < candle.ts) {
+                candles.splice(i + 1, 0, candle);
+                return;
+            }
+        }
+        candles.unshift(candle);
+    }
+
+    let fetching = false;
+    let refetch = false;
+    function refreshIndicators() {
+        if (!config.indicatorsUrl) {
+            return;
+        }
+        if (fetching) {
+            refetch = true;
+            return;
+        }
+        fetching = true;
+        fetch(config.indicatorsUrl).then(function (resp) {
+            return resp.ok ? resp.json() : [];
+        }).then(function (seriesList) {
+            indicatorValues = {};
+            for (let i = 0; i < seriesList.length; i++) {
+                let series = seriesList[i];
+                indicatorNulls[series.name] = null;
+                for (let j = 0; j < series.points.length; j++) {
+                    let point = series.points[j];
+                    if (indicatorValues[point.ts] === undefined) {
+                        indicatorValues[point.ts] = {ts: point.ts};
+                    }
+                    indicatorValues[point.ts][series.name] = point.value;
+                }
+            }
+            scheduleRender();
+        }).catch(function () {
+            /* keep the previous values; the next candle retries */
+        }).finally(function () {
+            fetching = false;
+            if (refetch) {
+                refetch = false;
+                refreshIndicators();
+            }
+        });
+    }
+
+    function buildIndicatorRows() {
+        let rows = [];
+        for (let i = 0; i < candles.length; i++) {
+            rows.push(Object.assign({ts: candles[i].ts}, indicatorNulls, indicatorValues[candles[i].ts]));
+        }
+        return rows;
+    }
+
+    let pending = false;
+    function render() {
+        pending = false;
+        let zoom = chart.getOption().dataZoom[0];
+        let span = zoom.endValue - zoom.startValue;
+        let followLatest = zoom.end >= 99.9;
+        let prevLength = chart.getOption().dataset[0].source.length;
+
+        chart.setOption({dataset: [{source: candles}, {source: stats}, {source: buildIndicatorRows()}]});
+
+        if (followLatest && zoom.start > 0 && candles.length > prevLength) {
+            chart.dispatchAction({type: 'dataZoom', startValue: candles.length - 1 - span, endValue: candles.length - 1});
+        } else if (!followLatest) {
+            chart.dispatchAction({type: 'dataZoom', startValue: zoom.startValue, endValue: zoom.endValue});
+        }
+    }
+    function scheduleRender() {
+        if (!pending) {
+            pending = true;
+            setTimeout(render, config.renderIntervalMs);
+        }
+    }
+
+    let url = config.streamUrl + '?schemas=trades,ohlcv-1m&lastEventId=' + encodeURIComponent(config.lastEventId);
+    let source = new EventSource(url);
+    source.addEventListener('trades', function (e) {
+        onTrade(JSON.parse(e.data));
+        scheduleRender();
+    });
+    source.addEventListener('ohlcv-1m', function (e) {
+        onCandle(JSON.parse(e.data));
+        refreshIndicators();
+        scheduleRender();
+    });
+}
@@ -24,6 +24,14 @@ function (params, ticket) {
         return str
     }
 
+    function addTrade(param) {
+        let data = param.data;
+        let str = `${param.marker}${param.seriesName}<br>${makeDateStr(1000*data.ts)}<br>`;
+        str += `<tr><td>price:</td><td>${data.px.toFixed(2)}</td></tr>`;
+        str += `<tr><td>shares:</td><td>${data.sz}</td></tr>`;
+        return str
+    }
+
     function addLine(param) {
         let data = param.data;
         if (param.axisDim === 'y') {
@@ -32,7 +40,7 @@ function (params, ticket) {
         return ''
     }
 
-    // handle any candlestick series, if present
+    // handle any candlestick or trade series, if present
     let tooltip = `<table>`;
     for (let i = 0; i < params.length; i++) {
         let param = params[i];
@@ -40,6 +48,10 @@ function (params, ticket) {
             tooltip += addCandle(param);
             break;
         }
+        if (param.componentSubType === 'scatter') {
+            tooltip += addTrade(param);
+            break;
+        }
     }
 
     // now handle the line series, if present
@@ -0,0 +1,44 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ReloadFunc re-reads the server's configuration and applies its changes.
+// Returns descriptions of the changes applied, and an error if any.
+type ReloadFunc func() ([]string, error)
+
+// ReloadResponse is the response of a successful config reload
+type ReloadResponse struct {
+	Changes []string `json:"changes"` // Changes applied, empty if there were none
+}
+
+// PostAdminReload re-reads the server's config and applies its changes, like SIGHUP.
+// Without authentication, it is only served to loopback clients.
+func PostAdminReload(c *gin.Context) {
+	if ip := net.ParseIP(c.RemoteIP()); !middleware.AuthEnabled() && (ip == nil || !ip.IsLoopback()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "without authentication, admin routes are only served to loopback clients"})
+		return
+	}
+	reload := getReloadFunc()
+	if reload == nil {
+		c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"message": "config reload is not available"})
+		return
+	}
+
+	changes, err := reload()
+	if err != nil {
+		middleware.InternalError(c, "failed to reload config: "+err.Error(), err)
+		return
+	}
+	if changes == nil {
+		changes = []string{}
+	}
+	c.JSON(http.StatusOK, ReloadResponse{Changes: changes})
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+	"github.com/marcboeker/go-duckdb/v2"
+	"go.uber.org/zap"
+)
+
+// QueryConfig is the configuration of the ad-hoc SQL query endpoint
+type QueryConfig struct {
+	Tables    []string      `yaml:"tables"`     // Tables and views open to queries (default: middleware.DefaultQueryTables)
+	MaxRows   int           `yaml:"max_rows"`   // Maximum rows a query returns; requests may lower it
+	Timeout   time.Duration `yaml:"timeout"`    // Maximum time to execute a query
+	CacheSize int           `yaml:"cache_size"` // Maximum results cached, 0 disables the cache
+	CacheTTL  time.Duration `yaml:"cache_ttl"`  // Time a result is cached, 0 disables the cache
+}
+
+// DefaultQueryConfig is the ad-hoc SQL query configuration unless SetQueryConfig is called
+var DefaultQueryConfig = QueryConfig{
+	Tables:    middleware.DefaultQueryTables,
+	MaxRows:   10_000,
+	Timeout:   30 * time.Second,
+	CacheSize: 64,
+	CacheTTL:  10 * time.Second,
+}
+
+// Global storage of the ad-hoc SQL query configuration, result cache and guard
+var (
+	gQueryMutex  sync.Mutex
+	gQueryConfig *QueryConfig
+	gQueryCache  *middleware.QueryCache
+	gQueryGuard  *middleware.SQLGuard // built on first use, as it parses with the DuckDB connection of Register
+)
+
+// queryFormats are the ad-hoc SQL query response formats, by 'format' parameter
+var queryFormats = map[string]string{
+	"json":  gin.MIMEJSON,
+	"csv":   "text/csv",
+	"arrow": arrowStreamMIME,
+}
+
+// SetQueryConfig sets the ad-hoc SQL query configuration, clearing the result cache and guard
+func SetQueryConfig(config QueryConfig) {
+	if len(config.Tables) == 0 {
+		config.Tables = DefaultQueryConfig.Tables
+	}
+	if config.MaxRows <= 0 {
+		config.MaxRows = DefaultQueryConfig.MaxRows
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultQueryConfig.Timeout
+	}
+	gQueryMutex.Lock()
+	gQueryConfig = &config
+	gQueryCache = middleware.NewQueryCache(config.CacheSize, config.CacheTTL)
+	gQueryGuard = nil
+	gQueryMutex.Unlock()
+}
+
+// getQueryConfig returns the ad-hoc SQL query configuration, result cache and guard of the allowed tables,
+// setting the default configuration if none was set
+func getQueryConfig() (QueryConfig, *middleware.QueryCache, *middleware.SQLGuard) {
+	gQueryMutex.Lock()
+	defer gQueryMutex.Unlock()
+	if gQueryConfig == nil {
+		config := DefaultQueryConfig
+		gQueryConfig = &config
+		gQueryCache = middleware.NewQueryCache(config.CacheSize, config.CacheTTL)
+	}
+	if gQueryGuard == nil {
+		gQueryGuard = middleware.NewSQLGuard(gDuckdbConn, gQueryConfig.Tables)
+	}
+	return *gQueryConfig, gQueryCache, gQueryGuard
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Runs a read-only SQL query and returns its result.
+//
+//	@Summary		Run an ad-hoc SQL query
+//	@ID				PostQuery
+//	@Description	Runs a single SELECT statement of the allowed tables in a read-only transaction, returning its result as JSON, CSV or an Arrow IPC stream.
+//	@Description	The format is the 'format' parameter, otherwise negotiated with the Accept header.
+//	@Description	Queries are cancelled at the server's time limit, and at most the server's row limit is returned; the X-Truncated header is true if rows were dropped.
+//	@Description	Results are cached briefly; the X-Cache header is HIT if the result was cached.
+//	@Accept			json
+//	@Produce		json
+//	@Produce		text/csv
+//	@Produce		application/vnd.apache.arrow.stream
+//	@Param			request body sdk.QueryRequest	true	"SQL query"
+//	@Param			format query string	false	"(optional) result format - default is negotiated, otherwise json" Enums(json, csv, arrow)
+//	@Success		200	{object}	sdk.QueryResult
+//	@Failure		400	{object}	error "query not allowed or invalid"
+//	@Failure		403	{object}	error "API key may not access every dataset"
+//	@Failure		500	{object}	error
+//	@Failure		504	{object}	error "query timed out"
+//	@Security		ApiKeyAuth
+//	@Router			/query [post]
+func PostQuery(c *gin.Context) {
+	var request sdk.QueryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.BadRequestError(c, fmt.Errorf("invalid query request: %w", err))
+		return
+	}
+	if strings.TrimSpace(request.SQL) == "" {
+		middleware.BadRequestError(c, fmt.Errorf("'sql' cannot be empty"))
+		return
+	}
+	// the tables hold every dataset's data
+	for _, liveClient := range getLiveDataClients() {
+		if !middleware.DatasetAllowed(c, liveClient.Dataset()) {
+			middleware.ForbiddenError(c, fmt.Errorf("API key may not access dataset %s, so cannot query its tables", liveClient.Dataset()))
+			return
+		}
+	}
+
+	formatName := c.Query("format")
+	if formatName == "" {
+		switch c.NegotiateFormat(gin.MIMEJSON, queryFormats["csv"], queryFormats["arrow"]) {
+		case queryFormats["csv"]:
+			formatName = "csv"
+		case queryFormats["arrow"]:
+			formatName = "arrow"
+		default:
+			formatName = "json"
+		}
+	}
+	if _, ok := queryFormats[formatName]; !ok {
+		middleware.BadRequestError(c, fmt.Errorf("invalid 'format' value: %s. Must be json, csv or arrow", formatName))
+		return
+	}
+
+	config, cache, guard := getQueryConfig()
+	maxRows := config.MaxRows
+	if request.MaxRows < 0 {
+		middleware.BadRequestError(c, fmt.Errorf("invalid 'max_rows' value: %d. Must be positive", request.MaxRows))
+		return
+	} else if request.MaxRows > 0 {
+		maxRows = min(maxRows, request.MaxRows)
+	}
+
+	// Each user's queries are logged, whether or not they succeed
+	startTime := time.Now()
+	logger := middleware.GetGinLogger(c).With(zap.String("user", requestUser(c)), zap.String("sql", request.SQL))
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), config.Timeout)
+	defer cancel()
+	if err := guard.Check(ctx, request.SQL); err != nil {
+		logger.Info("ad-hoc query rejected", zap.Error(err))
+		if errors.Is(err, middleware.ErrQueryNotAllowed) {
+			middleware.BadRequestError(c, err)
+		} else {
+			middleware.InternalError(c, "query check failed", err)
+		}
+		return
+	}
+
+	cacheKey := strconv.Itoa(maxRows) + "\x00" + request.SQL
+	var result *sdk.QueryResult
+	cachedResult, cached := cache.Get(cacheKey)
+	if cached {
+		result = cachedResult.(*sdk.QueryResult)
+		c.Header("X-Cache", "HIT")
+	} else {
+		var err error
+		result, err = runReadOnlyQuery(ctx, request.SQL, maxRows)
+		if err != nil {
+			logger.Info("ad-hoc query failed", zap.Duration("elapsed", time.Since(startTime)), zap.Error(err))
+			var duckdbErr *duckdb.Error
+			switch {
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				middleware.GatewayTimeoutError(c, fmt.Errorf("query exceeded the time limit of %s", config.Timeout))
+			case errors.As(err, &duckdbErr):
+				middleware.BadRequestError(c, err)
+			default:
+				middleware.InternalError(c, "query failed", err)
+			}
+			return
+		}
+		cache.Put(cacheKey, result)
+		c.Header("X-Cache", "MISS")
+	}
+	logger.Info("ad-hoc query", zap.Int("rows", result.RowCount), zap.Bool("truncated", result.Truncated),
+		zap.Bool("cached", cached), zap.Duration("elapsed", time.Since(startTime)))
+
+	// Transmit the result
+	c.Header("X-Row-Count", strconv.Itoa(result.RowCount))
+	c.Header("X-Truncated", strconv.FormatBool(result.Truncated))
+	switch formatName {
+	case "csv":
+		c.Header("Content-Type", queryFormats["csv"])
+		if err := writeQueryResultCSV(c.Writer, result); err != nil {
+			c.Error(err)
+			c.Abort()
+		}
+	case "arrow":
+		c.Header("Content-Type", queryFormats["arrow"])
+		if err := writeQueryResultArrow(c.Writer, result); err != nil {
+			c.Error(err)
+			c.Abort()
+		}
+	default:
+		response := *result
+		response.Cached = cached
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// requestUser returns the authenticated user or API key ID of the request, or its client IP if anonymous
+func requestUser(c *gin.Context) string {
+	if key := middleware.GetAPIKey(c); key != nil {
+		return key.ID
+	}
+	if user := c.GetString(gin.AuthUserKey); user != "" {
+		return user
+	}
+	return c.ClientIP()
+}
+
+// runReadOnlyQuery runs the query in a read-only transaction on a pooled connection of the
+// shared database, returning at most maxRows rows.  The query is interrupted when ctx is done,
+// or once maxRows rows are read.  Returns the result and an error, if any.
+//
+// A separate read-only handle would not do: a second DuckDB instance of the --db file does not
+// see the live data the writer has not checkpointed, and an in-memory database cannot be shared.
+// The read-only transaction is enough, as DuckDB fails any write attempted within it,
+// on top of the SQLGuard only admitting SELECT statements.
+func runReadOnlyQuery(ctx context.Context, queryStr string, maxRows int) (result *sdk.QueryResult, err error) {
+	ctx, span := middleware.StartQuerySpan(ctx, "query ad-hoc sql", queryStr)
+	defer func() {
+		rowCount := 0
+		if result != nil {
+			rowCount = result.RowCount
+		}
+		middleware.EndQuerySpan(span, rowCount, err)
+	}()
+	conn, err := gDuckdbConn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION READ ONLY;"); err != nil {
+		return nil, fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+	defer func() {
+		if _, rollbackErr := conn.ExecContext(context.Background(), "ROLLBACK;"); rollbackErr != nil {
+			// never return a connection in a read-only transaction to the pool, it would block writes
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+			if err == nil {
+				err = fmt.Errorf("failed to end read-only transaction: %w", rollbackErr)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	rows, err := conn.QueryContext(ctx, queryStr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	result = &sdk.QueryResult{Columns: make([]sdk.QueryColumn, len(columnTypes)), Rows: [][]any{}}
+	for i, columnType := range columnTypes {
+		result.Columns[i] = sdk.QueryColumn{Name: columnType.Name(), Type: columnType.DatabaseTypeName()}
+	}
+
+	for rows.Next() {
+		if len(result.Rows) == maxRows {
+			result.Truncated = true
+			cancel() // interrupt the rest of the query
+			break
+		}
+		values := make([]any, len(columnTypes))
+		valuePtrs := make([]any, len(values))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			values[i] = normalizeQueryValue(result.Columns[i].Type, value)
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil && !result.Truncated {
+		return nil, err
+	}
+	result.RowCount = len(result.Rows)
+	return result, nil
+}
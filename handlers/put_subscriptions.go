@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Replaces the live subscriptions of a dataset.
+//
+//	@Summary		PUT live subscriptions
+//	@ID				PutSubscriptionsByDataset
+//	@Description	Replaces the symbols the dataset's live session ingests, without restarting it, and returns its new subscription.
+//	@Description	New symbols are subscribed from now.  Databento has no unsubscribe, so removed symbols are still received, but are no longer ingested or streamed.
+//	@Description	The change lasts until the server restarts, or a config reload changes the configured symbols.
+//	@Accept			json
+//	@Produce		json
+//	@Param			dataset path string	true	"DataBento dataset" example(DBEQ.BASIC)
+//	@Param			request body sdk.SubscriptionRequest	true	"Symbols to subscribe"
+//	@Success		200	{object}	sdk.Subscription
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error "dataset not found"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/subscriptions/{dataset} [put]
+func PutSubscriptionsByDataset(c *gin.Context) {
+	dataset := c.Param("dataset")
+	var request sdk.SubscriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.BadRequestError(c, fmt.Errorf("invalid subscription request: %w", err))
+		return
+	}
+	for _, symbol := range request.Symbols {
+		if strings.TrimSpace(symbol) == "" {
+			middleware.BadRequestError(c, fmt.Errorf("'symbols' cannot contain an empty symbol"))
+			return
+		}
+	}
+	liveClient := getLiveDataClient(dataset)
+	if liveClient == nil {
+		middleware.NotFoundError(c, fmt.Errorf("dataset not found: %s", dataset))
+		return
+	}
+
+	added, removed, err := liveClient.SetSubscriptions(request.Symbols)
+	if err != nil {
+		middleware.InternalError(c, "failed to change subscriptions: "+err.Error(), err)
+		return
+	}
+	middleware.GetGinLogger(c).Info("subscriptions changed", zap.String("dataset", dataset),
+		zap.Strings("added", added), zap.Strings("removed", removed))
+	c.JSON(http.StatusOK, sdk.Subscription{
+		Dataset: dataset,
+		Schemas: liveClient.Schemas(),
+		Symbols: liveClient.Subscriptions(),
+	})
+}
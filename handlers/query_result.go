@@ -0,0 +1,254 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/marcboeker/go-duckdb/v2"
+)
+
+// baseTypeName returns a DuckDB type name without its parameters, e.g. "DECIMAL" for "DECIMAL(18,3)"
+func baseTypeName(typeName string) string {
+	if i := strings.IndexByte(typeName, '('); i >= 0 {
+		return typeName[:i]
+	}
+	return typeName
+}
+
+// normalizeQueryValue converts a value scanned from DuckDB to one that encodes as JSON:
+// DECIMALs become float64, UUIDs strings, and MAP keys strings
+func normalizeQueryValue(typeName string, value any) any {
+	switch v := value.(type) {
+	case duckdb.Decimal:
+		return v.Float64()
+	case []byte:
+		if baseTypeName(typeName) == "UUID" && len(v) == 16 {
+			uuid := duckdb.UUID(v)
+			return uuid.String()
+		}
+	case duckdb.Map:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeQueryValue("", value)
+		}
+		return m
+	case map[string]any:
+		for key, value := range v {
+			v[key] = normalizeQueryValue("", value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = normalizeQueryValue("", value)
+		}
+	}
+	return value
+}
+
+// queryValueText returns the text of a result value, as written to CSV
+func queryValueText(typeName string, value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		if baseTypeName(typeName) == "DATE" {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return "\\x" + hex.EncodeToString(v)
+	case *big.Int:
+		return v.String()
+	case bool, int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	}
+	if encoded, err := json.Marshal(value); err == nil {
+		return string(encoded)
+	}
+	return fmt.Sprint(value)
+}
+
+// writeQueryResultCSV writes the result as CSV with a header row.  Returns an error, if any.
+func writeQueryResultCSV(w io.Writer, result *sdk.QueryResult) error {
+	writer := csv.NewWriter(w)
+	record := make([]string, len(result.Columns))
+	for i, column := range result.Columns {
+		record[i] = column.Name
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		for i, value := range row {
+			record[i] = queryValueText(result.Columns[i].Type, value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// queryArrowTypes are the Arrow types of DuckDB types, by DuckDB type name.
+// DECIMALs are normalized to float64; other types, such as LIST and STRUCT, are written as JSON text.
+var queryArrowTypes = map[string]arrow.DataType{
+	"BOOLEAN":      arrow.FixedWidthTypes.Boolean,
+	"TINYINT":      arrow.PrimitiveTypes.Int8,
+	"SMALLINT":     arrow.PrimitiveTypes.Int16,
+	"INTEGER":      arrow.PrimitiveTypes.Int32,
+	"BIGINT":       arrow.PrimitiveTypes.Int64,
+	"UTINYINT":     arrow.PrimitiveTypes.Uint8,
+	"USMALLINT":    arrow.PrimitiveTypes.Uint16,
+	"UINTEGER":     arrow.PrimitiveTypes.Uint32,
+	"UBIGINT":      arrow.PrimitiveTypes.Uint64,
+	"FLOAT":        arrow.PrimitiveTypes.Float32,
+	"DOUBLE":       arrow.PrimitiveTypes.Float64,
+	"DECIMAL":      arrow.PrimitiveTypes.Float64,
+	"VARCHAR":      arrow.BinaryTypes.String,
+	"BLOB":         arrow.BinaryTypes.Binary,
+	"DATE":         arrow.FixedWidthTypes.Date32,
+	"TIMESTAMP":    arrow.FixedWidthTypes.Timestamp_us,
+	"TIMESTAMP_S":  arrow.FixedWidthTypes.Timestamp_us,
+	"TIMESTAMP_MS": arrow.FixedWidthTypes.Timestamp_us,
+	"TIMESTAMP_NS": arrow.FixedWidthTypes.Timestamp_us,
+	"TIMESTAMPTZ":  &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"},
+}
+
+// writeQueryResultArrow writes the result as an Arrow IPC stream of one record batch.
+// The result is already scanned into Go values, so this needs no duckdb_arrow build tag.
+// Returns an error, if any.
+func writeQueryResultArrow(w io.Writer, result *sdk.QueryResult) error {
+	fields := make([]arrow.Field, len(result.Columns))
+	for i, column := range result.Columns {
+		dataType, ok := queryArrowTypes[baseTypeName(column.Type)]
+		if !ok {
+			dataType = arrow.BinaryTypes.String
+		}
+		fields[i] = arrow.Field{Name: column.Name, Type: dataType, Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+	for _, row := range result.Rows {
+		for i, value := range row {
+			if err := appendArrowValue(builder.Field(i), result.Columns[i].Type, value); err != nil {
+				return fmt.Errorf("column %s: %w", result.Columns[i].Name, err)
+			}
+		}
+	}
+	record := builder.NewRecord()
+	defer record.Release()
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(schema))
+	if err := writer.Write(record); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// appendArrowValue appends a result value of the DuckDB type to the Arrow builder.  Returns an error, if any.
+func appendArrowValue(builder array.Builder, typeName string, value any) error {
+	if value == nil {
+		builder.AppendNull()
+		return nil
+	}
+	var ok bool
+	switch b := builder.(type) {
+	case *array.BooleanBuilder:
+		var v bool
+		if v, ok = value.(bool); ok {
+			b.Append(v)
+		}
+	case *array.Int8Builder:
+		var v int8
+		if v, ok = value.(int8); ok {
+			b.Append(v)
+		}
+	case *array.Int16Builder:
+		var v int16
+		if v, ok = value.(int16); ok {
+			b.Append(v)
+		}
+	case *array.Int32Builder:
+		var v int32
+		if v, ok = value.(int32); ok {
+			b.Append(v)
+		}
+	case *array.Int64Builder:
+		var v int64
+		if v, ok = value.(int64); ok {
+			b.Append(v)
+		}
+	case *array.Uint8Builder:
+		var v uint8
+		if v, ok = value.(uint8); ok {
+			b.Append(v)
+		}
+	case *array.Uint16Builder:
+		var v uint16
+		if v, ok = value.(uint16); ok {
+			b.Append(v)
+		}
+	case *array.Uint32Builder:
+		var v uint32
+		if v, ok = value.(uint32); ok {
+			b.Append(v)
+		}
+	case *array.Uint64Builder:
+		var v uint64
+		if v, ok = value.(uint64); ok {
+			b.Append(v)
+		}
+	case *array.Float32Builder:
+		var v float32
+		if v, ok = value.(float32); ok {
+			b.Append(v)
+		}
+	case *array.Float64Builder:
+		var v float64
+		if v, ok = value.(float64); ok {
+			b.Append(v)
+		}
+	case *array.BinaryBuilder:
+		var v []byte
+		if v, ok = value.([]byte); ok {
+			b.Append(v)
+		}
+	case *array.Date32Builder:
+		var v time.Time
+		if v, ok = value.(time.Time); ok {
+			b.Append(arrow.Date32FromTime(v))
+		}
+	case *array.TimestampBuilder:
+		var v time.Time
+		if v, ok = value.(time.Time); ok {
+			b.Append(arrow.Timestamp(v.UnixMicro()))
+		}
+	case *array.StringBuilder:
+		b.Append(queryValueText(typeName, value))
+		ok = true
+	}
+	if !ok {
+		return fmt.Errorf("unexpected %T value for type %s", value, typeName)
+	}
+	return nil
+}
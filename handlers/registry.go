@@ -4,8 +4,14 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/NimbleMarkets/dbn-duckduck-goose/docs"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
 	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
 
 	"go.uber.org/zap"
@@ -20,6 +26,102 @@ var (
 	gDuckdbConn *sql.DB
 )
 
+// Global storage of live data clients, keyed by dataset
+var (
+	gLiveClientsMutex sync.RWMutex
+	gLiveClients      = make(map[string]*livedata.LiveDataClient)
+)
+
+// Global storage of the export scratch directory
+var (
+	gScratchDirMutex sync.Mutex
+	gScratchDir      *middleware.ScratchDir
+)
+
+// Global storage of the config reload function
+var (
+	gReloadFuncMutex sync.RWMutex
+	gReloadFunc      ReloadFunc
+)
+
+// Global signal that the streaming routes are closed
+var (
+	gStreamsClosed     = make(chan struct{})
+	gStreamsClosedOnce sync.Once
+)
+
+// ScratchAcquireTimeout is the time an export waits for a free slot of the scratch directory
+const ScratchAcquireTimeout = 5 * time.Second
+
+// SetScratchDir sets the private directory used for file exports, which must be set for them.
+// The caller closes it at shutdown.
+func SetScratchDir(scratchDir *middleware.ScratchDir) {
+	gScratchDirMutex.Lock()
+	gScratchDir = scratchDir
+	gScratchDirMutex.Unlock()
+}
+
+// getScratchDir returns the export scratch directory, or an error if none was set
+func getScratchDir() (*middleware.ScratchDir, error) {
+	gScratchDirMutex.Lock()
+	defer gScratchDirMutex.Unlock()
+	if gScratchDir == nil {
+		return nil, fmt.Errorf("no export scratch directory is set")
+	}
+	return gScratchDir, nil
+}
+
+// SetReloadFunc sets the function of the /admin/reload route
+func SetReloadFunc(reload ReloadFunc) {
+	gReloadFuncMutex.Lock()
+	gReloadFunc = reload
+	gReloadFuncMutex.Unlock()
+}
+
+// getReloadFunc returns the config reload function, or nil if none was set
+func getReloadFunc() ReloadFunc {
+	gReloadFuncMutex.RLock()
+	defer gReloadFuncMutex.RUnlock()
+	return gReloadFunc
+}
+
+// CloseStreams ends the SSE streams and WebSocket connections of the streaming routes,
+// and any started later, for server shutdown.  http.Server.Shutdown would otherwise wait
+// for the streams until its deadline, and does not close hijacked WebSocket connections.
+func CloseStreams() {
+	gStreamsClosedOnce.Do(func() {
+		close(gStreamsClosed)
+	})
+}
+
+// AddLiveDataClient makes a LiveDataClient's dataset available to the streaming routes
+func AddLiveDataClient(client *livedata.LiveDataClient) {
+	gLiveClientsMutex.Lock()
+	gLiveClients[client.Dataset()] = client
+	gLiveClientsMutex.Unlock()
+}
+
+// getLiveDataClient returns the LiveDataClient for the dataset, or nil if there is none
+func getLiveDataClient(dataset string) *livedata.LiveDataClient {
+	gLiveClientsMutex.RLock()
+	defer gLiveClientsMutex.RUnlock()
+	return gLiveClients[dataset]
+}
+
+// getLiveDataClients returns the LiveDataClients, sorted by dataset
+func getLiveDataClients() []*livedata.LiveDataClient {
+	gLiveClientsMutex.RLock()
+	defer gLiveClientsMutex.RUnlock()
+	clients := make([]*livedata.LiveDataClient, 0, len(gLiveClients))
+	for _, client := range gLiveClients {
+		clients = append(clients, client)
+	}
+	slices.SortFunc(clients, func(a, b *livedata.LiveDataClient) int {
+		return strings.Compare(a.Dataset(), b.Dataset())
+	})
+	return clients
+}
+
 // Register registers our custom Appplication with the passed gin.Engine
 func Register(hostPort string, conn *sql.DB, r *gin.Engine, logger *zap.Logger) *gin.Engine {
 	// Set module DuckDB connection
@@ -34,6 +136,18 @@ func Register(hostPort string, conn *sql.DB, r *gin.Engine, logger *zap.Logger)
 
 	// Register our middleware suites
 	RegisterSnapshotApi(v1)
+	RegisterStreamApi(v1)
+	RegisterSubscriptionsApi(r)
+	RegisterQueryApi(r)
+	RegisterGrafanaApi(r)
+	RegisterWebSocketApi(r)
+	RegisterChartAssets(r)
+	RegisterDashboard(r)
+	RegisterAdminApi(r)
+	RegisterHealthApi(r)
+	if echartsAsset() == nil {
+		logger.Warn("ECharts is not embedded in this build, charts will load it from the CDN")
+	}
 	return r
 }
 
@@ -49,16 +163,95 @@ func RegisterOpenApiDocs(r *gin.Engine) *gin.Engine {
 
 // RegisterSnapshotApi registers the snapshot API routes
 func RegisterSnapshotApi(r *gin.RouterGroup) *gin.RouterGroup {
+	read := middleware.RequireScope(middleware.ScopeRead)
 	// last-trades
-	g := r.Group("/last-trades")
+	g := r.Group("/last-trades", read)
 	g.GET("json/:dataset/:ticker", GetLastTradesByDatasetAndTicker)
 	g.GET("csv/:dataset/:ticker", GetLastTradesByDatasetAndTickerCSV)
 	g.GET("excel/:dataset/:ticker", GetLastTradesByDatasetAndTickerExcel)
 	// candles
-	g2 := r.Group("/candles")
+	g2 := r.Group("/candles", read)
 	g2.GET("/:dataset/:ticker", GetOhlcvByDatasetAndTicker)
 	// charts
-	g3 := r.Group("/charts")
+	g3 := r.Group("/charts", read)
 	g3.GET("candles/:dataset/:ticker", GetCandleChartByDatasetAndTicker)
+	g3.GET("compare/:dataset", GetCompareChartByDataset)
+	g3.GET("volume-profile/:dataset/:ticker", GetVolumeProfileChartByDatasetAndTicker)
+	g3.GET("tape/:dataset/:ticker", GetTapeChartByDatasetAndTicker)
+	// indicators
+	g4 := r.Group("/indicators", read)
+	g4.GET("/:dataset/:ticker", GetIndicatorsByDatasetAndTicker)
+	// export
+	g5 := r.Group("/export", middleware.RequireScope(middleware.ScopeExport))
+	g5.GET("/:dataset/:ticker", GetExportByDatasetAndTicker)
+	// subscriptions
+	r.GET("/subscriptions", read, GetSubscriptions)
+	// status
+	r.GET("/status", read, GetStatus)
+	return r
+}
+
+// RegisterSubscriptionsApi registers the route changing live subscriptions, outside the /api/v1 group as it accepts PUT
+func RegisterSubscriptionsApi(r *gin.Engine) *gin.Engine {
+	g := r.Group("/api/v1/subscriptions", middleware.CorsOptionHandlerWithVerbs("PUT"),
+		middleware.RequireScope(middleware.ScopeSubscriptions))
+	g.PUT("/:dataset", PutSubscriptionsByDataset)
+	g.OPTIONS("/:dataset", middleware.CorsOptionHandlerWithVerbs("PUT"))
+	return r
+}
+
+// RegisterQueryApi registers the ad-hoc SQL query route, outside the /api/v1 group as it accepts POST
+func RegisterQueryApi(r *gin.Engine) *gin.Engine {
+	g := r.Group("/api/v1/query", middleware.CorsOptionHandlerWithVerbs("POST"),
+		middleware.RequireScope(middleware.ScopeRead))
+	g.POST("", PostQuery)
+	g.OPTIONS("", middleware.CorsOptionHandlerWithVerbs("POST"))
+	return r
+}
+
+// RegisterGrafanaApi registers the /grafana JSON datasource routes
+func RegisterGrafanaApi(r *gin.Engine) *gin.Engine {
+	g := r.Group("/grafana", middleware.CorsOptionHandlerWithVerbs("GET", "POST"),
+		middleware.RequireScope(middleware.ScopeRead))
+	g.GET("", GetGrafanaTest)
+	g.GET("/", GetGrafanaTest)
+	g.POST("/search", PostGrafanaSearch)
+	g.POST("/query", PostGrafanaQuery)
+	g.POST("/annotations", PostGrafanaAnnotations)
+	g.OPTIONS("/*any", middleware.CorsOptionHandlerWithVerbs("GET", "POST"))
+	return r
+}
+
+// RegisterDashboard registers the /dashboard HTML pages
+func RegisterDashboard(r *gin.Engine) *gin.Engine {
+	r.GET("/dashboard/:dataset", middleware.RequireScope(middleware.ScopeRead), GetDashboardByDataset)
+	return r
+}
+
+// RegisterAdminApi registers the /admin routes, which need the admin scope if authentication
+// is enabled, and are otherwise only served to loopback clients
+func RegisterAdminApi(r *gin.Engine) *gin.Engine {
+	g := r.Group("/admin", middleware.RequireScope(middleware.ScopeAdmin))
+	g.POST("/reload", PostAdminReload)
+	return r
+}
+
+// RegisterHealthApi registers the /healthz liveness and /readyz readiness probes
+func RegisterHealthApi(r *gin.Engine) *gin.Engine {
+	r.GET("/healthz", GetHealthz)
+	r.GET("/readyz", GetReadyz)
+	return r
+}
+
+// RegisterWebSocketApi registers the /ws/v1 WebSocket market-data gateway
+func RegisterWebSocketApi(r *gin.Engine) *gin.Engine {
+	r.GET("/ws/v1", middleware.RequireScope(middleware.ScopeRead), GetWebSocketStream)
+	return r
+}
+
+// RegisterStreamApi registers the streaming API routes
+func RegisterStreamApi(r *gin.RouterGroup) *gin.RouterGroup {
+	g := r.Group("/stream", middleware.RequireScope(middleware.ScopeRead))
+	g.GET("/:dataset/:ticker", GetStreamByDatasetAndTicker)
 	return r
 }
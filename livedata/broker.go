@@ -0,0 +1,134 @@
+// Copyright 2025 Neomantra Corp
+
+package livedata
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+)
+
+// ErrSlowConsumer is the error of a Subscription that was dropped because its buffer filled
+var ErrSlowConsumer = errors.New("slow consumer: subscription buffer full")
+
+// Event is a decoded record published to a Broker after it is ingested
+type Event struct {
+	ID      uint64         // Event ID, the record's ts_event as nanoseconds from the epoch
+	Seq     uint32         // Ordinal of the record among the Ticker's records of the Schema with the same ID
+	Dataset string         // Databento dataset of the record
+	Schema  string         // DBN schema of the record, e.g. "trades" or "ohlcv-1m"
+	Ticker  string         // Ticker of the record
+	Trade   *sdk.TradeTick // Trade datum, set for SchemaTrades events
+	Candle  *sdk.Candle    // Candle datum, set for SchemaOhlcv1m events
+}
+
+// EventFilter returns true if the Event should be delivered to a Subscription.
+type EventFilter func(ev *Event) bool
+
+// Subscription receives Events from a Broker on its channel C.
+// C is closed when the Subscription is closed or dropped; check Err() for the reason.
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	filter EventFilter
+	err    error
+	closed bool
+}
+
+// Err returns ErrSlowConsumer if the Subscription was dropped by its Broker, otherwise nil.
+// Only valid after C is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Broker is an in-process publish/subscribe hub for ingested Events.
+// Publish never blocks; a Subscription whose buffer is full is dropped.
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBroker returns a new, empty Broker
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a Subscription with a buffer of bufSize Events.
+// A nil filter receives every Event.
+func (b *Broker) Subscribe(bufSize int, filter EventFilter) *Subscription {
+	if bufSize <= 0 {
+		bufSize = 1
+	}
+	ch := make(chan Event, bufSize)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe removes the Subscription from the Broker and closes its channel.
+// It is safe to call more than once.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	b.closeLocked(sub, nil)
+	b.mu.Unlock()
+}
+
+// Publish delivers the Event to every matching Subscription.
+func (b *Broker) Publish(ev Event) {
+	var slow []*Subscription
+	b.mu.RLock()
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(&ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	if len(slow) != 0 {
+		b.mu.Lock()
+		for _, sub := range slow {
+			b.closeLocked(sub, ErrSlowConsumer)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// NumSubscriptions returns the number of active Subscriptions
+func (b *Broker) NumSubscriptions() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// QueueDepth returns the number of Events buffered for the active Subscriptions
+func (b *Broker) QueueDepth() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	depth := 0
+	for sub := range b.subs {
+		depth += len(sub.ch)
+	}
+	return depth
+}
+
+// closeLocked removes and closes the Subscription. b.mu must be held.
+func (b *Broker) closeLocked(sub *Subscription, err error) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	sub.err = err
+	delete(b.subs, sub)
+	close(sub.ch)
+}
@@ -8,20 +8,42 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
 
 	"github.com/NimbleMarkets/dbn-go"
 	dbn_live "github.com/NimbleMarkets/dbn-go/live"
 )
 
-var dbnLiveSchemas = []string{"trades", "ohlcv-1m"}
+// DBN schemas ingested by the LiveDataClient
+const (
+	SchemaTrades  = "trades"
+	SchemaOhlcv1m = "ohlcv-1m"
+)
+
+// DefaultSchemas are the DBN schemas ingested unless LiveDataConfig.Schemas is set
+var DefaultSchemas = []string{SchemaTrades, SchemaOhlcv1m}
 
 // LiveDataClient handles a DataBento live feed, writing records to a DuckDB
 type LiveDataClient struct {
 	config  LiveDataConfig
 	started bool
 
+	subMutex   sync.RWMutex
+	subSymbols []string        // symbols ingested, sorted
+	streamed   map[string]bool // symbols ever subscribed from Databento
+	dropped    map[string]bool // streamed symbols no longer ingested
+	stopped    bool            // true once Stop is called
+
+	following    atomic.Bool   // true while FollowStream reads the session
+	lastRecordAt atomic.Int64  // wall-clock time of the last record received, as nanoseconds from the epoch
+	lastEventTs  atomic.Uint64 // latest ts_event of the trades and candles ingested
+
 	duckdbConn       *sql.DB
 	tradesTableName  string
 	candlesTableName string
@@ -30,6 +52,8 @@ type LiveDataClient struct {
 	dbnVisitor   *LiveDataVisitor
 	dbnSymbolMap *dbn.PitSymbolMap
 
+	broker *Broker
+
 	outWriter io.Writer
 	outCloser func()
 }
@@ -38,6 +62,15 @@ type LiveDataClient struct {
 // It will connect, authenticate, pre-subscribe any symbols, and start the streaming
 // Returns nil and an error, if any
 func NewLiveDataClient(config LiveDataConfig, duckdbConn *sql.DB) (*LiveDataClient, error) {
+	if len(config.Schemas) == 0 {
+		config.Schemas = DefaultSchemas
+	}
+	for _, schema := range config.Schemas {
+		if !slices.Contains(DefaultSchemas, schema) {
+			return nil, fmt.Errorf("unsupported schema %q, must be one of %v", schema, DefaultSchemas)
+		}
+	}
+
 	// Create a new LiveDataClient, hooking up the visitor
 	liveDataClient := &LiveDataClient{
 		config:           config,
@@ -45,6 +78,13 @@ func NewLiveDataClient(config LiveDataConfig, duckdbConn *sql.DB) (*LiveDataClie
 		duckdbConn:       duckdbConn,
 		tradesTableName:  "trades",
 		candlesTableName: "candles",
+		broker:           NewBroker(),
+		subSymbols:       slices.Sorted(slices.Values(config.SubSymbols)),
+		streamed:         make(map[string]bool),
+		dropped:          make(map[string]bool),
+	}
+	for _, symbol := range config.SubSymbols {
+		liveDataClient.streamed[symbol] = true
 	}
 	liveDataClient.dbnVisitor = NewLiveDataVisitor(liveDataClient)
 
@@ -87,7 +127,7 @@ func NewLiveDataClient(config LiveDataConfig, duckdbConn *sql.DB) (*LiveDataClie
 
 	// Pre-subscribe to symbols, this blocks
 	if len(config.SubSymbols) != 0 {
-		for _, schema := range dbnLiveSchemas {
+		for _, schema := range config.Schemas {
 			subRequest := dbn_live.SubscriptionRequestMsg{
 				Schema:   schema,
 				StypeIn:  dbn.SType_RawSymbol,
@@ -127,26 +167,166 @@ func NewLiveDataClient(config LiveDataConfig, duckdbConn *sql.DB) (*LiveDataClie
 	if err = client.Start(); err != nil {
 		return nil, fmt.Errorf("failed to start LiveClient: %w", err)
 	}
+	metricSessions.WithLabelValues(config.Dataset).Inc()
+	gClientCollector.add(liveDataClient)
 
 	// Return the LiveDataClient
 	closeOutCloser = false
 	return liveDataClient, nil
 }
 
-// FollowStream listens to the DBN stream, handling records until it is stopped,
+// NewDetachedLiveDataClient returns a LiveDataClient for the config that is not connected
+// to Databento and ingests nothing.  Events published to its Broker reach the streaming
+// routes, which lets them be exercised without a live session, as in tests.
+// The client is already stopped, so it cannot subscribe to new symbols or FollowStream.
+func NewDetachedLiveDataClient(config LiveDataConfig) *LiveDataClient {
+	if len(config.Schemas) == 0 {
+		config.Schemas = DefaultSchemas
+	}
+	return &LiveDataClient{
+		config:     config,
+		broker:     NewBroker(),
+		subSymbols: slices.Sorted(slices.Values(config.SubSymbols)),
+		streamed:   make(map[string]bool),
+		dropped:    make(map[string]bool),
+		stopped:    true,
+	}
+}
+
+// Dataset returns the Databento dataset the client is subscribed to
+func (c *LiveDataClient) Dataset() string {
+	return c.config.Dataset
+}
+
+// Subscriptions returns the symbols the client is subscribed to, sorted
+func (c *LiveDataClient) Subscriptions() []string {
+	c.subMutex.RLock()
+	defer c.subMutex.RUnlock()
+	return slices.Clone(c.subSymbols)
+}
+
+// SetSubscriptions changes the symbols the client ingests without restarting its session,
+// subscribing to new symbols from now.  Databento has no unsubscribe, so removed symbols
+// are still streamed and written to the output file, but are not ingested or published.
+// Returns the added and removed symbols, sorted, and an error if any.
+func (c *LiveDataClient) SetSubscriptions(symbols []string) (added []string, removed []string, err error) {
+	c.subMutex.Lock()
+	defer c.subMutex.Unlock()
+
+	symbols = slices.Compact(slices.Sorted(slices.Values(symbols)))
+	for _, symbol := range symbols {
+		if !slices.Contains(c.subSymbols, symbol) {
+			added = append(added, symbol)
+		}
+	}
+	for _, symbol := range c.subSymbols {
+		if !slices.Contains(symbols, symbol) {
+			removed = append(removed, symbol)
+		}
+	}
+
+	var newSymbols []string
+	for _, symbol := range added {
+		if !c.streamed[symbol] {
+			newSymbols = append(newSymbols, symbol)
+		}
+	}
+	if len(newSymbols) != 0 {
+		if c.stopped {
+			return nil, nil, fmt.Errorf("client is stopped")
+		}
+		for _, schema := range c.config.Schemas {
+			subRequest := dbn_live.SubscriptionRequestMsg{
+				Schema:   schema,
+				StypeIn:  dbn.SType_RawSymbol,
+				Symbols:  newSymbols,
+				Snapshot: c.config.Snapshot,
+			}
+			if err := c.dbnClient.Subscribe(subRequest); err != nil {
+				return nil, nil, fmt.Errorf("failed to subscribe LiveClient: %w", err)
+			}
+		}
+	}
+
+	for _, symbol := range added {
+		c.streamed[symbol] = true
+		delete(c.dropped, symbol)
+	}
+	for _, symbol := range removed {
+		c.dropped[symbol] = true
+	}
+	c.subSymbols = symbols
+	return added, removed, nil
+}
+
+// isDropped returns true if the ticker is streamed but no longer ingested
+func (c *LiveDataClient) isDropped(ticker string) bool {
+	c.subMutex.RLock()
+	defer c.subMutex.RUnlock()
+	return c.dropped[ticker]
+}
+
+// Schemas returns the DBN schemas the client ingests
+func (c *LiveDataClient) Schemas() []string {
+	return slices.Clone(c.config.Schemas)
+}
+
+// Broker returns the Broker that ingested records are published to
+func (c *LiveDataClient) Broker() *Broker {
+	return c.broker
+}
+
+// ClientStatus is the state of a LiveDataClient's session
+type ClientStatus struct {
+	Following      bool      // True while FollowStream reads the authenticated session
+	LastRecordTime time.Time // Time the last record of any kind was received, zero if none
+	LastEventTime  time.Time // Latest ts_event of the trades and candles ingested, zero if none
+}
+
+// Status returns the state of the client's session
+func (c *LiveDataClient) Status() ClientStatus {
+	var status ClientStatus
+	status.Following = c.following.Load()
+	if at := c.lastRecordAt.Load(); at != 0 {
+		status.LastRecordTime = time.Unix(0, at)
+	}
+	if ts := c.lastEventTs.Load(); ts != 0 {
+		status.LastEventTime = time.Unix(0, int64(ts))
+	}
+	return status
+}
+
+// noteEvent records the ts_event of an ingested trade or candle, if it is the latest.
+// Only the FollowStream goroutine calls it.
+func (c *LiveDataClient) noteEvent(tsEvent uint64) {
+	if tsEvent > c.lastEventTs.Load() {
+		c.lastEventTs.Store(tsEvent)
+	}
+}
+
+// Stop closes the Databento session, so FollowStream returns after handling the
+// records already received.  It is safe to call more than once.
+// Returns the error of closing the session, if any.
 func (c *LiveDataClient) Stop() error {
-	if !c.started {
+	c.subMutex.Lock()
+	defer c.subMutex.Unlock()
+	if c.stopped {
 		return nil
 	}
-	err := c.dbnClient.Stop()
-	if err != nil {
-		c.started = false
-	}
-	return nil
+	c.stopped = true
+	return c.dbnClient.Stop()
+}
+
+// isStopped returns true once Stop is called
+func (c *LiveDataClient) isStopped() bool {
+	c.subMutex.RLock()
+	defer c.subMutex.RUnlock()
+	return c.stopped
 }
 
-// runOutCloser runs the output file closer function, if it exists
-func (c *LiveDataClient) runOutCloser() {
+// CloseOutput flushes and closes the output file.  It must not be called while
+// FollowStream is running.  It is safe to call more than once.
+func (c *LiveDataClient) CloseOutput() {
 	if c.outCloser != nil {
 		c.outCloser()
 		c.outCloser = nil
@@ -154,13 +334,15 @@ func (c *LiveDataClient) runOutCloser() {
 }
 
 // FollowStream listens to the DBN stream, handling records until it is stopped.
-// Only one of these should be invoked per client
+// Only one of these should be invoked per client.  Call CloseOutput after it returns.
 func (c *LiveDataClient) FollowStream() error {
 	if c.started {
 		return fmt.Errorf("already started")
 	}
+	if c.dbnClient == nil {
+		return fmt.Errorf("client is detached")
+	}
 	c.started = true
-	defer c.runOutCloser() // Close the output file when done
 
 	// Write metadata to file
 	dbnScanner := c.dbnClient.GetDbnScanner()
@@ -180,21 +362,37 @@ func (c *LiveDataClient) FollowStream() error {
 	c.dbnSymbolMap.FillFromMetadata(metadata, midTime)
 
 	// Follow the DBN stream, writing DBN messages to the file
-	for dbnScanner.Next() && c.started {
+	c.following.Store(true)
+	defer c.following.Store(false)
+	decodeSeconds := metricDecodeSeconds.WithLabelValues(c.config.Dataset)
+	dbnBytes := metricDbnBytes.WithLabelValues(c.config.Dataset)
+	for dbnScanner.Next() {
+		start := time.Now()
+		c.lastRecordAt.Store(start.UnixNano())
+		if header, err := dbnScanner.GetLastHeader(); err == nil {
+			metricRecords.WithLabelValues(c.config.Dataset, schemaOfRType(header.RType)).Inc()
+		}
+
 		// use the visitor to handle the record
+		c.dbnVisitor.insertTime = 0
 		if err := dbnScanner.Visit(c.dbnVisitor); err != nil {
 			return fmt.Errorf("failed to visit record: %w", err)
 		}
+		decodeSeconds.Observe((time.Since(start) - c.dbnVisitor.insertTime).Seconds())
 
 		// Write the raw record to the log
 		recordBytes := dbnScanner.GetLastRecord()[:dbnScanner.GetLastSize()]
-		_, err := c.outWriter.Write(recordBytes)
+		n, err := c.outWriter.Write(recordBytes)
+		dbnBytes.Add(float64(n))
 		if err != nil {
 			return fmt.Errorf("failed to write record: %w", err)
 		}
 	}
 
 	if err := dbnScanner.Error(); err != nil && err != io.EOF {
+		if c.isStopped() {
+			return nil // the session was closed by Stop
+		}
 		fmt.Fprintf(os.Stderr, "scanner err: %s\n", err.Error())
 		return err
 	}
@@ -205,12 +403,50 @@ func (c *LiveDataClient) FollowStream() error {
 
 // LiveDataVisitor is the dbn.Visitor to dispatch the clients's message handlers
 type LiveDataVisitor struct {
-	c *LiveDataClient
+	c          *LiveDataClient
+	insertTime time.Duration               // time spent inserting the current record
+	lastEvents map[eventSeqKey]eventSeqPos // last Event of each schema and ticker, to number Events sharing an ID
+}
+
+// eventSeqKey is the schema and ticker of Events numbered by their Seq
+type eventSeqKey struct {
+	schema string
+	ticker string
+}
+
+// eventSeqPos is the ID and Seq of an Event
+type eventSeqPos struct {
+	id  uint64
+	seq uint32
 }
 
 // NewLiveDataVisitor creates is an implementation of all the dbn.Visitor interface.
 func NewLiveDataVisitor(client *LiveDataClient) *LiveDataVisitor {
-	return &LiveDataVisitor{c: client}
+	return &LiveDataVisitor{c: client, lastEvents: make(map[eventSeqKey]eventSeqPos)}
+}
+
+// nextSeq returns the Seq of the schema and ticker's next Event, of the ID.
+// Records arrive in ts_event order, so those sharing an ID are numbered from 0 in arrival order.
+func (v *LiveDataVisitor) nextSeq(schema string, ticker string, id uint64) uint32 {
+	key := eventSeqKey{schema: schema, ticker: ticker}
+	seq := uint32(0)
+	if last, ok := v.lastEvents[key]; ok && last.id == id {
+		seq = last.seq + 1
+	}
+	v.lastEvents[key] = eventSeqPos{id: id, seq: seq}
+	return seq
+}
+
+// insert executes a DuckDB insert into the table, recording its metrics
+func (v *LiveDataVisitor) insert(table string, queryStr string) error {
+	start := time.Now()
+	_, err := v.c.duckdbConn.Exec(queryStr)
+	v.insertTime = time.Since(start)
+	metricInsertSeconds.WithLabelValues(v.c.config.Dataset, table).Observe(v.insertTime.Seconds())
+	if err != nil {
+		metricInsertErrors.WithLabelValues(v.c.config.Dataset, table).Inc()
+	}
+	return err
 }
 
 // OnMbp0 will insert the trade into the client's DuckDB
@@ -218,6 +454,9 @@ func (v *LiveDataVisitor) OnMbp0(tradeRecord *dbn.Mbp0Msg) error {
 	timestamp, nanos := dbn.TimestampToSecNanos(tradeRecord.Header.TsEvent) // thanks dbn-go!
 	micros := timestamp*1_000_000 + nanos/1_000
 	ticker := v.c.dbnSymbolMap.Get(tradeRecord.Header.InstrumentID)
+	if v.c.isDropped(ticker) {
+		return nil
+	}
 
 	sqlFormat := `INSERT INTO %s (date, timestamp, nanos, publisher, ticker, price, shares)
 VALUES (MAKE_TIMESTAMP(%d)::DATE, %d, %d, %d, '%s', %f, %d)
@@ -227,10 +466,28 @@ ON CONFLICT DO NOTHING;`
 		ticker, dbn.Fixed9ToFloat64(tradeRecord.Price), tradeRecord.Size,
 	)
 
-	_, err := v.c.duckdbConn.Exec(queryStr)
+	err := v.insert(v.c.tradesTableName, queryStr)
 	if err != nil {
 		return fmt.Errorf("failed to insert trade: %w", err)
 	}
+	v.c.noteEvent(tradeRecord.Header.TsEvent)
+	v.c.observeIngest(SchemaTrades, ticker, tradeRecord.Header.TsEvent)
+
+	v.c.broker.Publish(Event{
+		ID:      tradeRecord.Header.TsEvent,
+		Seq:     v.nextSeq(SchemaTrades, ticker, tradeRecord.Header.TsEvent),
+		Dataset: v.c.config.Dataset,
+		Schema:  SchemaTrades,
+		Ticker:  ticker,
+		Trade: &sdk.TradeTick{
+			Timestamp:   timestamp,
+			Nanos:       nanos,
+			PublisherID: tradeRecord.Header.PublisherID,
+			Ticker:      ticker,
+			Price:       dbn.Fixed9ToFloat64(tradeRecord.Price),
+			Shares:      int64(tradeRecord.Size),
+		},
+	})
 	return nil
 }
 
@@ -250,6 +507,9 @@ func (v *LiveDataVisitor) OnOhlcv(ohlcvRecord *dbn.OhlcvMsg) error {
 	timestamp, nanos := dbn.TimestampToSecNanos(ohlcvRecord.Header.TsEvent) // thanks dbn-go!
 	micros := timestamp*1_000_000 + nanos/1_000
 	ticker := v.c.dbnSymbolMap.Get(ohlcvRecord.Header.InstrumentID)
+	if v.c.isDropped(ticker) {
+		return nil
+	}
 
 	sqlFormat := `INSERT INTO %s (date, timestamp, nanos, publisher, ticker, volume, open, high, low, close)
 VALUES (MAKE_TIMESTAMP(%d)::DATE, %d, %d, %d, '%s', %d, %f, %f, %f, %f)
@@ -263,10 +523,31 @@ ON CONFLICT DO NOTHING;`
 		dbn.Fixed9ToFloat64(ohlcvRecord.Close),
 	)
 
-	_, err := v.c.duckdbConn.Exec(queryStr)
+	err := v.insert(v.c.candlesTableName, queryStr)
 	if err != nil {
 		return fmt.Errorf("failed to execute insert candle: %w", err)
 	}
+	v.c.noteEvent(ohlcvRecord.Header.TsEvent)
+	v.c.observeIngest(SchemaOhlcv1m, ticker, ohlcvRecord.Header.TsEvent)
+
+	v.c.broker.Publish(Event{
+		ID:      ohlcvRecord.Header.TsEvent,
+		Seq:     v.nextSeq(SchemaOhlcv1m, ticker, ohlcvRecord.Header.TsEvent),
+		Dataset: v.c.config.Dataset,
+		Schema:  SchemaOhlcv1m,
+		Ticker:  ticker,
+		Candle: &sdk.Candle{
+			Timestamp:   timestamp,
+			Nanos:       nanos,
+			PublisherID: ohlcvRecord.Header.PublisherID,
+			Ticker:      ticker,
+			Open:        dbn.Fixed9ToFloat64(ohlcvRecord.Open),
+			High:        dbn.Fixed9ToFloat64(ohlcvRecord.High),
+			Low:         dbn.Fixed9ToFloat64(ohlcvRecord.Low),
+			Close:       dbn.Fixed9ToFloat64(ohlcvRecord.Close),
+			Volume:      ohlcvRecord.Volume,
+		},
+	})
 	return nil
 }
 
@@ -6,11 +6,24 @@ import "time"
 
 // LiveDataConfig is configuration data for our live service
 type LiveDataConfig struct {
-	OutFilename string    // Output filename for the DBN data (*.zst will be compressed)
-	ApiKey      string    // DataBento API Key
-	Dataset     string    // Databento Dataset to subscribe to
-	SubSymbols  []string  // Symbols to automatically subscribe to
-	StartTime   time.Time // Start time to request (default: now)
-	Snapshot    bool      // Enable snapshot on subscription request
-	Verbose     bool      // Verbose logging
+	OutFilename string    `yaml:"out"`             // Output filename for the DBN data (*.zst will be compressed)
+	ApiKey      string    `yaml:"key"`             // DataBento API Key
+	Dataset     string    `yaml:"dataset"`         // Databento Dataset to subscribe to
+	SubSymbols  []string  `yaml:"symbols"`         // Symbols to automatically subscribe to
+	Schemas     []string  `yaml:"schemas"`         // DBN schemas to ingest (default: DefaultSchemas)
+	StartTime   time.Time `yaml:"start,omitempty"` // Start time to request (default: now)
+	Snapshot    bool      `yaml:"snapshot"`        // Enable snapshot on subscription request
+	Verbose     bool      `yaml:"-"`               // Verbose logging
+}
+
+// RetentionConfig is the configuration of deleting old trades and candles from DuckDB
+type RetentionConfig struct {
+	MaxAge   time.Duration `yaml:"max_age"`  // Age after which trades and candles are deleted, 0 keeps them
+	Interval time.Duration `yaml:"interval"` // Time between deletions of old data
+}
+
+// DefaultRetentionConfig keeps all data
+var DefaultRetentionConfig = RetentionConfig{
+	MaxAge:   0,
+	Interval: time.Hour,
 }
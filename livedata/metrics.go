@@ -0,0 +1,182 @@
+// Copyright 2025 Neomantra Corp
+
+package livedata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus metrics of the ingest pipeline.  They are registered with the default
+// registry, so they are served on the metrics path with ginmetrics' request metrics.
+
+// DefaultMaxSymbolLabels is the number of symbols given their own label unless SetMaxSymbolLabels is called
+const DefaultMaxSymbolLabels = 100
+
+// otherSymbolLabel is the symbol label of the symbols beyond the label limit
+const otherSymbolLabel = "_other"
+
+var (
+	metricRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goose_ingest_records_total",
+		Help: "DBN records received, by dataset and schema",
+	}, []string{"dataset", "schema"})
+	metricSymbolRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goose_ingest_symbol_records_total",
+		Help: "Trades and candles ingested, by dataset, schema and symbol; symbols beyond the label limit are counted as '" + otherSymbolLabel + "'",
+	}, []string{"dataset", "schema", "symbol"})
+	metricDecodeSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "goose_ingest_decode_seconds",
+		Help:    "Time to decode and dispatch a DBN record, excluding its DuckDB insert",
+		Buckets: prometheus.ExponentialBuckets(1e-6, 4, 10), // 1µs to 262ms
+	}, []string{"dataset"})
+	metricInsertSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "goose_ingest_insert_seconds",
+		Help:    "Time to insert a record into DuckDB, by dataset and table",
+		Buckets: prometheus.ExponentialBuckets(1e-5, 4, 10), // 10µs to 2.6s
+	}, []string{"dataset", "table"})
+	metricInsertErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goose_ingest_insert_errors_total",
+		Help: "Failed DuckDB inserts, by dataset and table",
+	}, []string{"dataset", "table"})
+	metricFeedLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "goose_ingest_feed_latency_seconds",
+		Help:    "Wall clock minus ts_event of ingested records, by dataset and schema; ohlcv-1m ts_event is the start of the bar",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 30, 60, 120, 300},
+	}, []string{"dataset", "schema"})
+	metricSessions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goose_ingest_sessions_total",
+		Help: "Databento live sessions started, by dataset; more than one is a reconnect",
+	}, []string{"dataset"})
+	metricDbnBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goose_ingest_dbn_bytes_written_total",
+		Help: "Bytes of DBN records written to the output file, by dataset",
+	}, []string{"dataset"})
+)
+
+// Global collector of the gauges of each LiveDataClient
+var gClientCollector = &clientCollector{
+	queueDepth: prometheus.NewDesc("goose_ingest_queue_depth",
+		"Events buffered for streaming subscribers, by dataset", []string{"dataset"}, nil),
+	subscribers: prometheus.NewDesc("goose_ingest_stream_subscribers",
+		"Streaming subscribers of the Broker, by dataset", []string{"dataset"}, nil),
+	connected: prometheus.NewDesc("goose_ingest_connected",
+		"1 while the live session is followed, by dataset", []string{"dataset"}, nil),
+}
+
+// Global storage of the symbols given their own label
+var gSymbolLabels = newSymbolLabeler(DefaultMaxSymbolLabels)
+
+func init() {
+	prometheus.MustRegister(metricRecords, metricSymbolRecords, metricDecodeSeconds, metricInsertSeconds,
+		metricInsertErrors, metricFeedLatency, metricSessions, metricDbnBytes, gClientCollector)
+}
+
+// SetMaxSymbolLabels sets the number of symbols given their own label in the per-symbol
+// metrics, bounding their series count.  Symbols are labelled in the order first ingested;
+// later ones are counted as "_other".  Labels already given are kept.
+func SetMaxSymbolLabels(max int) {
+	gSymbolLabels.setMax(max)
+}
+
+// schemaOfRType returns the schema label of a DBN record type
+func schemaOfRType(rtype dbn.RType) string {
+	switch rtype {
+	case dbn.RType_Mbp0:
+		return SchemaTrades
+	case dbn.RType_Ohlcv1M:
+		return SchemaOhlcv1m
+	}
+	if name := rtype.String(); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// observeIngest records the metrics of an ingested trade or candle
+func (c *LiveDataClient) observeIngest(schema string, ticker string, tsEvent uint64) {
+	metricSymbolRecords.WithLabelValues(c.config.Dataset, schema, gSymbolLabels.label(ticker)).Inc()
+	metricFeedLatency.WithLabelValues(c.config.Dataset, schema).Observe(time.Since(time.Unix(0, int64(tsEvent))).Seconds())
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// symbolLabeler bounds the symbol label values of the per-symbol metrics
+type symbolLabeler struct {
+	mutex   sync.RWMutex
+	max     int
+	symbols map[string]bool // symbols given their own label
+}
+
+func newSymbolLabeler(max int) *symbolLabeler {
+	return &symbolLabeler{max: max, symbols: make(map[string]bool)}
+}
+
+func (l *symbolLabeler) setMax(max int) {
+	l.mutex.Lock()
+	l.max = max
+	l.mutex.Unlock()
+}
+
+// label returns the symbol's label value, giving it its own if there is room
+func (l *symbolLabeler) label(symbol string) string {
+	l.mutex.RLock()
+	labelled, full := l.symbols[symbol], len(l.symbols) >= l.max
+	l.mutex.RUnlock()
+	if labelled {
+		return symbol
+	}
+	if full {
+		return otherSymbolLabel
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.symbols) >= l.max {
+		return otherSymbolLabel
+	}
+	l.symbols[symbol] = true
+	return symbol
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// clientCollector collects the gauges of the LiveDataClients when scraped
+type clientCollector struct {
+	mutex       sync.Mutex
+	clients     []*LiveDataClient
+	queueDepth  *prometheus.Desc
+	subscribers *prometheus.Desc
+	connected   *prometheus.Desc
+}
+
+// add adds a client to be collected
+func (cc *clientCollector) add(client *LiveDataClient) {
+	cc.mutex.Lock()
+	cc.clients = append(cc.clients, client)
+	cc.mutex.Unlock()
+}
+
+func (cc *clientCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.queueDepth
+	ch <- cc.subscribers
+	ch <- cc.connected
+}
+
+func (cc *clientCollector) Collect(ch chan<- prometheus.Metric) {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	for _, client := range cc.clients {
+		dataset := client.Dataset()
+		connected := 0.0
+		if client.following.Load() {
+			connected = 1
+		}
+		ch <- prometheus.MustNewConstMetric(cc.queueDepth, prometheus.GaugeValue, float64(client.broker.QueueDepth()), dataset)
+		ch <- prometheus.MustNewConstMetric(cc.subscribers, prometheus.GaugeValue, float64(client.broker.NumSubscriptions()), dataset)
+		ch <- prometheus.MustNewConstMetric(cc.connected, prometheus.GaugeValue, connected, dataset)
+	}
+}
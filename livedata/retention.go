@@ -0,0 +1,39 @@
+// Copyright 2025 Neomantra Corp
+
+package livedata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricPrunedRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "goose_retention_deleted_rows_total",
+	Help: "Trades and candles deleted for being older than the retention max age, by dataset and table",
+}, []string{"dataset", "table"})
+
+func init() {
+	prometheus.MustRegister(metricPrunedRows)
+}
+
+// Prune deletes the client's trades and candles whose timestamp is before cutoff.
+// Returns the number of rows deleted and an error, if any.
+func (c *LiveDataClient) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	var deleted int64
+	for _, table := range []string{c.tradesTableName, c.candlesTableName} {
+		result, err := c.duckdbConn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE timestamp < ?;", table), cutoff.Unix())
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+		metricPrunedRows.WithLabelValues(c.config.Dataset, table).Add(float64(rows))
+		deleted += rows
+	}
+	return deleted, nil
+}
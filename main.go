@@ -6,23 +6,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
 	"github.com/penglongli/gin-metrics/ginmetrics"
-	"github.com/relvacode/iso8601"
-	"github.com/spf13/pflag"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
+	"github.com/NimbleMarkets/dbn-duckduck-goose/flightserver"
 	"github.com/NimbleMarkets/dbn-duckduck-goose/handlers"
 	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
 	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/pgserver"
 
 	"database/sql"
 
@@ -32,10 +36,23 @@ import (
 ///////////////////////////////////////////////////////////////////////////////
 
 type ServiceConfig struct {
-	HostPort   string                  // HostPort to server the webserver on
-	DuckDBFile string                  // DuckDB file to connect to (default: ':memory:')
-	LiveConfig livedata.LiveDataConfig // LiveDataConfig configuration
-	Verbose    bool                    // Verbose logging
+	HostPort        string                   `yaml:"hostport"`         // HostPort to server the webserver on
+	DuckDBFile      string                   `yaml:"db"`               // DuckDB file to connect to (default: ':memory:')
+	LiveConfig      livedata.LiveDataConfig  `yaml:"live"`             // LiveDataConfig configuration
+	ScratchDir      string                   `yaml:"scratch"`          // Parent of the private directory for export files, swept on startup and removed on shutdown
+	MaxExports      int                      `yaml:"max_exports"`      // Maximum number of concurrent file exports
+	FlightSQL       flightserver.Config      `yaml:"flight"`           // Flight SQL listener configuration, disabled if HostPort is empty
+	PgWire          pgserver.Config          `yaml:"pg"`               // PostgreSQL wire protocol listener configuration, disabled if HostPort is empty
+	Query           handlers.QueryConfig     `yaml:"query"`            // Ad-hoc SQL query endpoint configuration
+	Health          handlers.HealthConfig    `yaml:"health"`           // Readiness probe configuration
+	Cors            middleware.CorsConfig    `yaml:"cors"`             // CORS configuration
+	Log             middleware.LogConfig     `yaml:"log"`              // Log file configuration
+	Metrics         MetricsConfig            `yaml:"metrics"`          // Prometheus request metrics configuration
+	Tracing         middleware.TracingConfig `yaml:"tracing"`          // OpenTelemetry tracing configuration
+	Auth            middleware.AuthConfig    `yaml:"auth"`             // API key authentication configuration
+	Retention       livedata.RetentionConfig `yaml:"retention"`        // Deletion of old trades and candles
+	ShutdownTimeout time.Duration            `yaml:"shutdown_timeout"` // Time allowed for a graceful shutdown
+	Verbose         bool                     `yaml:"verbose"`          // Verbose logging
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -56,55 +73,70 @@ type ServiceConfig struct {
 //	@BasePath					/api/v1
 //	@Host						api.example.com
 //	@Schemes					http
+//
+//	@securityDefinitions.apikey	ApiKeyAuth
+//	@in							header
+//	@name						X-API-Key
+//	@description				API key token '<id>.<secret>', if the server enables authentication
 
 func main() {
-	var err error
-	var config ServiceConfig
-	var startTimeArg string
-	var showHelp bool
-
-	pflag.StringVarP(&config.DuckDBFile, "db", "", "", "DuckDB datate file to use (default: ':memory:')")
-	pflag.StringVarP(&config.HostPort, "hostport", "p", "localhost:8888", "'host:port' to service HTTP")
-	pflag.StringVarP(&config.LiveConfig.Dataset, "dataset", "d", "", "Dataset to subscribe to")
-	pflag.StringVarP(&config.LiveConfig.ApiKey, "key", "k", "", "Databento API key (or set 'DATABENTO_API_KEY' envvar)")
-	pflag.StringVarP(&config.LiveConfig.OutFilename, "out", "o", "", "Output filename for DBN stream ('-' for stdout)")
-	pflag.StringVarP(&startTimeArg, "start", "t", "", "Start time to request as ISO 8601 format (default: now)")
-	pflag.BoolVarP(&config.LiveConfig.Snapshot, "snapshot", "n", false, "Enable snapshot on subscription request")
-	pflag.BoolVarP(&config.Verbose, "verbose", "v", false, "Verbose logging")
-	pflag.BoolVarP(&showHelp, "help", "h", false, "Show help")
-	pflag.Parse()
-
-	// therese are pre-loaded subscription requests
-	config.LiveConfig.SubSymbols = pflag.Args()
-	config.LiveConfig.Verbose = config.Verbose
-
-	if showHelp {
+	config, cmdFlags, flags, err := loadServiceConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if cmdFlags.ShowHelp {
 		fmt.Fprintf(os.Stdout, "usage: %s -d <dataset> [opts] symbol1 symbol2 ...\n\n", os.Args[0])
-		pflag.PrintDefaults()
+		flags.SetOutput(os.Stderr)
+		flags.PrintDefaults()
 		os.Exit(0)
 	}
 
-	if startTimeArg != "" {
-		config.LiveConfig.StartTime, err = iso8601.ParseString(startTimeArg)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to parse --start as ISO 8601 time: %s\n", err.Error())
+	if cmdFlags.ShowConfig {
+		if err := printConfig(os.Stdout, config); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print config: %s\n", err.Error())
 			os.Exit(1)
 		}
+		os.Exit(0)
 	}
 
-	if config.LiveConfig.ApiKey == "" {
-		config.LiveConfig.ApiKey = os.Getenv("DATABENTO_API_KEY")
-		requireValOrExit(config.LiveConfig.ApiKey, "missing Databento API key, use --key or set DATABENTO_API_KEY envvar\n")
+	if cmdFlags.NewAPIKey != "" {
+		if err := createAPIKey(config, cmdFlags); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create API key: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
+	requireValOrExit(config.LiveConfig.ApiKey, "missing Databento API key, use --key, set DATABENTO_API_KEY envvar or live.key in --config\n")
+	if config.FlightSQL.HostPort != "" {
+		requireValOrExit(config.FlightSQL.Password, "missing Flight SQL password, use --flight-password, set FLIGHT_SQL_PASSWORD envvar or flight.password in --config\n")
+	}
+	if config.PgWire.HostPort != "" {
+		requireValOrExit(config.PgWire.Password, "missing PostgreSQL wire protocol password, use --pg-password, set PGWIRE_PASSWORD envvar or pg.password in --config\n")
+	}
+	if config.Retention.MaxAge > 0 && config.Retention.Interval <= 0 {
+		fmt.Fprintf(os.Stderr, "--retention-interval must be greater than 0\n")
+		os.Exit(1)
+	}
 	requireValOrExit(config.LiveConfig.Dataset, "missing required --dataset")
 	requireValOrExit(config.LiveConfig.OutFilename, "missing required --out")
 
 	// logger setup
-	isRelease := (gin.Mode() == gin.ReleaseMode) // GIN_MODE="release"
-	logger := middleware.CreateLogger("dbn-duckduck-goose", isRelease)
+	if config.Log.Release {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	logger, logLevel := middleware.CreateLogger("dbn-duckduck-goose", config.Log)
 	defer logger.Sync()
 
+	// OpenTelemetry tracing setup
+	shutdownTracing, err := middleware.InitTracing(context.Background(), "dbn-duckduck-goose", config.Tracing)
+	if err != nil {
+		logger.Error("failed to set up tracing", zap.Error(err))
+		os.Exit(1)
+	}
+
 	// DuckDB setup
 	if config.DuckDBFile == "" {
 		logger.Warn("no DuckDB file specified, using in-memory database")
@@ -116,62 +148,238 @@ func main() {
 	}
 	defer duckdbConn.Close()
 
+	// API key authentication setup
+	keyStore, err := openKeyStore(config.Auth, duckdbConn)
+	if err != nil {
+		logger.Error("failed to open API key store", zap.Error(err))
+		os.Exit(1)
+	}
+	if keyStore == nil {
+		logger.Warn("API key authentication is disabled, use --auth to enable it")
+	} else {
+		middleware.SetKeyStore(keyStore)
+	}
+
+	// Export scratch directory setup
+	scratchDir, err := middleware.NewScratchDir(config.ScratchDir, config.MaxExports, handlers.ScratchAcquireTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scratch dir failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	handlers.SetScratchDir(scratchDir)
+	handlers.SetQueryConfig(config.Query)
+	handlers.SetHealthConfig(config.Health)
+
 	// Gin webserver setup
 	router := gin.New()
-	router.Use(ginzap.Ginzap(logger, time.RFC3339, true))
+	// trace each request, before its log line so that it records the trace
+	router.Use(otelgin.Middleware("dbn-duckduck-goose", otelgin.WithGinFilter(func(c *gin.Context) bool {
+		path := c.Request.URL.Path
+		return path != config.Metrics.Path && path != "/healthz" && path != "/readyz"
+	})))
+	router.Use(ginzap.GinzapWithConfig(logger, &ginzap.Config{
+		TimeFormat:   time.RFC3339,
+		UTC:          true,
+		Context:      middleware.RequestLogContext,
+		DefaultLevel: zapcore.InfoLevel,
+	}))
 	router.Use(ginzap.RecoveryWithZap(logger, true))
 	router.Use(middleware.SetGinLogger(logger))
 	router.Use(gin.Recovery()) // recover from panics and return 500
 
 	// prometheus metrics middleware
 	m := ginmetrics.GetMonitor()
-	m.SetMetricPath("/metrics")
+	m.SetMetricPath(config.Metrics.Path)
 	// cutoff for slow request metric in seconds
-	m.SetSlowTime(5)
-	// request duration histogram buckets in seconds - used to p95, p99
-	// default {0.1, 0.3, 1.2, 5, 10}
-	m.SetDuration([]float64{0.1, 0.3, 1.2, 5, 10})
+	m.SetSlowTime(config.Metrics.SlowTime)
+	// request duration histogram buckets in seconds
+	m.SetDuration(config.Metrics.DurationBuckets)
 	m.Use(router)
+	// ingest and DuckDB metrics share its registry and path
+	livedata.SetMaxSymbolLabels(config.Metrics.MaxSymbolLabels)
+	registerDatabaseMetrics(config.DuckDBFile)
 
 	// Register our service's handlers/routes
+	middleware.SetCorsConfig(config.Cors)
 	handlers.Register(config.HostPort, duckdbConn, router, logger)
 
+	// Flight SQL setup, sharing the DuckDB connection
+	var flightServer *flightserver.Server
+	if config.FlightSQL.HostPort != "" {
+		flightServer, err = flightserver.NewServer(config.FlightSQL, duckdbConn, logger)
+		if err != nil {
+			logger.Error("failed to create Flight SQL server", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	// PostgreSQL wire protocol setup, sharing the DuckDB connection
+	var pgServer *pgserver.Server
+	if config.PgWire.HostPort != "" {
+		pgServer, err = pgserver.NewServer(config.PgWire, duckdbConn, logger)
+		if err != nil {
+			logger.Error("failed to create PostgreSQL wire protocol server", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
 	// Create our LiveDataClient
 	liveDataClient, err := livedata.NewLiveDataClient(config.LiveConfig, duckdbConn)
 	if err != nil {
 		logger.Error("failed to create LiveDataClient", zap.Error(err))
 		os.Exit(1)
 	}
+	handlers.AddLiveDataClient(liveDataClient)
+
+	// Reload the config on SIGHUP or POST /admin/reload
+	configReloader := &reloader{
+		config:         config,
+		logLevel:       logLevel,
+		liveDataClient: liveDataClient,
+		logger:         logger,
+	}
+	configReloader.keyStore, _ = keyStore.(*middleware.FileKeyStore)
+	handlers.SetReloadFunc(configReloader.Reload)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("SIGHUP received, reloading config")
+			if _, err := configReloader.Reload(); err != nil {
+				logger.Error("failed to reload config", zap.Error(err))
+			}
+		}
+	}()
 
 	// Run the LiveDataClient in a goroutine
-	var wg sync.WaitGroup
-	wg.Add(1)
+	followDone := make(chan struct{})
 	go func() {
-		defer wg.Done()
+		defer close(followDone)
 		logger.Info("LiveDataClient following DataBento Live Stream", zap.String("dataset", config.LiveConfig.Dataset))
 		if err := liveDataClient.FollowStream(); err != nil {
 			logger.Error("LiveDataClient error:", zap.Error(err))
 		}
 	}()
 
+	// Delete old data in a goroutine, if there is a max age
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	retentionDone := make(chan struct{})
+	go func() {
+		defer close(retentionDone)
+		if config.Retention.MaxAge > 0 {
+			logger.Info("Deleting old data", zap.Duration("max_age", config.Retention.MaxAge), zap.Duration("interval", config.Retention.Interval))
+			runRetention(retentionCtx, liveDataClient, config.Retention, logger)
+		}
+	}()
+
 	// Run the web server in a goroutine
+	httpServer := &http.Server{Addr: config.HostPort, Handler: router}
+	httpServer.RegisterOnShutdown(handlers.CloseStreams)
 	go func() {
-		// we can add graceful shutdown with this:
-		// https://gin-gonic.com/docs/examples/graceful-restart-or-stop/
 		logger.Info("Running web server", zap.String("hostport", config.HostPort))
-		router.Run(config.HostPort)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("web server error", zap.Error(err))
+		}
 		logger.Info("Web server stopped")
 	}()
 
+	// Run the Flight SQL server in a goroutine
+	if flightServer != nil {
+		go func() {
+			logger.Info("Running Flight SQL server", zap.String("hostport", flightServer.Addr()))
+			if err := flightServer.Serve(); err != nil {
+				logger.Error("Flight SQL server error", zap.Error(err))
+			}
+			logger.Info("Flight SQL server stopped")
+		}()
+	}
+
+	// Run the PostgreSQL wire protocol server in a goroutine
+	if pgServer != nil {
+		go func() {
+			logger.Info("Running PostgreSQL wire protocol server", zap.String("hostport", pgServer.Addr()))
+			if err := pgServer.Serve(); err != nil {
+				logger.Error("PostgreSQL wire protocol server error", zap.Error(err))
+			}
+			logger.Info("PostgreSQL wire protocol server stopped")
+		}()
+	}
+
 	// Wait for interrupt signal to shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	logger.Info("Signal received, shutting down...")
+	logger.Info("Signal received, shutting down...", zap.Duration("timeout", config.ShutdownTimeout))
 
-	liveDataClient.Stop()
-	wg.Wait() // wait for LiveDataClient to finish
+	// Stop accepting requests and drain them, then stop ingest and persist its data
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	phases := []shutdownPhase{
+		{"drain HTTP requests", httpServer.Shutdown},
+		{"remove export scratch dir", func(context.Context) error {
+			return scratchDir.Close()
+		}},
+		{"flush traces", shutdownTracing},
+	}
+	if flightServer != nil {
+		phases = append(phases, shutdownPhase{"stop Flight SQL server", func(context.Context) error {
+			flightServer.Shutdown()
+			return nil
+		}})
+	}
+	if pgServer != nil {
+		phases = append(phases, shutdownPhase{"stop PostgreSQL wire protocol server", func(context.Context) error {
+			pgServer.Shutdown()
+			return nil
+		}})
+	}
+	phases = append(phases,
+		shutdownPhase{"stop data retention", func(ctx context.Context) error {
+			stopRetention()
+			select {
+			case <-retentionDone:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}},
+		shutdownPhase{"stop live session", func(context.Context) error {
+			return liveDataClient.Stop()
+		}},
+		shutdownPhase{"drain ingest", func(ctx context.Context) error {
+			select {
+			case <-followDone:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}},
+		shutdownPhase{"close DBN output", func(context.Context) error {
+			select {
+			case <-followDone:
+				liveDataClient.CloseOutput()
+				return nil
+			default:
+				return errors.New("ingest is still running")
+			}
+		}},
+		shutdownPhase{"checkpoint DuckDB", func(ctx context.Context) error {
+			if config.DuckDBFile == "" {
+				return nil // in-memory
+			}
+			_, err := duckdbConn.ExecContext(ctx, "CHECKPOINT")
+			return err
+		}},
+		shutdownPhase{"close DuckDB", func(context.Context) error {
+			return duckdbConn.Close()
+		}},
+	)
+	if runShutdown(ctx, logger, phases) {
+		logger.Info("Shutdown complete")
+	} else {
+		logger.Warn("Shutdown incomplete")
+	}
 }
 
 // requireValOrExit exits with an error message if `val` is empty.
@@ -0,0 +1,38 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package main
+
+import (
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// registerDatabaseMetrics registers gauges of the sizes of the DuckDB file and its
+// write-ahead log, read when scraped.  An in-memory database has none.
+func registerDatabaseMetrics(dbFile string) {
+	if dbFile == "" || dbFile == ":memory:" {
+		return
+	}
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "goose_duckdb_file_bytes",
+			Help: "Size of the DuckDB database file",
+		}, fileSizeFunc(dbFile)),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "goose_duckdb_wal_bytes",
+			Help: "Size of the DuckDB write-ahead log, 0 after a checkpoint",
+		}, fileSizeFunc(dbFile+".wal")),
+	)
+}
+
+// fileSizeFunc returns a function of the size of the file at path, 0 if it does not exist
+func fileSizeFunc(path string) func() float64 {
+	return func() float64 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0
+		}
+		return float64(info.Size())
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright (c) 2025 Neomantra Corp
+//
+// API key authentication and authorization of the HTTP routes.
+//
+// A key is presented as the token '<id>.<secret>', in an 'Authorization: Bearer'
+// or 'X-API-Key' header, or as HTTP Basic credentials of the id and secret, which
+// browsers keep for the dashboard and chart pages.  Only the SHA-256 hash of the
+// secret is stored, in a YAML file or a DuckDB table.
+//
+
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Scopes of API keys
+const (
+	ScopeRead          = "read"          // market data, charts, streams, status and ad-hoc SQL
+	ScopeExport        = "export"        // file exports
+	ScopeSubscriptions = "subscriptions" // changing the live subscriptions
+	ScopeAdmin         = "admin"         // admin routes, such as config reload
+)
+
+// AllScopes are the scopes an API key may have
+var AllScopes = []string{ScopeRead, ScopeExport, ScopeSubscriptions, ScopeAdmin}
+
+// AllDatasets in an API key's datasets allows every dataset
+const AllDatasets = "*"
+
+// Key stores of AuthConfig
+const (
+	KeyStoreNone   = ""       // authentication is disabled
+	KeyStoreFile   = "file"   // keys in the YAML file of AuthConfig.KeysFile
+	KeyStoreDuckDB = "duckdb" // keys in the DuckDB table of AuthConfig.KeysTable
+)
+
+// AuthConfig is the configuration of API key authentication
+type AuthConfig struct {
+	KeyStore  string `yaml:"key_store"`  // "file", "duckdb", or empty to disable authentication
+	KeysFile  string `yaml:"keys_file"`  // YAML file of the keys of the "file" store
+	KeysTable string `yaml:"keys_table"` // DuckDB table of the keys of the "duckdb" store
+}
+
+// DefaultAuthConfig is the authentication configuration unless one is configured
+var DefaultAuthConfig = AuthConfig{
+	KeysTable: "api_keys",
+}
+
+// apiKeyHashPrefix prefixes the hex SHA-256 hashes of APIKey.SecretHash
+const apiKeyHashPrefix = "sha256:"
+
+// apiKeyIDRegexp matches valid API key IDs, which cannot contain the token's '.'
+var apiKeyIDRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// tableNameRegexp matches valid keys table names, which are not quoted
+var tableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// gin.Context key of the authenticated APIKey
+const ginAPIKey = "api-key"
+
+// ErrInvalidAPIKey is returned for a malformed API key or token
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+///////////////////////////////////////////////////////////////////////////////
+
+// APIKey is an API key's ID, hashed secret and permissions
+type APIKey struct {
+	ID         string   `yaml:"id"`          // Key ID, the first part of its token
+	SecretHash string   `yaml:"secret_hash"` // "sha256:" and the hex SHA-256 hash of its secret
+	Scopes     []string `yaml:"scopes"`      // Scopes granted, of AllScopes
+	Datasets   []string `yaml:"datasets"`    // Datasets allowed, or "*" for all
+}
+
+// NewAPIKey returns a new API key with a random secret, and its token.
+// The token is only returned here; the key stores only the secret's hash.
+func NewAPIKey(id string, scopes []string, datasets []string) (APIKey, string, error) {
+	key := APIKey{ID: id, Scopes: scopes, Datasets: datasets}
+	if err := key.validate(); err != nil {
+		return key, "", err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return key, "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	key.SecretHash = hashSecret(secret)
+	return key, id + "." + secret, nil
+}
+
+// HasScope returns true if the key has the scope
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// AllowsDataset returns true if the key may access the dataset
+func (k *APIKey) AllowsDataset(dataset string) bool {
+	return slices.Contains(k.Datasets, AllDatasets) || slices.Contains(k.Datasets, dataset)
+}
+
+// validate returns an error if the key's ID, scopes or hash are malformed
+func (k *APIKey) validate() error {
+	if !apiKeyIDRegexp.MatchString(k.ID) {
+		return fmt.Errorf("%w: id %q must be 1 to 64 letters, digits, '_' or '-'", ErrInvalidAPIKey, k.ID)
+	}
+	for _, scope := range k.Scopes {
+		if !slices.Contains(AllScopes, scope) {
+			return fmt.Errorf("%w: key %q has unknown scope %q, must be one of %v", ErrInvalidAPIKey, k.ID, scope, AllScopes)
+		}
+	}
+	for _, dataset := range k.Datasets {
+		if dataset == "" || strings.Contains(dataset, ",") {
+			return fmt.Errorf("%w: key %q has invalid dataset %q", ErrInvalidAPIKey, k.ID, dataset)
+		}
+	}
+	if k.SecretHash != "" && !strings.HasPrefix(k.SecretHash, apiKeyHashPrefix) {
+		return fmt.Errorf("%w: key %q secret_hash must start with %q", ErrInvalidAPIKey, k.ID, apiKeyHashPrefix)
+	}
+	return nil
+}
+
+// checkSecret returns true if the secret matches the key's hash
+func (k *APIKey) checkSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(k.SecretHash)) == 1
+}
+
+// hashSecret returns the SecretHash of a secret.  Secrets are 256 random bits, so a
+// fast hash suffices; a slow password hash would cost every request.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return apiKeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// KeyStore looks up API keys by ID
+type KeyStore interface {
+	// LookupKey returns the key of the ID, or nil if there is none, and an error, if any
+	LookupKey(ctx context.Context, id string) (*APIKey, error)
+}
+
+// FileKeyStore is a KeyStore of a YAML file with a list of APIKeys.
+// It is read when created and by Load.
+type FileKeyStore struct {
+	filename string
+	keys     atomic.Pointer[map[string]*APIKey]
+}
+
+// NewFileKeyStore returns a FileKeyStore of the keys of a YAML file, and an error, if any
+func NewFileKeyStore(filename string) (*FileKeyStore, error) {
+	store := &FileKeyStore{filename: filename}
+	if _, err := store.Load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Load re-reads the store's file.  If it fails, the previous keys are kept.
+// Returns the number of keys, and an error, if any.
+func (s *FileKeyStore) Load() (int, error) {
+	file, err := os.Open(s.filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open keys file: %w", err)
+	}
+	defer file.Close()
+
+	var keyList []APIKey
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&keyList); err != nil && err != io.EOF {
+		return 0, fmt.Errorf("failed to parse keys file %s: %w", s.filename, err)
+	}
+	keys := make(map[string]*APIKey, len(keyList))
+	for i := range keyList {
+		key := &keyList[i]
+		if err := key.validate(); err != nil {
+			return 0, fmt.Errorf("keys file %s: %w", s.filename, err)
+		}
+		if _, ok := keys[key.ID]; ok {
+			return 0, fmt.Errorf("keys file %s: duplicate key id %q", s.filename, key.ID)
+		}
+		keys[key.ID] = key
+	}
+	s.keys.Store(&keys)
+	return len(keys), nil
+}
+
+// LookupKey returns the key of the ID, or nil if there is none
+func (s *FileKeyStore) LookupKey(_ context.Context, id string) (*APIKey, error) {
+	return (*s.keys.Load())[id], nil
+}
+
+// DuckDBKeyStore is a KeyStore of a DuckDB table, looked up on each request
+type DuckDBKeyStore struct {
+	duckdbConn *sql.DB
+	table      string
+}
+
+// NewDuckDBKeyStore returns a DuckDBKeyStore of the table, creating it if needed.
+// Returns the store and an error, if any.
+func NewDuckDBKeyStore(duckdbConn *sql.DB, table string) (*DuckDBKeyStore, error) {
+	if !tableNameRegexp.MatchString(table) {
+		return nil, fmt.Errorf("invalid keys table name %q", table)
+	}
+	err := RunMigration(duckdbConn, APIKeysMigrationTemplate, MigrationInfo{
+		MigrationName: "apiKeysMigration",
+		TableName:     table,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run API keys migration: %w", err)
+	}
+	return &DuckDBKeyStore{duckdbConn: duckdbConn, table: table}, nil
+}
+
+// LookupKey returns the key of the ID, or nil if there is none, and an error, if any
+func (s *DuckDBKeyStore) LookupKey(ctx context.Context, id string) (*APIKey, error) {
+	queryStr := fmt.Sprintf(`SELECT secret_hash, array_to_string(scopes, ','), array_to_string(datasets, ',')
+FROM %s WHERE id = ?`, s.table)
+	var key APIKey
+	var scopes, datasets string
+	ctx, span := StartQuerySpan(ctx, "query api key", queryStr)
+	err := s.duckdbConn.QueryRowContext(ctx, queryStr, id).Scan(&key.SecretHash, &scopes, &datasets)
+	if errors.Is(err, sql.ErrNoRows) {
+		EndQuerySpan(span, 0, nil)
+		return nil, nil
+	}
+	if err != nil {
+		EndQuerySpan(span, 0, err)
+		return nil, err
+	}
+	EndQuerySpan(span, 1, nil)
+	key.ID = id
+	key.Scopes = splitNonEmpty(scopes)
+	key.Datasets = splitNonEmpty(datasets)
+	return &key, nil
+}
+
+// AddKey inserts a key into the table.  Returns an error, if any, such as for an existing ID.
+func (s *DuckDBKeyStore) AddKey(ctx context.Context, key APIKey) error {
+	if err := key.validate(); err != nil {
+		return err
+	}
+	queryStr := fmt.Sprintf(`INSERT INTO %s (id, secret_hash, scopes, datasets)
+VALUES (?, ?, list_filter(string_split(?, ','), s -> s <> ''), list_filter(string_split(?, ','), s -> s <> ''))`, s.table)
+	_, err := s.duckdbConn.ExecContext(ctx, queryStr, key.ID, key.SecretHash,
+		strings.Join(key.Scopes, ","), strings.Join(key.Datasets, ","))
+	return err
+}
+
+// splitNonEmpty splits a comma-separated list, returning nil for an empty string
+func splitNonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// keyStoreHolder holds a KeyStore, which may be nil, for an atomic.Pointer
+type keyStoreHolder struct {
+	store KeyStore
+}
+
+var gKeyStore atomic.Pointer[keyStoreHolder]
+
+func init() {
+	SetKeyStore(nil)
+}
+
+// SetKeyStore sets the store of the API keys.  If nil, authentication is disabled.
+func SetKeyStore(store KeyStore) {
+	gKeyStore.Store(&keyStoreHolder{store: store})
+}
+
+// AuthEnabled returns true if requests must present an API key
+func AuthEnabled() bool {
+	return gKeyStore.Load().store != nil
+}
+
+// RequireScope returns a handler that aborts requests without an API key of the scope,
+// or whose key does not allow the route's :dataset, if any.  It passes every request
+// if authentication is disabled.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := gKeyStore.Load().store
+		if store == nil {
+			c.Next()
+			return
+		}
+		key, err := authenticate(c, store)
+		if err != nil {
+			InternalError(c, "failed to look up API key", err)
+			return
+		}
+		if key == nil {
+			UnauthorizedError(c, errors.New("missing or invalid API key"))
+			return
+		}
+		c.Set(ginAPIKey, key)
+		if !key.HasScope(scope) {
+			ForbiddenError(c, fmt.Errorf("API key %q lacks the %q scope", key.ID, scope))
+			return
+		}
+		if dataset := c.Param("dataset"); dataset != "" && !key.AllowsDataset(dataset) {
+			ForbiddenError(c, fmt.Errorf("API key %q may not access dataset %s", key.ID, dataset))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetAPIKey returns the authenticated API key of a request, or nil if there is none
+func GetAPIKey(c *gin.Context) *APIKey {
+	if key, ok := c.Get(ginAPIKey); ok {
+		return key.(*APIKey)
+	}
+	return nil
+}
+
+// DatasetAllowed returns true if the request may access the dataset: authentication is
+// disabled, or its API key allows the dataset.  Handlers use it for datasets that are
+// not in the route, such as those of WebSocket subscriptions.
+func DatasetAllowed(c *gin.Context, dataset string) bool {
+	if !AuthEnabled() {
+		return true
+	}
+	key := GetAPIKey(c)
+	return key != nil && key.AllowsDataset(dataset)
+}
+
+// authenticate returns the valid API key presented by the request, or nil if there is none,
+// and an error if the store failed
+func authenticate(c *gin.Context, store KeyStore) (*APIKey, error) {
+	id, secret, ok := requestCredentials(c.Request)
+	if !ok {
+		return nil, nil
+	}
+	key, err := store.LookupKey(c.Request.Context(), id)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	if !key.checkSecret(secret) {
+		return nil, nil
+	}
+	return key, nil
+}
+
+// requestCredentials returns the API key ID and secret of a request's Authorization or
+// X-API-Key header, and true if it has them
+func requestCredentials(req *http.Request) (id string, secret string, ok bool) {
+	if id, secret, ok := req.BasicAuth(); ok {
+		return id, secret, true
+	}
+	token := req.Header.Get("X-API-Key")
+	if auth := req.Header.Get("Authorization"); token == "" && len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		token = auth[len("Bearer "):]
+	}
+	id, secret, ok = strings.Cut(strings.TrimSpace(token), ".")
+	return id, secret, ok && id != "" && secret != ""
+}
@@ -5,19 +5,41 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 )
 
-var allowedOrigins = GetEnvDefault("ALLOWED_ORIGINS", "*")
-var allowedHeaders = GetEnvDefault("ALLOWED_HEADERS", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization")
+// CorsConfig is the CORS configuration of the routes
+type CorsConfig struct {
+	AllowedOrigins string `yaml:"allowed_origins"` // "*" or a comma-separated list of origins
+	AllowedHeaders string `yaml:"allowed_headers"` // Comma-separated list of request headers
+}
+
+// DefaultCorsConfig is the CORS configuration unless SetCorsConfig is called
+var DefaultCorsConfig = CorsConfig{
+	AllowedOrigins: "*",
+	AllowedHeaders: "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-API-Key",
+}
+
+var corsConfig atomic.Pointer[CorsConfig]
+
+func init() {
+	SetCorsConfig(DefaultCorsConfig)
+}
+
+// SetCorsConfig sets the CORS configuration.  It may be changed while serving requests.
+func SetCorsConfig(config CorsConfig) {
+	corsConfig.Store(&config)
+}
 
 func CorsOptionHandlerWithVerbs(verbs ...string) gin.HandlerFunc {
 	allowMethods := strings.Join(verbs, ", ")
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", allowedOrigins)
+		corsConfig := corsConfig.Load()
+		c.Header("Access-Control-Allow-Origin", corsConfig.AllowedOrigins)
 		c.Header("Access-Control-Allow-Methods", allowMethods)
-		c.Header("Access-Control-Allow-Headers", allowedHeaders)
+		c.Header("Access-Control-Allow-Headers", corsConfig.AllowedHeaders)
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
@@ -25,3 +47,17 @@ func CorsOptionHandlerWithVerbs(verbs ...string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// IsOriginAllowed returns true if the request Origin is permitted by CorsConfig.AllowedOrigins.
+func IsOriginAllowed(origin string) bool {
+	corsConfig := corsConfig.Load()
+	if origin == "" || corsConfig.AllowedOrigins == "*" {
+		return true
+	}
+	for _, allowed := range strings.Split(corsConfig.AllowedOrigins, ",") {
+		if strings.TrimSpace(allowed) == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -35,6 +35,12 @@ var TradeMigrationTemplate string
 //go:embed sql/candles.sql.tpl
 var CandlesMigrationTemplate string
 
+// APIKeysMigrationTemplate is the SQL format string for the API keys table migration
+// Takes the "TableName"
+//
+//go:embed sql/api_keys.sql.tpl
+var APIKeysMigrationTemplate string
+
 ///////////////////////////////////////////////////////////////////////////////
 
 // RunMigration executes the templated migration string on the DuckDB connection.
@@ -21,19 +21,51 @@ func SetGinLogger(logger *zap.Logger) gin.HandlerFunc {
 	}
 }
 
-// GetGinLogger returns the current logger from a gin.Context
+// GetGinLogger returns the current logger from a gin.Context, with the request's trace and span IDs
+// if it is traced, and its API key ID if it is authenticated
 func GetGinLogger(c *gin.Context) *zap.Logger {
 	l, ok := c.Get("gin-logger")
 	if !ok {
 		return zap.NewNop()
 	}
+	if fields := RequestLogContext(c); len(fields) != 0 {
+		return l.(*zap.Logger).With(fields...)
+	}
 	return l.(*zap.Logger)
 }
 
-// CreateLogger creates a logger for our app
-// Honors APP_LOG_PATH environment variable, otherwise uses current directory
-func CreateLogger(appName string, isRelease bool) *zap.Logger {
-	logPath := os.Getenv("APP_LOG_PATH")
+// RequestLogContext is a ginzap context function that logs the trace and API key ID of each request
+func RequestLogContext(c *gin.Context) []zap.Field {
+	fields := TraceLogFields(c.Request.Context())
+	if key := GetAPIKey(c); key != nil {
+		fields = append(fields, zap.String("key_id", key.ID))
+	}
+	return fields
+}
+
+// LogConfig is the configuration of the app's log file
+type LogConfig struct {
+	Level      zapcore.Level `yaml:"level"`        // Minimum level logged
+	Path       string        `yaml:"path"`         // Directory of the log file
+	Release    bool          `yaml:"release"`      // Release mode logs only to the file, not stdout
+	MaxSizeMB  int           `yaml:"max_size_mb"`  // Size at which the log file is rotated
+	MaxBackups int           `yaml:"max_backups"`  // Number of rotated log files retained
+	MaxAgeDays int           `yaml:"max_age_days"` // Days rotated log files are retained
+}
+
+// DefaultLogConfig is the log configuration unless one is configured
+var DefaultLogConfig = LogConfig{
+	Level:      zapcore.DebugLevel,
+	Path:       ".",
+	MaxSizeMB:  100,
+	MaxBackups: 3,
+	MaxAgeDays: 7,
+}
+
+// CreateLogger creates a logger for our app, writing to a rotated file in the config's Path.
+// Returns the logger and its level, which may be changed while logging.
+func CreateLogger(appName string, config LogConfig) (*zap.Logger, zap.AtomicLevel) {
+	logPath := config.Path
 	if logPath == "" {
 		logPath = "."
 	}
@@ -41,12 +73,12 @@ func CreateLogger(appName string, isRelease bool) *zap.Logger {
 	// log rotation
 	file := zapcore.AddSync(&lumberjack.Logger{
 		Filename:   fmt.Sprintf("%s/%s.log", logPath, appName),
-		MaxSize:    100, // megabytes
-		MaxBackups: 3,
-		MaxAge:     7, // days
+		MaxSize:    config.MaxSizeMB, // megabytes
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAgeDays, // days
 	})
 
-	level := zap.NewAtomicLevelAt(zap.DebugLevel)
+	level := zap.NewAtomicLevelAt(config.Level)
 
 	productionCfg := zap.NewProductionEncoderConfig()
 	productionCfg.TimeKey = "timestamp"
@@ -55,7 +87,7 @@ func CreateLogger(appName string, isRelease bool) *zap.Logger {
 	fileEncoder := zapcore.NewJSONEncoder(productionCfg)
 
 	var logger *zap.Logger
-	if !isRelease {
+	if !config.Release {
 		developmentCfg := zap.NewDevelopmentEncoderConfig()
 		developmentCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
 		stdout := zapcore.AddSync(os.Stdout)
@@ -71,13 +103,13 @@ func CreateLogger(appName string, isRelease bool) *zap.Logger {
 		logger = zap.New(zapcore.NewCore(fileEncoder, file, level))
 	}
 
-	logger.Info("Logger initialized", zap.String("app", appName), zap.String("vcs", buildVcsSha()))
+	logger.Info("Logger initialized", zap.String("app", appName), zap.String("vcs", BuildVcsSha()))
 
-	return logger
+	return logger, level
 }
 
-// buildVcsSha returns the VCS revision and type of the current build.
-func buildVcsSha() string {
+// BuildVcsSha returns the VCS revision and type of the current build.
+func BuildVcsSha() string {
 	info, ok := debug.ReadBuildInfo()
 	if !ok {
 		return "unknown"
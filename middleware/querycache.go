@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package middleware
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// QueryCache is a least-recently-used cache of query results, which expire after a TTL.
+// Results are cached briefly, as the tables they query grow with live data.
+// A nil QueryCache, or one with no size or TTL, caches nothing.
+type QueryCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List // of *queryCacheEntry, most recently used first
+}
+
+// queryCacheEntry is a cached result
+type queryCacheEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+// NewQueryCache returns a QueryCache holding at most size results, each for ttl
+func NewQueryCache(size int, ttl time.Duration) *QueryCache {
+	return &QueryCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the cached result for key and true, or nil and false if there is none or it expired
+func (qc *QueryCache) Get(key string) (any, bool) {
+	if qc == nil {
+		return nil, false
+	}
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	elem, ok := qc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*queryCacheEntry)
+	if time.Now().After(entry.expires) {
+		qc.order.Remove(elem)
+		delete(qc.entries, key)
+		return nil, false
+	}
+	qc.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Put caches the result for key, evicting the least recently used result if full
+func (qc *QueryCache) Put(key string, value any) {
+	if qc == nil || qc.size <= 0 || qc.ttl <= 0 {
+		return
+	}
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	expires := time.Now().Add(qc.ttl)
+	if elem, ok := qc.entries[key]; ok {
+		entry := elem.Value.(*queryCacheEntry)
+		entry.value, entry.expires = value, expires
+		qc.order.MoveToFront(elem)
+		return
+	}
+	qc.entries[key] = qc.order.PushFront(&queryCacheEntry{key: key, value: value, expires: expires})
+	for qc.order.Len() > qc.size {
+		oldest := qc.order.Back()
+		qc.order.Remove(oldest)
+		delete(qc.entries, oldest.Value.(*queryCacheEntry).key)
+	}
+}
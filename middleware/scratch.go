@@ -0,0 +1,152 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrScratchBusy is returned by ScratchDir.Acquire when no export slot frees up in time
+var ErrScratchBusy = errors.New("too many concurrent exports")
+
+// scratchDirPrefix prefixes the private directory a ScratchDir uses under its parent, which is
+// suffixed with the user ID so users sharing a parent such as /tmp each get their own
+const scratchDirPrefix = "dbn-duckduck-goose-"
+
+// scratchFilePrefix prefixes every file a ScratchDir creates; only such files are swept
+const scratchFilePrefix = "export-"
+
+// ScratchDir is a private directory for temporary export files,
+// with a cap on the number of files in use at once.
+type ScratchDir struct {
+	path           string
+	slots          chan struct{}
+	acquireTimeout time.Duration
+}
+
+// NewScratchDir opens the private directory under the parent directory, allowing
+// maxConcurrent files in use at once.  The directory has the same path on every run,
+// so exports left behind by a crashed run are swept here.  It is created with mode 0700,
+// and an existing one must be a directory of that mode owned by this user.  Servers running
+// at once must not share a parent.  The parent is created if missing, but is otherwise left alone.
+// Acquire waits up to acquireTimeout for a free slot.  Returns nil and an error, if any.
+func NewScratchDir(parent string, maxConcurrent int, acquireTimeout time.Duration) (*ScratchDir, error) {
+	if maxConcurrent <= 0 {
+		return nil, fmt.Errorf("maxConcurrent must be greater than 0")
+	}
+	if err := os.MkdirAll(parent, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create scratch parent dir: %w", err)
+	}
+	path := filepath.Join(parent, fmt.Sprintf("%s%d", scratchDirPrefix, os.Getuid()))
+	if err := os.Mkdir(path, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("failed to create scratch dir: %w", err)
+	}
+	if err := checkScratchDir(path); err != nil {
+		return nil, err
+	}
+
+	sd := &ScratchDir{
+		path:           path,
+		slots:          make(chan struct{}, maxConcurrent),
+		acquireTimeout: acquireTimeout,
+	}
+	if err := sd.Sweep(); err != nil {
+		return nil, err
+	}
+	return sd, nil
+}
+
+// checkScratchDir returns an error unless the path is a private directory of this user.
+// It is not followed if it is a symlink.
+func checkScratchDir(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat scratch dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("scratch dir %s is not a directory", path)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		return fmt.Errorf("scratch dir %s has mode %#o, must be 0700", path, perm)
+	}
+	if !ownedByUser(info) {
+		return fmt.Errorf("scratch dir %s is not owned by this user", path)
+	}
+	return nil
+}
+
+// Path returns the scratch directory's path
+func (sd *ScratchDir) Path() string {
+	return sd.path
+}
+
+// Acquire reserves a slot and returns a new, empty filename in the scratch directory.
+// The pattern is as os.CreateTemp, e.g. "trades-*.csv", and is prefixed with "export-".  The release function must be
+// called when the file is no longer needed; it deletes the file and frees the slot.
+// Returns ErrScratchBusy if no slot frees up in time.
+func (sd *ScratchDir) Acquire(ctx context.Context, pattern string) (filename string, release func(), err error) {
+	timer := time.NewTimer(sd.acquireTimeout)
+	defer timer.Stop()
+	select {
+	case sd.slots <- struct{}{}:
+	case <-timer.C:
+		return "", nil, ErrScratchBusy
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+
+	file, err := os.CreateTemp(sd.path, scratchFilePrefix+pattern)
+	if err != nil {
+		<-sd.slots
+		return "", nil, fmt.Errorf("failed to create scratch file: %w", err)
+	}
+	filename = file.Name()
+	file.Close()
+
+	release = func() {
+		os.Remove(filename)
+		<-sd.slots
+	}
+	return filename, release, nil
+}
+
+// Sweep removes the export files in the scratch directory.
+// Only regular files created by Acquire are removed.
+func (sd *ScratchDir) Sweep() error {
+	entries, err := os.ReadDir(sd.path)
+	if err != nil {
+		return fmt.Errorf("failed to read scratch dir: %w", err)
+	}
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), scratchFilePrefix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(sd.path, entry.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to sweep scratch dir: %w", err)
+	}
+	return nil
+}
+
+// Close sweeps and removes the scratch directory.
+// The directory is left in place if it holds files other than exports.
+func (sd *ScratchDir) Close() error {
+	if err := sd.Sweep(); err != nil {
+		return err
+	}
+	if err := os.Remove(sd.path); err != nil {
+		return fmt.Errorf("failed to remove scratch dir: %w", err)
+	}
+	return nil
+}
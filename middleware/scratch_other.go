@@ -0,0 +1,12 @@
+// Copyright (c) 2025 Neomantra Corp
+
+//go:build !unix
+
+package middleware
+
+import "io/fs"
+
+// ownedByUser returns true, as file ownership is not checked on this platform
+func ownedByUser(info fs.FileInfo) bool {
+	return true
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2025 Neomantra Corp
+// scratch_test.go
+//
+// Tests that the ScratchDir only removes the files it created, and sweeps those of earlier runs.
+
+package middleware_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+)
+
+func TestScratchDirLeavesParentAlone(t *testing.T) {
+	parent := t.TempDir()
+	keep := filepath.Join(parent, "keep.txt")
+	if err := os.WriteFile(keep, []byte("keep"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sd, err := middleware.NewScratchDir(parent, 1, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create scratch dir: %s", err)
+	}
+	if filepath.Dir(sd.Path()) != parent {
+		t.Fatalf("scratch dir %s is not under %s", sd.Path(), parent)
+	}
+	if info, err := os.Stat(sd.Path()); err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("scratch dir is not private: %v %v", info, err)
+	}
+
+	filename, release, err := sd.Acquire(context.Background(), "trades-*.csv")
+	if err != nil {
+		t.Fatalf("failed to acquire scratch file: %s", err)
+	}
+	if _, _, err := sd.Acquire(context.Background(), "trades-*.csv"); err != middleware.ErrScratchBusy {
+		t.Fatalf("expected ErrScratchBusy, got %v", err)
+	}
+	release()
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("released file %s still exists", filename)
+	}
+
+	// an export left behind is swept on Close, along with the directory
+	if _, _, err := sd.Acquire(context.Background(), "trades-*.csv"); err != nil {
+		t.Fatalf("failed to acquire scratch file: %s", err)
+	}
+	if err := sd.Close(); err != nil {
+		t.Fatalf("failed to close scratch dir: %s", err)
+	}
+	if _, err := os.Stat(sd.Path()); !os.IsNotExist(err) {
+		t.Fatalf("scratch dir %s still exists", sd.Path())
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Fatalf("file in parent dir was removed: %s", err)
+	}
+}
+
+func TestScratchDirKeepsForeignFiles(t *testing.T) {
+	sd, err := middleware.NewScratchDir(t.TempDir(), 1, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create scratch dir: %s", err)
+	}
+	foreign := filepath.Join(sd.Path(), "foreign.txt")
+	if err := os.WriteFile(foreign, []byte("foreign"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.Sweep(); err != nil {
+		t.Fatalf("failed to sweep scratch dir: %s", err)
+	}
+	if _, err := os.Stat(foreign); err != nil {
+		t.Fatalf("foreign file was swept: %s", err)
+	}
+	if err := sd.Close(); err == nil {
+		t.Fatalf("expected Close to leave a non-empty directory in place")
+	}
+}
+
+func TestScratchDirSweepsPreviousRun(t *testing.T) {
+	parent := t.TempDir()
+	crashed, err := middleware.NewScratchDir(parent, 1, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create scratch dir: %s", err)
+	}
+	leftover, _, err := crashed.Acquire(context.Background(), "trades-*.csv")
+	if err != nil {
+		t.Fatalf("failed to acquire scratch file: %s", err)
+	}
+
+	// the next run opens the same directory and sweeps the export the crashed run left behind
+	sd, err := middleware.NewScratchDir(parent, 1, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to reopen scratch dir: %s", err)
+	}
+	if sd.Path() != crashed.Path() {
+		t.Fatalf("scratch dir moved from %s to %s", crashed.Path(), sd.Path())
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Fatalf("leftover export %s was not swept", leftover)
+	}
+}
+
+func TestScratchDirRejectsUnsafeDir(t *testing.T) {
+	parent := t.TempDir()
+	sd, err := middleware.NewScratchDir(parent, 1, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create scratch dir: %s", err)
+	}
+	path := sd.Path()
+	if err := os.Chmod(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := middleware.NewScratchDir(parent, 1, 10*time.Millisecond); err == nil {
+		t.Errorf("opened a scratch dir of mode 0755")
+	}
+
+	// a symlink to a private directory is not followed
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := middleware.NewScratchDir(parent, 1, 10*time.Millisecond); err == nil {
+		t.Errorf("opened a scratch dir that is a symlink")
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2025 Neomantra Corp
+
+//go:build unix
+
+package middleware
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// ownedByUser returns true if the file is owned by this process's user
+func ownedByUser(info fs.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
@@ -0,0 +1,8 @@
+-- Create API keys table
+CREATE TABLE IF NOT EXISTS {{.TableName}} (
+	id varchar PRIMARY KEY,
+	secret_hash varchar NOT NULL,
+	scopes varchar[] NOT NULL,
+	datasets varchar[] NOT NULL,
+	created_at timestamp NOT NULL DEFAULT current_timestamp
+);
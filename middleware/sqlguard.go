@@ -0,0 +1,188 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrQueryNotAllowed is wrapped by SQLGuard.Check's errors for queries it rejects
+var ErrQueryNotAllowed = errors.New("query not allowed")
+
+// DefaultQueryTables are the ingested tables open to ad-hoc queries
+var DefaultQueryTables = []string{"trades", "candles"}
+
+// sqlGuardSchemas are the schemas a query may name; tables are created in DuckDB's default schema
+var sqlGuardSchemas = []string{"", "main"}
+
+// SQLGuard restricts ad-hoc SQL to read-only queries of allowed tables.
+// It parses queries with DuckDB's json_serialize_sql, which only serializes SELECT statements,
+// and walks the syntax tree to check each table reference.  Table functions,
+// such as read_csv, are rejected as they reach outside the database.
+type SQLGuard struct {
+	duckdbConn *sql.DB
+	tables     []string
+	catalogs   []string // attached databases whose tables are all allowed
+}
+
+// NewSQLGuard returns an SQLGuard parsing with duckdbConn and allowing the named tables
+func NewSQLGuard(duckdbConn *sql.DB, tables []string) *SQLGuard {
+	allowed := make([]string, len(tables))
+	for i, table := range tables {
+		allowed[i] = strings.ToLower(table)
+	}
+	return &SQLGuard{duckdbConn: duckdbConn, tables: allowed}
+}
+
+// Tables returns the allowed tables
+func (g *SQLGuard) Tables() []string {
+	return slices.Clone(g.tables)
+}
+
+// WithCatalog returns a copy of the guard also allowing every table of the attached database
+// named catalog, such as one holding views that filter what the allowed tables may reveal
+func (g *SQLGuard) WithCatalog(catalog string) *SQLGuard {
+	allowed := *g
+	allowed.catalogs = append(slices.Clone(g.catalogs), strings.ToLower(catalog))
+	return &allowed
+}
+
+// Check returns nil if query is a single SELECT statement reading only allowed tables.
+// Returns an error wrapping ErrQueryNotAllowed if it is not, or another error if parsing failed.
+func (g *SQLGuard) Check(ctx context.Context, query string) error {
+	const parseQuery = "SELECT CAST(json_serialize_sql(CAST(? AS VARCHAR)) AS VARCHAR);"
+	var serialized string
+	ctx, span := StartQuerySpan(ctx, "parse sql", parseQuery)
+	err := g.duckdbConn.QueryRowContext(ctx, parseQuery, query).Scan(&serialized)
+	if err != nil {
+		EndQuerySpan(span, 0, err)
+		return fmt.Errorf("failed to parse query: %w", err)
+	}
+	EndQuerySpan(span, 1, nil)
+
+	var parsed struct {
+		Error        bool   `json:"error"`
+		ErrorMessage string `json:"error_message"`
+		Statements   []any  `json:"statements"`
+	}
+	if err := json.Unmarshal([]byte(serialized), &parsed); err != nil {
+		return fmt.Errorf("failed to decode parsed query: %w", err)
+	}
+	if parsed.Error {
+		return fmt.Errorf("%w: %s", ErrQueryNotAllowed, parsed.ErrorMessage)
+	}
+	if len(parsed.Statements) != 1 {
+		return fmt.Errorf("%w: expected 1 statement, got %d", ErrQueryNotAllowed, len(parsed.Statements))
+	}
+
+	return g.checkNode(parsed.Statements[0], nil)
+}
+
+// checkNode walks a serialized syntax tree node, returning an error wrapping
+// ErrQueryNotAllowed at the first disallowed table reference.  ctes holds the
+// names of the CTEs in scope at node, which unqualified table references may name.
+// As in DuckDB, a WITH clause's CTEs are in scope for its query and for the
+// definitions of the CTEs after them, but not for enclosing or sibling subqueries.
+func (g *SQLGuard) checkNode(node any, ctes map[string]bool) error {
+	switch node := node.(type) {
+	case []any:
+		for _, child := range node {
+			if err := g.checkNode(child, ctes); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		switch node["type"] {
+		case "TABLE_FUNCTION":
+			return fmt.Errorf("%w: table functions are not allowed", ErrQueryNotAllowed)
+		case "RECURSIVE_CTE_NODE":
+			// a recursive CTE's name is in scope for its own definition
+			name, _ := node["cte_name"].(string)
+			ctes = withCTEName(ctes, name)
+		case "BASE_TABLE":
+			catalog, _ := node["catalog_name"].(string)
+			schema, _ := node["schema_name"].(string)
+			table, _ := node["table_name"].(string)
+			table = strings.ToLower(table)
+			if catalog == "" && schema == "" && ctes[table] {
+				break
+			}
+			if catalog != "" && slices.Contains(g.catalogs, strings.ToLower(catalog)) {
+				break
+			}
+			if catalog != "" || !slices.Contains(sqlGuardSchemas, strings.ToLower(schema)) || !slices.Contains(g.tables, table) {
+				return fmt.Errorf("%w: table %s is not one of %s", ErrQueryNotAllowed, table, strings.Join(g.tables, ", "))
+			}
+		}
+
+		if cteMap, ok := node["cte_map"].(map[string]any); ok {
+			entries, _ := cteMap["map"].([]any)
+			for _, entry := range entries {
+				entry, _ := entry.(map[string]any)
+				if err := g.checkNode(entry["value"], ctes); err != nil {
+					return err
+				}
+				name, _ := entry["key"].(string)
+				ctes = withCTEName(ctes, name)
+			}
+		}
+		for key, child := range node {
+			if key == "cte_map" {
+				continue
+			}
+			if err := g.checkNode(child, ctes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// withCTEName returns a copy of the CTE scope ctes with name added, leaving ctes unchanged
+func withCTEName(ctes map[string]bool, name string) map[string]bool {
+	scope := make(map[string]bool, len(ctes)+1)
+	for cte := range ctes {
+		scope[cte] = true
+	}
+	if name != "" {
+		scope[strings.ToLower(name)] = true
+	}
+	return scope
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// BeginReadOnly returns a connection of duckdbConn's pool in a read-only transaction, for
+// running queries the SQLGuard admitted; DuckDB fails any write attempted within it.
+// The connection is returned to the pool with EndReadOnly.
+func BeginReadOnly(ctx context.Context, duckdbConn *sql.DB) (*sql.Conn, error) {
+	conn, err := duckdbConn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION READ ONLY;"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+	return conn, nil
+}
+
+// EndReadOnly rolls back the transaction of a connection from BeginReadOnly and returns it
+// to the pool.  If the rollback fails, the connection is discarded instead, as one left in a
+// read-only transaction would block writes.  Returns the rollback's error, if any.
+func EndReadOnly(conn *sql.Conn) error {
+	_, err := conn.ExecContext(context.Background(), "ROLLBACK;")
+	if err != nil {
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+		err = fmt.Errorf("failed to end read-only transaction: %w", err)
+	}
+	conn.Close()
+	return err
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2025 Neomantra Corp
+// sqlguard_test.go
+//
+// Tests of the SQLGuard against DuckDB's parser and name resolution.
+
+package middleware_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	_ "github.com/marcboeker/go-duckdb/v2"
+)
+
+// guardSecret is stored in the disallowed api_keys table; no allowed query may return it
+const guardSecret = "secret"
+
+// openGuardDB returns an in-memory DuckDB with allowed trades and candles tables and a disallowed api_keys table
+func openGuardDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("failed to open database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range []string{
+		"CREATE TABLE trades (ticker VARCHAR, price DOUBLE);",
+		"INSERT INTO trades VALUES ('AAPL', 1.5);",
+		"CREATE TABLE candles (ticker VARCHAR, close DOUBLE);",
+		"INSERT INTO candles VALUES ('AAPL', 2.5);",
+		"CREATE TABLE api_keys (token VARCHAR);",
+		"INSERT INTO api_keys VALUES ('" + guardSecret + "');",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to set up database: %s", err)
+		}
+	}
+	return db
+}
+
+func TestSQLGuardCheck(t *testing.T) {
+	db := openGuardDB(t)
+	guard := middleware.NewSQLGuard(db, middleware.DefaultQueryTables)
+
+	tests := []struct {
+		name    string
+		query   string
+		allowed bool
+	}{
+		{"select", "SELECT * FROM trades", true},
+		{"join", "SELECT * FROM trades t JOIN candles c USING (ticker)", true},
+		{"qualified", "SELECT * FROM main.trades", true},
+		{"case insensitive", "SELECT * FROM TRADES", true},
+		{"union", "SELECT ticker FROM trades UNION ALL SELECT ticker FROM candles", true},
+		{"no tables", "SELECT 42", true},
+		{"cte", "WITH t AS (SELECT * FROM trades) SELECT * FROM t", true},
+		{"cte chain", "WITH a AS (SELECT * FROM trades), b AS (SELECT * FROM a) SELECT * FROM b", true},
+		{"cte shadowing", "WITH api_keys AS (SELECT * FROM trades) SELECT * FROM api_keys", true},
+		{"nested cte shadowing", "SELECT * FROM (WITH api_keys AS (SELECT 1) SELECT * FROM api_keys) t", true},
+		{"outer cte in subquery", "WITH api_keys AS (SELECT 1) SELECT * FROM (SELECT * FROM api_keys) t", true},
+		{"recursive cte", "WITH RECURSIVE api_keys(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM api_keys WHERE n < 3) SELECT * FROM api_keys", true},
+
+		{"table", "SELECT * FROM api_keys", false},
+		{"qualified table", "SELECT * FROM main.api_keys", false},
+		{"catalog", "SELECT * FROM memory.main.trades", false},
+		{"schema", "SELECT * FROM information_schema.tables", false},
+		{"shorthand", "FROM api_keys", false},
+		{"describe", "DESCRIBE api_keys", false},
+		{"union table", "SELECT 'x' UNION ALL SELECT token FROM api_keys", false},
+		{"scalar subquery", "SELECT (SELECT token FROM api_keys)", false},
+		{"lateral", "SELECT * FROM trades, LATERAL (SELECT token FROM api_keys) k", false},
+		{"sibling cte shadowing", "SELECT k.* FROM (WITH api_keys AS (SELECT 1) SELECT 1) t, api_keys k", false},
+		{"sibling subquery cte shadowing", "SELECT (SELECT 1 FROM (WITH api_keys AS (SELECT 1) SELECT 1) t), (SELECT token FROM api_keys)", false},
+		{"inner cte not in outer scope", "SELECT * FROM (WITH api_keys AS (SELECT 1) SELECT 1) t WHERE EXISTS (SELECT token FROM api_keys)", false},
+		{"cte self reference", "WITH api_keys AS (SELECT * FROM api_keys) SELECT * FROM api_keys", false},
+		{"cte qualified reference", "WITH api_keys AS (SELECT 1) SELECT * FROM main.api_keys", false},
+		{"table function", "SELECT * FROM read_csv('/etc/passwd')", false},
+		{"table function in cte", "WITH t AS (SELECT * FROM duckdb_tables()) SELECT * FROM t", false},
+		{"file", "SELECT * FROM '/etc/passwd'", false},
+		{"multiple statements", "SELECT * FROM trades; SELECT * FROM api_keys", false},
+		{"multiple selects", "SELECT 1; SELECT 2", false},
+		{"delete", "DELETE FROM trades", false},
+		{"insert", "INSERT INTO trades VALUES ('MSFT', 1)", false},
+		{"create", "CREATE TABLE t AS SELECT * FROM trades", false},
+		{"drop", "DROP TABLE trades", false},
+		{"attach", "ATTACH '/tmp/other.db'", false},
+		{"pragma", "PRAGMA database_list", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.Check(context.Background(), tt.query)
+			if !tt.allowed {
+				if !errors.Is(err, middleware.ErrQueryNotAllowed) {
+					t.Fatalf("expected ErrQueryNotAllowed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected query to be allowed, got %s", err)
+			}
+
+			// allowed queries must run without reading api_keys
+			rows, err := db.Query(tt.query)
+			if err != nil {
+				t.Fatalf("allowed query failed: %s", err)
+			}
+			defer rows.Close()
+			cols, _ := rows.Columns()
+			for rows.Next() {
+				values := make([]any, len(cols))
+				ptrs := make([]any, len(cols))
+				for i := range values {
+					ptrs[i] = &values[i]
+				}
+				if err := rows.Scan(ptrs...); err != nil {
+					t.Fatalf("failed to scan row: %s", err)
+				}
+				for _, value := range values {
+					if s, ok := value.(string); ok && strings.Contains(s, guardSecret) {
+						t.Fatalf("allowed query read api_keys")
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSQLGuardTables(t *testing.T) {
+	guard := middleware.NewSQLGuard(openGuardDB(t), []string{"Trades"})
+	if err := guard.Check(context.Background(), "SELECT * FROM candles"); !errors.Is(err, middleware.ErrQueryNotAllowed) {
+		t.Fatalf("expected candles to be rejected, got %v", err)
+	}
+	if tables := guard.Tables(); len(tables) != 1 || tables[0] != "trades" {
+		t.Fatalf("unexpected tables %v", tables)
+	}
+}
+
+func TestSQLGuardWithCatalog(t *testing.T) {
+	db := openGuardDB(t)
+	if _, err := db.Exec("ATTACH ':memory:' AS filtered; CREATE VIEW filtered.main.tables AS SELECT 1 AS n;"); err != nil {
+		t.Fatalf("failed to attach database: %s", err)
+	}
+	guard := middleware.NewSQLGuard(db, middleware.DefaultQueryTables)
+	withCatalog := guard.WithCatalog("Filtered")
+	if err := withCatalog.Check(context.Background(), "SELECT * FROM filtered.main.tables, trades"); err != nil {
+		t.Fatalf("expected the catalog to be allowed, got %s", err)
+	}
+	for _, query := range []string{"SELECT * FROM api_keys", "SELECT * FROM memory.main.api_keys"} {
+		if err := withCatalog.Check(context.Background(), query); !errors.Is(err, middleware.ErrQueryNotAllowed) {
+			t.Errorf("expected %q to be rejected, got %v", query, err)
+		}
+	}
+	if err := guard.Check(context.Background(), "SELECT * FROM filtered.main.tables"); !errors.Is(err, middleware.ErrQueryNotAllowed) {
+		t.Errorf("expected the original guard to reject the catalog, got %v", err)
+	}
+}
+
+func TestBeginReadOnly(t *testing.T) {
+	db := openGuardDB(t)
+	conn, err := middleware.BeginReadOnly(context.Background(), db)
+	if err != nil {
+		t.Fatalf("BeginReadOnly: %s", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "DELETE FROM trades;"); err == nil {
+		t.Errorf("expected a write within the read-only transaction to fail")
+	}
+	if err := middleware.EndReadOnly(conn); err != nil {
+		t.Fatalf("EndReadOnly: %s", err)
+	}
+	if _, err := db.Exec("DELETE FROM trades;"); err != nil {
+		t.Errorf("expected writes after EndReadOnly to succeed, got %s", err)
+	}
+}
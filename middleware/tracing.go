@@ -0,0 +1,122 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Tracing exporters of TracingConfig
+const (
+	TracingExporterNone   = ""       // tracing is disabled
+	TracingExporterOtlp   = "otlp"   // OTLP over HTTP to a collector
+	TracingExporterStdout = "stdout" // pretty-printed JSON to stdout, for debugging
+)
+
+// TracingConfig is the configuration of OpenTelemetry tracing
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`     // "otlp", "stdout", or empty to disable tracing
+	Endpoint    string  `yaml:"endpoint"`     // 'host:port' of the OTLP/HTTP collector
+	Insecure    bool    `yaml:"insecure"`     // Send to the collector over HTTP rather than HTTPS
+	SampleRatio float64 `yaml:"sample_ratio"` // Fraction of new traces sampled, from 0 to 1
+}
+
+// DefaultTracingConfig is the tracing configuration unless one is configured
+var DefaultTracingConfig = TracingConfig{
+	Endpoint:    "localhost:4318",
+	Insecure:    true,
+	SampleRatio: 1,
+}
+
+// Tracer is the app's OpenTelemetry tracer.  Its spans are dropped unless InitTracing enables an exporter.
+var Tracer = otel.Tracer("github.com/NimbleMarkets/dbn-duckduck-goose")
+
+// InitTracing sets the global OpenTelemetry tracer provider and propagator for the config's exporter.
+// Returns a function that flushes and stops the exporter, and an error, if any.
+// If tracing is disabled, the shutdown function does nothing.
+func InitTracing(ctx context.Context, appName string, config TracingConfig) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case TracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case TracingExporterOtlp:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, must be %q or %q", config.Exporter, TracingExporterOtlp, TracingExporterStdout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", config.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", appName),
+		attribute.String("service.version", BuildVcsSha()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// StartQuerySpan starts a child span of ctx for a DuckDB query, recording its SQL.
+// End it with EndQuerySpan.
+func StartQuerySpan(ctx context.Context, name string, query string) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "duckdb"),
+		attribute.String("db.statement", query),
+	))
+}
+
+// EndQuerySpan records the rows read, or the error, of a DuckDB query and ends its span
+func EndQuerySpan(span trace.Span, rowCount int, err error) {
+	span.SetAttributes(attribute.Int("db.row_count", rowCount))
+	EndSpan(span, err)
+}
+
+// EndSpan records the error of a span, if any, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceLogFields returns the zap fields of the trace and span of ctx, or none if it has no valid span
+func TraceLogFields(ctx context.Context) []zap.Field {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String("trace_id", spanContext.TraceID().String()),
+		zap.String("span_id", spanContext.SpanID().String()),
+	}
+}
@@ -35,6 +35,40 @@ func BadRequestError(c *gin.Context, err error) {
 	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 }
 
+// NotFoundError responds to a request with an http.StatusNotFound and error
+func NotFoundError(c *gin.Context, err error) {
+	c.Error(err)
+	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+}
+
+// UnauthorizedError responds to a request with an http.StatusUnauthorized, error and the
+// authentication schemes it accepts
+func UnauthorizedError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Writer.Header().Add("WWW-Authenticate", `Bearer realm="dbn-duckduck-goose"`)
+	c.Writer.Header().Add("WWW-Authenticate", `Basic realm="dbn-duckduck-goose", charset="UTF-8"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+}
+
+// ForbiddenError responds to a request with an http.StatusForbidden and error
+func ForbiddenError(c *gin.Context, err error) {
+	c.Error(err)
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": err.Error()})
+}
+
+// ServiceUnavailableError responds to a request with an http.StatusServiceUnavailable, error and Retry-After
+func ServiceUnavailableError(c *gin.Context, err error, retryAfter time.Duration) {
+	c.Error(err)
+	c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": err.Error()})
+}
+
+// GatewayTimeoutError responds to a request with an http.StatusGatewayTimeout and error
+func GatewayTimeoutError(c *gin.Context, err error) {
+	c.Error(err)
+	c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"message": err.Error()})
+}
+
 // ValidatePositiveNonzeroInteger checks if the input string is a positive non-zero integer.
 // Returns a descriptive error if the input is not valid.
 func ValidatePositiveNonzeroInteger(str string) (int, error) {
@@ -0,0 +1,227 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package pgserver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+)
+
+// catalogDatabase is the in-memory database attached for the filtered catalog views
+const catalogDatabase = "pgwire_catalog"
+
+// catalogSchemas are the schemas of catalogDatabase holding the filtered views of
+// each catalog schema, which DuckDB does not allow to be named pg_catalog or information_schema
+var catalogSchemas = map[string]string{
+	"pg_catalog":         "pg",
+	"information_schema": "information",
+}
+
+// catalogView is a view of DuckDB's catalog schemas filtered to the allowed tables
+type catalogView struct {
+	schema string
+	name   string
+	filter string // WHERE condition with the placeholders of catalogFilters; empty for views revealing no tables
+}
+
+// catalogViews are the catalog views open to introspection.  Other catalog views, such as pg_settings, are not.
+var catalogViews = []catalogView{
+	{"pg_catalog", "pg_namespace", "oid IN ({namespaces})"},
+	{"pg_catalog", "pg_class", "oid IN ({relations}) OR oid IN (SELECT index_oid FROM duckdb_indexes() WHERE table_oid IN ({relations}))"},
+	{"pg_catalog", "pg_attribute", "attrelid IN ({relations})"},
+	{"pg_catalog", "pg_attrdef", "adrelid IN ({relations})"},
+	{"pg_catalog", "pg_index", "indrelid IN ({relations})"},
+	{"pg_catalog", "pg_constraint", "conrelid IN ({relations})"},
+	{"pg_catalog", "pg_description", "objoid IN ({relations})"},
+	{"pg_catalog", "pg_indexes", "schemaname = 'main' AND lower(tablename) IN ({tables})"},
+	{"pg_catalog", "pg_tables", "schemaname = 'main' AND lower(tablename) IN ({tables})"},
+	{"pg_catalog", "pg_views", "schemaname = 'main' AND lower(viewname) IN ({tables})"},
+	{"pg_catalog", "pg_database", "datname = {database}"},
+	{"pg_catalog", "pg_type", ""},
+	{"pg_catalog", "pg_am", ""},
+	{"pg_catalog", "pg_tablespace", ""},
+	{"information_schema", "schemata", "catalog_name = {database} AND schema_name = 'main'"},
+	{"information_schema", "tables", "{table_columns}"},
+	{"information_schema", "columns", "{table_columns}"},
+	{"information_schema", "views", "{table_columns}"},
+	{"information_schema", "table_constraints", "{table_columns}"},
+	{"information_schema", "key_column_usage", "{table_columns}"},
+	{"information_schema", "constraint_column_usage", "{table_columns}"},
+	{"information_schema", "constraint_table_usage", "{table_columns}"},
+}
+
+// catalogFilters are the placeholders of catalogView filters, expanded in order
+var catalogFilters = []string{
+	"{table_columns}", "table_catalog = {database} AND table_schema = 'main' AND lower(table_name) IN ({tables})",
+	"{relations}", "SELECT table_oid FROM duckdb_tables() WHERE database_name = {database} AND schema_name = 'main' AND lower(table_name) IN ({tables})" +
+		" UNION ALL SELECT view_oid FROM duckdb_views() WHERE database_name = {database} AND schema_name = 'main' AND lower(view_name) IN ({tables})",
+	"{namespaces}", "SELECT oid FROM duckdb_schemas() WHERE (database_name = {database} AND schema_name = 'main')" +
+		" OR (database_name = 'system' AND schema_name IN ('pg_catalog', 'information_schema'))",
+}
+
+// createCatalogViews attaches catalogDatabase to duckdbConn and creates the catalog views,
+// filtered to the tables of the current database.  Returns an error, if any.
+func createCatalogViews(duckdbConn *sql.DB, tables []string) error {
+	var database string
+	if err := duckdbConn.QueryRow("SELECT current_database();").Scan(&database); err != nil {
+		return fmt.Errorf("failed to query catalog: %w", err)
+	}
+	quotedTables := make([]string, len(tables))
+	for i, table := range tables {
+		quotedTables[i] = quoteLiteral(strings.ToLower(table))
+	}
+	var expansions []*strings.Replacer
+	for i := 0; i < len(catalogFilters); i += 2 {
+		expansions = append(expansions, strings.NewReplacer(catalogFilters[i], catalogFilters[i+1]))
+	}
+	values := strings.NewReplacer("{database}", quoteLiteral(database), "{tables}", strings.Join(quotedTables, ", "))
+
+	stmts := []string{fmt.Sprintf("ATTACH IF NOT EXISTS ':memory:' AS %s;", catalogDatabase)}
+	for _, schema := range catalogSchemas {
+		stmts = append(stmts, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s.%s;", catalogDatabase, schema))
+	}
+	for _, view := range catalogViews {
+		filter := view.filter
+		for _, expansion := range expansions {
+			filter = expansion.Replace(filter)
+		}
+		stmt := fmt.Sprintf("CREATE OR REPLACE VIEW %s.%s.%s AS SELECT * FROM system.%s.%s",
+			catalogDatabase, catalogSchemas[view.schema], view.name, view.schema, view.name)
+		if filter != "" {
+			stmt += " WHERE " + values.Replace(filter)
+		}
+		stmts = append(stmts, stmt+";")
+	}
+	for _, stmt := range stmts {
+		if _, err := duckdbConn.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create catalog views: %w", err)
+		}
+	}
+	return nil
+}
+
+// quoteLiteral returns s as an SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// rewriteCatalogQuery returns query with its references to catalog views redirected to the
+// filtered views, or query itself if it has none.  As in Postgres, unqualified names of
+// pg_catalog views refer to them.  Queries DuckDB cannot serialize are returned as is,
+// for the SQLGuard to reject.
+func rewriteCatalogQuery(ctx context.Context, duckdbConn *sql.DB, query string) (string, error) {
+	const serializeQuery = "SELECT CAST(json_serialize_sql(CAST(? AS VARCHAR)) AS VARCHAR);"
+	var serialized string
+	spanCtx, span := middleware.StartQuerySpan(ctx, "parse catalog sql", serializeQuery)
+	err := duckdbConn.QueryRowContext(spanCtx, serializeQuery, query).Scan(&serialized)
+	if err != nil {
+		middleware.EndQuerySpan(span, 0, err)
+		return "", fmt.Errorf("failed to parse query: %w", err)
+	}
+	middleware.EndQuerySpan(span, 1, nil)
+
+	// numbers are kept as written, as constants may not fit a float64
+	decoder := json.NewDecoder(strings.NewReader(serialized))
+	decoder.UseNumber()
+	var parsed map[string]any
+	if err := decoder.Decode(&parsed); err != nil {
+		return "", fmt.Errorf("failed to decode parsed query: %w", err)
+	}
+	if parsed["error"] == true {
+		return query, nil
+	}
+	ctes := make(map[string]bool)
+	collectCTENames(parsed, ctes)
+	if !redirectCatalogViews(parsed, ctes) {
+		return query, nil
+	}
+
+	var modified bytes.Buffer
+	if err := json.NewEncoder(&modified).Encode(parsed); err != nil {
+		return "", fmt.Errorf("failed to encode rewritten query: %w", err)
+	}
+	const deserializeQuery = "SELECT json_deserialize_sql(CAST(? AS JSON));"
+	var rewritten string
+	spanCtx, span = middleware.StartQuerySpan(ctx, "rewrite catalog sql", deserializeQuery)
+	err = duckdbConn.QueryRowContext(spanCtx, deserializeQuery, modified.String()).Scan(&rewritten)
+	middleware.EndQuerySpan(span, 1, err)
+	if err != nil {
+		return "", fmt.Errorf("failed to rewrite query: %w", err)
+	}
+	return rewritten, nil
+}
+
+// collectCTENames adds the names of all CTEs of a serialized syntax tree node to ctes
+func collectCTENames(node any, ctes map[string]bool) {
+	switch node := node.(type) {
+	case []any:
+		for _, child := range node {
+			collectCTENames(child, ctes)
+		}
+	case map[string]any:
+		if name, ok := node["cte_name"].(string); ok && node["type"] == "RECURSIVE_CTE_NODE" {
+			ctes[strings.ToLower(name)] = true
+		}
+		if cteMap, ok := node["cte_map"].(map[string]any); ok {
+			entries, _ := cteMap["map"].([]any)
+			for _, entry := range entries {
+				entry, _ := entry.(map[string]any)
+				if name, ok := entry["key"].(string); ok {
+					ctes[strings.ToLower(name)] = true
+				}
+			}
+		}
+		for _, child := range node {
+			collectCTENames(child, ctes)
+		}
+	}
+}
+
+// redirectCatalogViews points the catalog view references of a serialized syntax tree node
+// at the filtered views, except unqualified names of CTEs.  Returns true if any were.
+func redirectCatalogViews(node any, ctes map[string]bool) bool {
+	redirected := false
+	switch node := node.(type) {
+	case []any:
+		for _, child := range node {
+			redirected = redirectCatalogViews(child, ctes) || redirected
+		}
+	case map[string]any:
+		if node["type"] == "BASE_TABLE" {
+			catalog, _ := node["catalog_name"].(string)
+			schema, _ := node["schema_name"].(string)
+			table, _ := node["table_name"].(string)
+			schema, table = strings.ToLower(schema), strings.ToLower(table)
+			if schema == "" && !ctes[table] {
+				schema = "pg_catalog"
+			}
+			if catalog == "" && isCatalogView(schema, table) {
+				node["catalog_name"] = catalogDatabase
+				node["schema_name"] = catalogSchemas[schema]
+				return true
+			}
+		}
+		for _, child := range node {
+			redirected = redirectCatalogViews(child, ctes) || redirected
+		}
+	}
+	return redirected
+}
+
+// isCatalogView returns true if schema.name is one of the catalogViews
+func isCatalogView(schema string, name string) bool {
+	for _, view := range catalogViews {
+		if view.schema == schema && view.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package pgserver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionCommand is a statement handled by the session rather than DuckDB,
+// such as the SET and BEGIN statements clients send when connecting
+type sessionCommand struct {
+	tag   string // CommandComplete tag, e.g. "SET"
+	name  string // parameter name of SET, RESET and SHOW, lowercase; "all" for RESET ALL
+	value string // parameter value of SET
+	show  bool   // true for SHOW, which returns the parameter as a row
+}
+
+var (
+	sessionKeywordPat = regexp.MustCompile(`^\s*([A-Za-z]+)`)
+	setTransactionPat = regexp.MustCompile(`(?is)^\s*SET\s+(SESSION\s+CHARACTERISTICS|TRANSACTION)\b`)
+	setPat            = regexp.MustCompile(`(?is)^\s*SET\s+(?:SESSION\s+|LOCAL\s+)?(TIME\s+ZONE|[\w.]+)\s*(?:=|\s+TO\s|\s)\s*(.*?)\s*;?\s*$`)
+	resetPat          = regexp.MustCompile(`(?is)^\s*RESET\s+([\w.]+)\s*;?\s*$`)
+	showPat           = regexp.MustCompile(`(?is)^\s*SHOW\s+(TIME\s+ZONE|TRANSACTION\s+ISOLATION\s+LEVEL|[\w.]+)\s*;?\s*$`)
+	whitespacePat     = regexp.MustCompile(`\s+`)
+)
+
+// showAliases are the parameter names of SHOW's multi-word forms
+var showAliases = map[string]string{
+	"time zone":                   "timezone",
+	"transaction isolation level": "transaction_isolation",
+}
+
+// parseSessionCommand returns the session command of a query, or nil if it is for DuckDB.
+// Returns an error for malformed session commands.
+func parseSessionCommand(query string) (*sessionCommand, error) {
+	match := sessionKeywordPat.FindStringSubmatch(query)
+	if match == nil {
+		return nil, nil
+	}
+	switch keyword := strings.ToUpper(match[1]); keyword {
+	case "BEGIN", "START":
+		return &sessionCommand{tag: "BEGIN"}, nil
+	case "COMMIT", "END":
+		return &sessionCommand{tag: "COMMIT"}, nil
+	case "ROLLBACK", "ABORT":
+		return &sessionCommand{tag: "ROLLBACK"}, nil
+	case "DISCARD":
+		return &sessionCommand{tag: "DISCARD ALL", name: "all"}, nil
+	case "DEALLOCATE":
+		return &sessionCommand{tag: "DEALLOCATE"}, nil
+	case "SET":
+		if setTransactionPat.MatchString(query) {
+			return &sessionCommand{tag: "SET"}, nil // sessions are read-only regardless
+		}
+		set := setPat.FindStringSubmatch(query)
+		if set == nil {
+			return nil, newError(codeSyntaxError, "malformed SET statement")
+		}
+		return &sessionCommand{tag: "SET", name: parameterName(set[1]), value: unquote(set[2])}, nil
+	case "RESET":
+		reset := resetPat.FindStringSubmatch(query)
+		if reset == nil {
+			return nil, newError(codeSyntaxError, "malformed RESET statement")
+		}
+		return &sessionCommand{tag: "RESET", name: parameterName(reset[1])}, nil
+	case "SHOW":
+		show := showPat.FindStringSubmatch(query)
+		if show == nil {
+			return nil, newError(codeSyntaxError, "malformed SHOW statement")
+		}
+		return &sessionCommand{tag: "SHOW", name: parameterName(show[1]), show: true}, nil
+	}
+	return nil, nil
+}
+
+// parameterName normalizes a parameter name as written in a statement
+func parameterName(name string) string {
+	name = strings.ToLower(whitespacePat.ReplaceAllString(name, " "))
+	if alias, ok := showAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// unquote removes the quotes around a SET value, if any
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseStatementTimeout parses a statement_timeout value, in milliseconds or with units
+// such as "5s" or "1min".  Zero means no timeout of the session's own.
+func parseStatementTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("statement_timeout cannot be negative")
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	value = strings.ReplaceAll(value, " ", "")
+	value = strings.Replace(value, "min", "m", 1)
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid value for statement_timeout: %q", value)
+	}
+	return timeout, nil
+}
+
+// formatStatementTimeout formats a statement timeout as SHOW returns it
+func formatStatementTimeout(timeout time.Duration) string {
+	if timeout%time.Second == 0 {
+		return fmt.Sprintf("%ds", timeout/time.Second)
+	}
+	return fmt.Sprintf("%dms", timeout/time.Millisecond)
+}
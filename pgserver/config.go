@@ -0,0 +1,18 @@
+// Copyright (c) 2025 Neomantra Corp
+
+// Package pgserver serves the DuckDB store read-only over the PostgreSQL wire protocol,
+// for clients such as psql and BI tools.  Clients authenticate with SCRAM-SHA-256 over
+// unencrypted connections.  Queries of pg_catalog and information_schema are answered
+// from views filtered to the tables open to queries, for tools that introspect the catalog.
+package pgserver
+
+import "time"
+
+// Config is the configuration of a PostgreSQL wire protocol server
+type Config struct {
+	HostPort         string        `yaml:"hostport"`          // 'host:port' to listen on
+	Username         string        `yaml:"user"`              // Username clients authenticate with
+	Password         string        `yaml:"password"`          // Password clients authenticate with
+	StatementTimeout time.Duration `yaml:"statement_timeout"` // Maximum time to execute a statement; sessions may only lower it
+	Tables           []string      `yaml:"-"`                 // Tables and views open to queries (default: middleware.DefaultQueryTables)
+}
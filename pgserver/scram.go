@@ -0,0 +1,137 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package pgserver
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// scramMechanism is the SASL mechanism we offer; without TLS, there is no channel binding to offer
+const scramMechanism = "SCRAM-SHA-256"
+
+// scramIterations is the PBKDF2 iteration count of the salted password, as PostgreSQL's default
+const scramIterations = 4096
+
+// errSCRAMFailed is returned for a failed exchange; its cause is not told to the client
+var errSCRAMFailed = errors.New("SCRAM authentication failed")
+
+// scramServer is the server side of a SCRAM-SHA-256 exchange (RFC 5802, RFC 7677).
+// As the server holds the plain password, each exchange uses a fresh salt.
+// Like PostgreSQL, the username of the exchange is ignored for the startup message's.
+// Passwords are not SASLprep normalized, which only matters for non-ASCII passwords.
+type scramServer struct {
+	password        string
+	salt            []byte
+	nonce           string // client and server nonce
+	gs2Header       string // e.g. "n,,"
+	clientFirstBare string
+	serverFirst     string
+}
+
+// newSCRAMServer returns a scramServer verifying the password.  Returns nil and an error, if any.
+func newSCRAMServer(password string) (*scramServer, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to create salt: %w", err)
+	}
+	return &scramServer{password: password, salt: salt}, nil
+}
+
+// firstMessage takes the client-first-message and returns the server-first-message.
+// Returns an error if the message is malformed or requires channel binding.
+func (s *scramServer) firstMessage(clientFirst string) (string, error) {
+	// gs2-header is the channel binding flag and an authzid, e.g. "n,,"
+	flag, rest, ok := strings.Cut(clientFirst, ",")
+	if !ok {
+		return "", fmt.Errorf("%w: malformed client-first-message", errSCRAMFailed)
+	}
+	switch {
+	case flag == "n" || flag == "y":
+	case strings.HasPrefix(flag, "p="):
+		return "", fmt.Errorf("%w: channel binding is not supported", errSCRAMFailed)
+	default:
+		return "", fmt.Errorf("%w: malformed channel binding flag", errSCRAMFailed)
+	}
+	authzid, bare, ok := strings.Cut(rest, ",")
+	if !ok || authzid != "" {
+		return "", fmt.Errorf("%w: authzid is not supported", errSCRAMFailed)
+	}
+	clientNonce := scramAttribute(bare, 'r')
+	if clientNonce == "" || strings.HasPrefix(bare, "m=") {
+		return "", fmt.Errorf("%w: malformed client-first-message", errSCRAMFailed)
+	}
+
+	serverNonce := make([]byte, 18)
+	if _, err := rand.Read(serverNonce); err != nil {
+		return "", fmt.Errorf("failed to create nonce: %w", err)
+	}
+	s.gs2Header = clientFirst[:len(clientFirst)-len(bare)]
+	s.clientFirstBare = bare
+	s.nonce = clientNonce + base64.StdEncoding.EncodeToString(serverNonce)
+	s.serverFirst = fmt.Sprintf("r=%s,s=%s,i=%d", s.nonce, base64.StdEncoding.EncodeToString(s.salt), scramIterations)
+	return s.serverFirst, nil
+}
+
+// finalMessage takes the client-final-message, verifies its proof of the password,
+// and returns the server-final-message.  Returns an error wrapping errSCRAMFailed if it does not verify.
+func (s *scramServer) finalMessage(clientFinal string) (string, error) {
+	withoutProof, proofAttr, ok := strings.Cut(clientFinal, ",p=")
+	if !ok {
+		return "", fmt.Errorf("%w: missing proof", errSCRAMFailed)
+	}
+	if scramAttribute(withoutProof, 'c') != base64.StdEncoding.EncodeToString([]byte(s.gs2Header)) {
+		return "", fmt.Errorf("%w: channel binding mismatch", errSCRAMFailed)
+	}
+	if scramAttribute(withoutProof, 'r') != s.nonce {
+		return "", fmt.Errorf("%w: nonce mismatch", errSCRAMFailed)
+	}
+	proof, err := base64.StdEncoding.DecodeString(proofAttr)
+	if err != nil || len(proof) != sha256.Size {
+		return "", fmt.Errorf("%w: malformed proof", errSCRAMFailed)
+	}
+
+	saltedPassword, err := pbkdf2.Key(sha256.New, s.password, s.salt, scramIterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	clientKey := scramHMAC(saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	authMessage := s.clientFirstBare + "," + s.serverFirst + "," + withoutProof
+	clientSignature := scramHMAC(storedKey[:], authMessage)
+
+	// the proof is the client key masked by the signature
+	proofKey := make([]byte, sha256.Size)
+	subtle.XORBytes(proofKey, proof, clientSignature)
+	proofStoredKey := sha256.Sum256(proofKey)
+	if subtle.ConstantTimeCompare(proofStoredKey[:], storedKey[:]) != 1 {
+		return "", fmt.Errorf("%w: wrong password", errSCRAMFailed)
+	}
+
+	serverSignature := scramHMAC(scramHMAC(saltedPassword, "Server Key"), authMessage)
+	return "v=" + base64.StdEncoding.EncodeToString(serverSignature), nil
+}
+
+// scramAttribute returns the value of the attribute named name in the comma-separated message, or "" if absent
+func scramAttribute(message string, name byte) string {
+	for _, attr := range strings.Split(message, ",") {
+		if len(attr) >= 2 && attr[0] == name && attr[1] == '=' {
+			return attr[2:]
+		}
+	}
+	return ""
+}
+
+// scramHMAC returns the HMAC-SHA-256 of message with key
+func scramHMAC(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
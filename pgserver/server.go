@@ -0,0 +1,145 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package pgserver
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+)
+
+// Server is a read-only PostgreSQL wire protocol server over the DuckDB store
+type Server struct {
+	config     Config
+	duckdbConn *sql.DB
+	guard      *middleware.SQLGuard
+	logger     *zap.Logger
+	listener   net.Listener
+
+	mu       sync.Mutex
+	sessions map[uint32]*session // by process ID, for cancel requests
+	nextPID  uint32
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a PostgreSQL wire protocol server querying duckdbConn.
+// Returns nil and an error, if any.
+func NewServer(config Config, duckdbConn *sql.DB, logger *zap.Logger) (*Server, error) {
+	if config.Password == "" {
+		return nil, fmt.Errorf("a password is required")
+	}
+	if config.StatementTimeout <= 0 {
+		return nil, fmt.Errorf("statement timeout must be greater than 0")
+	}
+	tables := config.Tables
+	if len(tables) == 0 {
+		tables = middleware.DefaultQueryTables
+	}
+	if err := createCatalogViews(duckdbConn, tables); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", config.HostPort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	return &Server{
+		config:     config,
+		duckdbConn: duckdbConn,
+		guard:      middleware.NewSQLGuard(duckdbConn, tables).WithCatalog(catalogDatabase),
+		logger:     logger,
+		listener:   listener,
+		sessions:   make(map[uint32]*session),
+	}, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Serve accepts connections until Shutdown.  Returns an error, if any.
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		sess, err := s.newSession(conn)
+		if err != nil {
+			conn.Close()
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.logger.Error("pgwire session failed", zap.Error(err))
+			continue
+		}
+		go func() {
+			defer s.wg.Done()
+			defer s.removeSession(sess)
+			sess.run()
+		}()
+	}
+}
+
+// Shutdown stops accepting connections, closes the open ones, and waits for their sessions to end
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	s.closed = true
+	s.listener.Close()
+	for _, sess := range s.sessions {
+		sess.cancelQuery()
+		sess.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// newSession creates and registers a session for conn.  Returns net.ErrClosed after Shutdown.
+func (s *Server) newSession(conn net.Conn) (*session, error) {
+	var secret [4]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return nil, fmt.Errorf("failed to create secret key: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, net.ErrClosed
+	}
+	s.nextPID++
+	sess := newSession(s, conn, s.nextPID, binary.BigEndian.Uint32(secret[:]))
+	s.sessions[sess.pid] = sess
+	s.wg.Add(1)
+	return sess, nil
+}
+
+// removeSession unregisters a finished session
+func (s *Server) removeSession(sess *session) {
+	s.mu.Lock()
+	delete(s.sessions, sess.pid)
+	s.mu.Unlock()
+}
+
+// cancelQuery cancels the running query of the session with the process ID, if the secret key matches
+func (s *Server) cancelQuery(pid uint32, secretKey uint32) {
+	s.mu.Lock()
+	sess, ok := s.sessions[pid]
+	s.mu.Unlock()
+	if ok && sess.secretKey == secretKey {
+		sess.cancelQuery()
+	}
+}
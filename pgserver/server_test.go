@@ -0,0 +1,145 @@
+// Copyright (c) 2025 Neomantra Corp
+// server_test.go
+//
+// Tests of the PostgreSQL wire protocol server with the pgx client, on an in-memory DuckDB.
+
+package pgserver_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/marcboeker/go-duckdb/v2"
+	"go.uber.org/zap"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/pgserver"
+)
+
+const (
+	testUser     = "goose"
+	testPassword = "s3cret pässword"
+)
+
+// startTestServer starts a server over an in-memory DuckDB with a trades table and a disallowed
+// api_keys table, returning its address
+func startTestServer(t *testing.T) string {
+	t.Helper()
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("failed to open database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("CREATE TABLE trades AS SELECT range AS price FROM range(3);"); err != nil {
+		t.Fatalf("failed to create trades: %s", err)
+	}
+	if _, err := db.Exec("CREATE TABLE api_keys (token VARCHAR);"); err != nil {
+		t.Fatalf("failed to create api_keys: %s", err)
+	}
+
+	server, err := pgserver.NewServer(pgserver.Config{
+		HostPort:         "127.0.0.1:0",
+		Username:         testUser,
+		Password:         testPassword,
+		StatementTimeout: 10 * time.Second,
+	}, db, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create server: %s", err)
+	}
+	go server.Serve()
+	t.Cleanup(server.Shutdown)
+	return server.Addr()
+}
+
+// connect opens a pgx connection to addr as user with password
+func connect(addr string, user string, password string) (*pgconn.PgConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return pgconn.Connect(ctx, fmt.Sprintf("postgres://%s@%s/goose?sslmode=disable&password=%s", user, addr, password))
+}
+
+func TestSCRAMAuthentication(t *testing.T) {
+	addr := startTestServer(t)
+
+	conn, err := connect(addr, testUser, "s3cret%20p%C3%A4ssword")
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer conn.Close(context.Background())
+	results, err := conn.Exec(context.Background(), "SELECT count(*) FROM trades").ReadAll()
+	if err != nil {
+		t.Fatalf("query failed: %s", err)
+	}
+	if len(results) != 1 || len(results[0].Rows) != 1 || string(results[0].Rows[0][0]) != "3" {
+		t.Fatalf("unexpected result %v", results)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		user     string
+		password string
+	}{
+		{"wrong password", testUser, "wrong"},
+		{"wrong user", "other", "s3cret%20p%C3%A4ssword"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := connect(addr, tt.user, tt.password)
+			if err == nil {
+				conn.Close(context.Background())
+				t.Fatalf("expected authentication to fail")
+			}
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+				t.Fatalf("expected invalid password error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCatalogIntrospection(t *testing.T) {
+	conn, err := connect(startTestServer(t), testUser, "s3cret%20p%C3%A4ssword")
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer conn.Close(context.Background())
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{"SELECT table_name FROM information_schema.tables ORDER BY 1", "trades"},
+		{"SELECT column_name FROM information_schema.columns ORDER BY 1", "price"},
+		{"SELECT c.relname FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = 'main'", "trades"},
+		{"SELECT relname FROM pg_class", "trades"},
+		{"SELECT a.attname FROM pg_catalog.pg_attribute a JOIN pg_catalog.pg_class c ON c.oid = a.attrelid", "price"},
+		{"SELECT tablename FROM pg_catalog.pg_tables", "trades"},
+		{"WITH pg_class AS (SELECT 'cte' AS relname) SELECT relname FROM pg_class", "cte"},
+	} {
+		results, err := conn.Exec(context.Background(), tt.query).ReadAll()
+		if err != nil {
+			t.Fatalf("%q failed: %s", tt.query, err)
+		}
+		var got []string
+		for _, row := range results[0].Rows {
+			got = append(got, string(row[0]))
+		}
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%q returned %v, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{
+		"SELECT * FROM pg_catalog.pg_settings",
+		"SELECT * FROM system.information_schema.tables",
+		"SELECT * FROM api_keys",
+	} {
+		_, err := conn.Exec(context.Background(), query).ReadAll()
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != "42501" {
+			t.Errorf("expected %q to be rejected, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,795 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package pgserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/marcboeker/go-duckdb/v2"
+	"go.uber.org/zap"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+)
+
+// flushRows is the number of rows sent between flushes of a result
+const flushRows = 1000
+
+// SQLSTATE error codes we send
+const (
+	codeSyntaxError           = "42601"
+	codeUndefinedTable        = "42P01"
+	codeAccessRuleViolation   = "42000"
+	codeInsufficientPrivilege = "42501"
+	codeUndefinedObject       = "42704"
+	codeInvalidStatementName  = "26000"
+	codeInvalidCursorName     = "34000"
+	codeDataException         = "22000"
+	codeQueryCanceled         = "57014"
+	codeInvalidPassword       = "28P01"
+	codeProtocolViolation     = "08P01"
+	codeInternalError         = "XX000"
+)
+
+// pgError is an error sent to the client with its SQLSTATE code
+type pgError struct {
+	code    string
+	message string
+}
+
+func (e *pgError) Error() string { return e.message }
+
+// newError returns a pgError with the SQLSTATE code and message
+func newError(code string, message string) *pgError {
+	return &pgError{code: code, message: message}
+}
+
+// serverParameters are reported to clients on startup and returned by SHOW
+var serverParameters = map[string]string{
+	"server_version":                "16.0",
+	"server_encoding":               "UTF8",
+	"client_encoding":               "UTF8",
+	"datestyle":                     "ISO, MDY",
+	"intervalstyle":                 "postgres",
+	"timezone":                      "UTC",
+	"integer_datetimes":             "on",
+	"standard_conforming_strings":   "on",
+	"is_superuser":                  "off",
+	"application_name":              "",
+	"transaction_isolation":         "serializable",
+	"transaction_read_only":         "on",
+	"default_transaction_read_only": "on",
+}
+
+// startupParameters are the serverParameters reported on startup, with their canonical names
+var startupParameters = []string{
+	"server_version", "server_encoding", "client_encoding", "DateStyle", "IntervalStyle",
+	"TimeZone", "integer_datetimes", "standard_conforming_strings", "is_superuser", "application_name",
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// preparedStatement is a statement of the extended query protocol
+type preparedStatement struct {
+	query     string
+	command   *sessionCommand // non-nil for statements handled by the session
+	paramOIDs []uint32
+}
+
+// portal is a bound statement of the extended query protocol, or a simple query.
+// It is opened when described or executed, and its rows are sent across Executes.
+type portal struct {
+	statement *preparedStatement
+	args      []any
+	formats   []int16 // result format codes
+
+	opened bool
+	conn   *sql.Conn // pooled connection in the read-only transaction of rows
+	rows   *sql.Rows
+	cancel context.CancelFunc
+	names  []string
+	oids   []uint32
+	count  int // rows sent
+}
+
+// hasRows returns true if the opened portal returns rows
+func (p *portal) hasRows() bool {
+	return p.rows != nil || (p.statement.command != nil && p.statement.command.show)
+}
+
+// close releases the portal's rows and connection, if any
+func (p *portal) close() {
+	if p.rows != nil {
+		p.rows.Close()
+		p.rows = nil
+	}
+	if p.conn != nil {
+		middleware.EndReadOnly(p.conn)
+		p.conn = nil
+	}
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+// session is a client connection
+type session struct {
+	server    *Server
+	conn      net.Conn
+	backend   *pgproto3.Backend
+	typeMap   *pgtype.Map
+	pid       uint32
+	secretKey uint32
+	user      string
+
+	params        map[string]string // session parameters, for SHOW
+	timeout       time.Duration     // statement timeout
+	inTransaction bool
+	statements    map[string]*preparedStatement
+	portals       map[string]*portal
+
+	mu              sync.Mutex
+	cancel          context.CancelFunc // cancels the running query
+	cancelRequested bool
+}
+
+func newSession(server *Server, conn net.Conn, pid uint32, secretKey uint32) *session {
+	params := make(map[string]string, len(serverParameters)+1)
+	for name, value := range serverParameters {
+		params[name] = value
+	}
+	params["statement_timeout"] = formatStatementTimeout(server.config.StatementTimeout)
+	return &session{
+		server:     server,
+		conn:       conn,
+		backend:    pgproto3.NewBackend(conn, conn),
+		typeMap:    pgtype.NewMap(),
+		pid:        pid,
+		secretKey:  secretKey,
+		params:     params,
+		timeout:    server.config.StatementTimeout,
+		statements: make(map[string]*preparedStatement),
+		portals:    make(map[string]*portal),
+	}
+}
+
+// run serves the session until the client disconnects
+func (sess *session) run() {
+	defer sess.conn.Close()
+	defer sess.closePortals()
+
+	if err := sess.startup(); err != nil {
+		sess.server.logger.Debug("pgwire startup ended", zap.Error(err))
+		return
+	}
+	sess.server.logger.Info("pgwire session started", zap.String("user", sess.user),
+		zap.Uint32("pid", sess.pid), zap.String("remote", sess.conn.RemoteAddr().String()))
+
+	// after an error in the extended protocol, messages are discarded until Sync
+	ignoreTillSync := false
+	for {
+		msg, err := sess.backend.Receive()
+		if err != nil {
+			return
+		}
+		if _, isSync := msg.(*pgproto3.Sync); ignoreTillSync && !isSync {
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			sess.handleQuery(msg.String)
+			err = sess.readyForQuery()
+		case *pgproto3.Parse:
+			err = sess.handleParse(msg)
+		case *pgproto3.Bind:
+			err = sess.handleBind(msg)
+		case *pgproto3.Describe:
+			err = sess.handleDescribe(msg)
+		case *pgproto3.Execute:
+			err = sess.handleExecute(msg)
+		case *pgproto3.Close:
+			sess.handleClose(msg)
+		case *pgproto3.Sync:
+			ignoreTillSync = false
+			if !sess.inTransaction {
+				sess.closePortals()
+			}
+			err = sess.readyForQuery()
+		case *pgproto3.Flush:
+			err = sess.backend.Flush()
+		case *pgproto3.Terminate:
+			return
+		default:
+			err = newError(codeProtocolViolation, fmt.Sprintf("unsupported message %T", msg))
+		}
+
+		if err != nil {
+			var pgErr *pgError
+			if !errors.As(err, &pgErr) {
+				return // the connection failed
+			}
+			sess.sendError(pgErr)
+			ignoreTillSync = true
+		}
+	}
+}
+
+// startup negotiates the connection and authenticates the user.  Returns an error, if any.
+func (sess *session) startup() error {
+	for {
+		msg, err := sess.backend.ReceiveStartupMessage()
+		if err != nil {
+			return err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			// encryption is not supported, the client may continue without it
+			if _, err := sess.conn.Write([]byte{'N'}); err != nil {
+				return err
+			}
+		case *pgproto3.CancelRequest:
+			sess.server.cancelQuery(msg.ProcessID, msg.SecretKey)
+			return fmt.Errorf("cancel request")
+		case *pgproto3.StartupMessage:
+			sess.user = msg.Parameters["user"]
+			if applicationName, ok := msg.Parameters["application_name"]; ok {
+				sess.params["application_name"] = applicationName
+			}
+			return sess.authenticate()
+		default:
+			return fmt.Errorf("unexpected startup message %T", msg)
+		}
+	}
+}
+
+// authenticate authenticates the user with SCRAM-SHA-256 and completes startup.  Returns an error, if any.
+func (sess *session) authenticate() error {
+	serverFinal, err := sess.exchangeSCRAM()
+	if err != nil && !errors.Is(err, errSCRAMFailed) {
+		return err
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(sess.user), []byte(sess.server.config.Username)) == 1
+	if err != nil || !userOK {
+		sess.backend.Send(&pgproto3.ErrorResponse{
+			Severity: "FATAL",
+			Code:     codeInvalidPassword,
+			Message:  fmt.Sprintf("password authentication failed for user %q", sess.user),
+		})
+		sess.backend.Flush()
+		return fmt.Errorf("authentication failed for user %q", sess.user)
+	}
+
+	sess.backend.Send(&pgproto3.AuthenticationSASLFinal{Data: []byte(serverFinal)})
+	sess.backend.Send(&pgproto3.AuthenticationOk{})
+	for _, name := range startupParameters {
+		sess.backend.Send(&pgproto3.ParameterStatus{Name: name, Value: sess.params[strings.ToLower(name)]})
+	}
+	sess.backend.Send(&pgproto3.BackendKeyData{ProcessID: sess.pid, SecretKey: sess.secretKey})
+	return sess.readyForQuery()
+}
+
+// exchangeSCRAM runs the SASL exchange verifying the client knows the password, up to
+// the server-final-message, which it returns for sending once the user is verified too.
+// Returns an error wrapping errSCRAMFailed if it does not, or another error if the connection failed.
+func (sess *session) exchangeSCRAM() (string, error) {
+	scram, err := newSCRAMServer(sess.server.config.Password)
+	if err != nil {
+		return "", err
+	}
+	sess.backend.Send(&pgproto3.AuthenticationSASL{AuthMechanisms: []string{scramMechanism}})
+	if err := sess.backend.Flush(); err != nil {
+		return "", err
+	}
+
+	if err := sess.backend.SetAuthType(pgproto3.AuthTypeSASL); err != nil {
+		return "", err
+	}
+	msg, err := sess.backend.Receive()
+	if err != nil {
+		return "", err
+	}
+	initial, ok := msg.(*pgproto3.SASLInitialResponse)
+	if !ok || initial.AuthMechanism != scramMechanism {
+		return "", fmt.Errorf("%w: expected %s initial response", errSCRAMFailed, scramMechanism)
+	}
+	serverFirst, err := scram.firstMessage(string(initial.Data))
+	if err != nil {
+		return "", err
+	}
+	sess.backend.Send(&pgproto3.AuthenticationSASLContinue{Data: []byte(serverFirst)})
+	if err := sess.backend.Flush(); err != nil {
+		return "", err
+	}
+
+	if err := sess.backend.SetAuthType(pgproto3.AuthTypeSASLContinue); err != nil {
+		return "", err
+	}
+	if msg, err = sess.backend.Receive(); err != nil {
+		return "", err
+	}
+	response, ok := msg.(*pgproto3.SASLResponse)
+	if !ok {
+		return "", fmt.Errorf("%w: expected SASL response", errSCRAMFailed)
+	}
+	return scram.finalMessage(string(response.Data))
+}
+
+// readyForQuery tells the client the session is ready for the next query
+func (sess *session) readyForQuery() error {
+	txStatus := byte('I')
+	if sess.inTransaction {
+		txStatus = 'T'
+	}
+	sess.backend.Send(&pgproto3.ReadyForQuery{TxStatus: txStatus})
+	return sess.backend.Flush()
+}
+
+// sendError sends an ErrorResponse for the error
+func (sess *session) sendError(err *pgError) {
+	sess.backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: err.code, Message: err.message})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Simple query protocol
+
+// handleQuery executes a simple query, sending its results or error
+func (sess *session) handleQuery(query string) {
+	if strings.TrimSpace(strings.Trim(strings.TrimSpace(query), ";")) == "" {
+		sess.backend.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+	statement, err := sess.prepare(query)
+	if err != nil {
+		sess.sendError(err)
+		return
+	}
+	p := &portal{statement: statement}
+	defer p.close()
+	if err := sess.openPortal(p); err != nil {
+		sess.sendError(err)
+		return
+	}
+	if p.hasRows() {
+		sess.backend.Send(rowDescription(p.names, p.oids, p.formats))
+	}
+	var pgErr *pgError
+	if err := sess.executePortal(p, 0); errors.As(err, &pgErr) {
+		sess.sendError(pgErr)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Extended query protocol
+
+// handleParse prepares a statement
+func (sess *session) handleParse(msg *pgproto3.Parse) error {
+	statement, err := sess.prepare(msg.Query)
+	if err != nil {
+		return err
+	}
+	// the client's parameter types take precedence over DuckDB's
+	for i, oid := range msg.ParameterOIDs {
+		if i < len(statement.paramOIDs) && oid != 0 {
+			statement.paramOIDs[i] = oid
+		}
+	}
+	sess.statements[msg.Name] = statement
+	sess.backend.Send(&pgproto3.ParseComplete{})
+	return nil
+}
+
+// handleBind binds parameters to a prepared statement, creating a portal
+func (sess *session) handleBind(msg *pgproto3.Bind) error {
+	statement, ok := sess.statements[msg.PreparedStatement]
+	if !ok {
+		return newError(codeInvalidStatementName, fmt.Sprintf("prepared statement %q does not exist", msg.PreparedStatement))
+	}
+	if len(msg.Parameters) != len(statement.paramOIDs) {
+		return newError(codeProtocolViolation, fmt.Sprintf("bind message supplies %d parameters, but prepared statement requires %d",
+			len(msg.Parameters), len(statement.paramOIDs)))
+	}
+	args, err := decodeParams(sess.typeMap, statement.paramOIDs, msg.ParameterFormatCodes, msg.Parameters)
+	if err != nil {
+		return newError(codeDataException, err.Error())
+	}
+	if old, ok := sess.portals[msg.DestinationPortal]; ok {
+		old.close()
+	}
+	sess.portals[msg.DestinationPortal] = &portal{statement: statement, args: args, formats: msg.ResultFormatCodes}
+	sess.backend.Send(&pgproto3.BindComplete{})
+	return nil
+}
+
+// handleDescribe describes a prepared statement's parameters and rows, or a portal's rows
+func (sess *session) handleDescribe(msg *pgproto3.Describe) error {
+	if msg.ObjectType == 'P' {
+		p, ok := sess.portals[msg.Name]
+		if !ok {
+			return newError(codeInvalidCursorName, fmt.Sprintf("portal %q does not exist", msg.Name))
+		}
+		if err := sess.openPortal(p); err != nil {
+			return err
+		}
+		if p.hasRows() {
+			sess.backend.Send(rowDescription(p.names, p.oids, p.formats))
+		} else {
+			sess.backend.Send(&pgproto3.NoData{})
+		}
+		return nil
+	}
+
+	statement, ok := sess.statements[msg.Name]
+	if !ok {
+		return newError(codeInvalidStatementName, fmt.Sprintf("prepared statement %q does not exist", msg.Name))
+	}
+	sess.backend.Send(&pgproto3.ParameterDescription{ParameterOIDs: statement.paramOIDs})
+	switch {
+	case statement.command == nil:
+		names, oids, err := sess.describeColumns(statement)
+		if err != nil {
+			return err
+		}
+		sess.backend.Send(rowDescription(names, oids, nil))
+	case statement.command.show:
+		sess.backend.Send(rowDescription([]string{statement.command.name}, []uint32{pgtype.TextOID}, nil))
+	default:
+		sess.backend.Send(&pgproto3.NoData{})
+	}
+	return nil
+}
+
+// handleExecute sends up to MaxRows rows of a portal, all if zero
+func (sess *session) handleExecute(msg *pgproto3.Execute) error {
+	p, ok := sess.portals[msg.Portal]
+	if !ok {
+		return newError(codeInvalidCursorName, fmt.Sprintf("portal %q does not exist", msg.Portal))
+	}
+	if err := sess.openPortal(p); err != nil {
+		return err
+	}
+	return sess.executePortal(p, int(msg.MaxRows))
+}
+
+// handleClose closes a prepared statement or portal
+func (sess *session) handleClose(msg *pgproto3.Close) {
+	if msg.ObjectType == 'P' {
+		if p, ok := sess.portals[msg.Name]; ok {
+			p.close()
+			delete(sess.portals, msg.Name)
+		}
+	} else {
+		delete(sess.statements, msg.Name)
+	}
+	sess.backend.Send(&pgproto3.CloseComplete{})
+}
+
+// closePortals closes all portals
+func (sess *session) closePortals() {
+	for name, p := range sess.portals {
+		p.close()
+		delete(sess.portals, name)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Statement execution
+
+// prepare checks a query and returns its statement, with any catalog views redirected to
+// the filtered ones.  DuckDB reports the types of its parameters.
+func (sess *session) prepare(query string) (*preparedStatement, *pgError) {
+	command, err := parseSessionCommand(query)
+	if err != nil {
+		return nil, err.(*pgError)
+	}
+	if command != nil {
+		return &preparedStatement{query: query, command: command}, nil
+	}
+
+	ctx, finish := sess.queryContext()
+	defer finish()
+	query, err = rewriteCatalogQuery(ctx, sess.server.duckdbConn, query)
+	if err != nil {
+		return nil, sess.queryError(ctx, err)
+	}
+	if err := sess.server.guard.Check(ctx, query); err != nil {
+		if errors.Is(err, middleware.ErrQueryNotAllowed) {
+			return nil, newError(codeInsufficientPrivilege, err.Error())
+		}
+		return nil, sess.queryError(ctx, err)
+	}
+	paramOIDs, err := sess.paramOIDs(ctx, query)
+	if err != nil {
+		return nil, sess.queryError(ctx, err)
+	}
+	return &preparedStatement{query: query, paramOIDs: paramOIDs}, nil
+}
+
+// paramOIDs prepares the query in DuckDB and returns the Postgres types of its parameters
+func (sess *session) paramOIDs(ctx context.Context, query string) ([]uint32, error) {
+	conn, err := sess.server.duckdbConn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var oids []uint32
+	err = conn.Raw(func(driverConn any) error {
+		stmt, err := driverConn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		duckdbStmt, ok := stmt.(*duckdb.Stmt)
+		if !ok {
+			return fmt.Errorf("unexpected statement type %T", stmt)
+		}
+		oids = make([]uint32, duckdbStmt.NumInput())
+		for i := range oids {
+			paramType, err := duckdbStmt.ParamType(i + 1)
+			if err != nil {
+				return err
+			}
+			oids[i] = paramTypeOID(paramType)
+		}
+		return nil
+	})
+	return oids, err
+}
+
+// describeColumns returns the names and Postgres types of a statement's columns, without executing it
+func (sess *session) describeColumns(statement *preparedStatement) ([]string, []uint32, *pgError) {
+	ctx, finish := sess.queryContext()
+	defer finish()
+
+	conn, err := middleware.BeginReadOnly(ctx, sess.server.duckdbConn)
+	if err != nil {
+		return nil, nil, sess.queryError(ctx, err)
+	}
+	defer middleware.EndReadOnly(conn)
+	query := strings.TrimRight(strings.TrimSpace(statement.query), ";")
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT * FROM (%s\n) LIMIT 0;", query),
+		make([]any, len(statement.paramOIDs))...)
+	if err != nil {
+		return nil, nil, sess.queryError(ctx, err)
+	}
+	defer rows.Close()
+	return rowColumns(ctx, sess, rows)
+}
+
+// openPortal executes the portal's statement, if not already.  Queries run in a read-only
+// transaction on a connection of the pool, held until the portal is closed.
+func (sess *session) openPortal(p *portal) *pgError {
+	if p.opened {
+		return nil
+	}
+	p.opened = true
+	if command := p.statement.command; command != nil {
+		if command.show {
+			p.names, p.oids = []string{command.name}, []uint32{pgtype.TextOID}
+		}
+		return nil
+	}
+
+	sess.server.logger.Info("pgwire query", zap.String("user", sess.user), zap.Uint32("pid", sess.pid),
+		zap.String("query", p.statement.query))
+	ctx, finish := sess.queryContext()
+	conn, err := middleware.BeginReadOnly(ctx, sess.server.duckdbConn)
+	if err != nil {
+		defer finish()
+		return sess.queryError(ctx, err)
+	}
+	rows, err := conn.QueryContext(ctx, p.statement.query, p.args...)
+	if err != nil {
+		defer finish()
+		defer middleware.EndReadOnly(conn)
+		return sess.queryError(ctx, err)
+	}
+	names, oids, pgErr := rowColumns(ctx, sess, rows)
+	if pgErr != nil {
+		rows.Close()
+		middleware.EndReadOnly(conn)
+		finish()
+		return pgErr
+	}
+	p.conn, p.rows, p.cancel, p.names, p.oids = conn, rows, finish, names, oids
+	return nil
+}
+
+// rowColumns returns the names and Postgres types of the rows' columns
+func rowColumns(ctx context.Context, sess *session, rows *sql.Rows) ([]string, []uint32, *pgError) {
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, nil, sess.queryError(ctx, err)
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, sess.queryError(ctx, err)
+	}
+	return names, columnOIDs(columnTypes), nil
+}
+
+// executePortal sends up to maxRows rows of the opened portal, all if zero,
+// then PortalSuspended if rows may remain or CommandComplete if not
+func (sess *session) executePortal(p *portal, maxRows int) error {
+	if command := p.statement.command; command != nil {
+		return sess.executeCommand(command)
+	}
+	if p.rows == nil {
+		return newError(codeInvalidCursorName, "portal is closed")
+	}
+
+	values := make([]any, len(p.names))
+	valuePtrs := make([]any, len(values))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	sent := 0
+	for p.rows.Next() {
+		if err := p.rows.Scan(valuePtrs...); err != nil {
+			return newError(codeDataException, err.Error())
+		}
+		dataRow := &pgproto3.DataRow{Values: make([][]byte, len(values))}
+		for i, value := range values {
+			encoded, err := encodeValue(sess.typeMap, p.oids[i], resultFormat(p.formats, i), value)
+			if err != nil {
+				return newError(codeDataException, err.Error())
+			}
+			dataRow.Values[i] = encoded
+		}
+		sess.backend.Send(dataRow)
+		p.count++
+		sent++
+		if sent%flushRows == 0 {
+			if err := sess.backend.Flush(); err != nil {
+				return err
+			}
+		}
+		if maxRows > 0 && sent == maxRows {
+			sess.backend.Send(&pgproto3.PortalSuspended{})
+			return nil
+		}
+	}
+	if err := p.rows.Err(); err != nil {
+		return sess.queryError(context.Background(), err)
+	}
+	p.close()
+	sess.backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(fmt.Sprintf("SELECT %d", p.count))})
+	return nil
+}
+
+// executeCommand executes a session command, sending its row if any and CommandComplete
+func (sess *session) executeCommand(command *sessionCommand) error {
+	switch command.tag {
+	case "BEGIN":
+		sess.inTransaction = true
+	case "COMMIT", "ROLLBACK":
+		sess.inTransaction = false
+	case "DISCARD ALL":
+		sess.resetParameters()
+		sess.statements = make(map[string]*preparedStatement)
+	case "SET":
+		if command.name != "" {
+			if err := sess.setParameter(command.name, command.value); err != nil {
+				return err
+			}
+		}
+	case "RESET":
+		if command.name == "all" {
+			sess.resetParameters()
+		} else {
+			sess.resetParameter(command.name)
+		}
+	case "SHOW":
+		value, ok := sess.params[command.name]
+		if !ok {
+			return newError(codeUndefinedObject, fmt.Sprintf("unrecognized configuration parameter %q", command.name))
+		}
+		sess.backend.Send(&pgproto3.DataRow{Values: [][]byte{[]byte(value)}})
+	}
+	sess.backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(command.tag)})
+	return nil
+}
+
+// setParameter sets a session parameter.  The statement timeout may not exceed the server's.
+func (sess *session) setParameter(name string, value string) *pgError {
+	if name == "statement_timeout" {
+		timeout, err := parseStatementTimeout(value)
+		if err != nil {
+			return newError(codeDataException, err.Error())
+		}
+		if timeout == 0 || timeout > sess.server.config.StatementTimeout {
+			timeout = sess.server.config.StatementTimeout
+		}
+		sess.timeout = timeout
+		value = formatStatementTimeout(timeout)
+	}
+	sess.params[name] = value
+	return nil
+}
+
+// resetParameter restores a session parameter to its default
+func (sess *session) resetParameter(name string) {
+	if name == "statement_timeout" {
+		sess.timeout = sess.server.config.StatementTimeout
+		sess.params[name] = formatStatementTimeout(sess.timeout)
+	} else if value, ok := serverParameters[name]; ok {
+		sess.params[name] = value
+	} else {
+		delete(sess.params, name)
+	}
+}
+
+// resetParameters restores all session parameters to their defaults
+func (sess *session) resetParameters() {
+	for name := range sess.params {
+		sess.resetParameter(name)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Cancellation
+
+// queryContext returns a context for a query within the statement timeout, cancelled by
+// cancel requests, and the function to call when the query is finished
+func (sess *session) queryContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), sess.timeout)
+	sess.mu.Lock()
+	sess.cancel = cancel
+	sess.cancelRequested = false
+	sess.mu.Unlock()
+	return ctx, cancel
+}
+
+// cancelQuery cancels the running query, if any
+func (sess *session) cancelQuery() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.cancel != nil {
+		sess.cancelRequested = true
+		sess.cancel()
+	}
+}
+
+// queryError converts an error of a query run with ctx to a pgError
+func (sess *session) queryError(ctx context.Context, err error) *pgError {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return newError(codeQueryCanceled, "canceling statement due to statement timeout")
+	case errors.Is(ctx.Err(), context.Canceled):
+		sess.mu.Lock()
+		requested := sess.cancelRequested
+		sess.mu.Unlock()
+		if requested {
+			return newError(codeQueryCanceled, "canceling statement due to user request")
+		}
+	}
+
+	var duckdbErr *duckdb.Error
+	if errors.As(err, &duckdbErr) {
+		switch duckdbErr.Type {
+		case duckdb.ErrorTypeParser:
+			return newError(codeSyntaxError, err.Error())
+		case duckdb.ErrorTypeCatalog:
+			return newError(codeUndefinedTable, err.Error())
+		case duckdb.ErrorTypeBinder:
+			return newError(codeAccessRuleViolation, err.Error())
+		case duckdb.ErrorTypeConversion, duckdb.ErrorTypeOutOfRange, duckdb.ErrorTypeInvalidInput:
+			return newError(codeDataException, err.Error())
+		case duckdb.ErrorTypeInterrupt:
+			return newError(codeQueryCanceled, err.Error())
+		}
+	}
+	return newError(codeInternalError, err.Error())
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package pgserver
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/marcboeker/go-duckdb/v2"
+)
+
+// typeOIDs are the Postgres types of DuckDB types, by DuckDB type name.
+// Other types, such as LIST and STRUCT, are sent as JSON text.
+var typeOIDs = map[string]uint32{
+	"BOOLEAN":      pgtype.BoolOID,
+	"TINYINT":      pgtype.Int2OID,
+	"SMALLINT":     pgtype.Int2OID,
+	"UTINYINT":     pgtype.Int2OID,
+	"INTEGER":      pgtype.Int4OID,
+	"USMALLINT":    pgtype.Int4OID,
+	"BIGINT":       pgtype.Int8OID,
+	"UINTEGER":     pgtype.Int8OID,
+	"UBIGINT":      pgtype.NumericOID,
+	"HUGEINT":      pgtype.NumericOID,
+	"UHUGEINT":     pgtype.NumericOID,
+	"VARINT":       pgtype.NumericOID,
+	"DECIMAL":      pgtype.NumericOID,
+	"FLOAT":        pgtype.Float4OID,
+	"DOUBLE":       pgtype.Float8OID,
+	"VARCHAR":      pgtype.TextOID,
+	"ENUM":         pgtype.TextOID,
+	"BLOB":         pgtype.ByteaOID,
+	"DATE":         pgtype.DateOID,
+	"TIME":         pgtype.TimeOID,
+	"TIMESTAMP":    pgtype.TimestampOID,
+	"TIMESTAMP_S":  pgtype.TimestampOID,
+	"TIMESTAMP_MS": pgtype.TimestampOID,
+	"TIMESTAMP_NS": pgtype.TimestampOID,
+	"TIMESTAMPTZ":  pgtype.TimestamptzOID,
+	"INTERVAL":     pgtype.IntervalOID,
+	"UUID":         pgtype.UUIDOID,
+}
+
+// paramTypeNames are the DuckDB type names of prepared statement parameter types
+var paramTypeNames = map[duckdb.Type]string{
+	duckdb.TYPE_BOOLEAN:      "BOOLEAN",
+	duckdb.TYPE_TINYINT:      "TINYINT",
+	duckdb.TYPE_SMALLINT:     "SMALLINT",
+	duckdb.TYPE_UTINYINT:     "UTINYINT",
+	duckdb.TYPE_INTEGER:      "INTEGER",
+	duckdb.TYPE_USMALLINT:    "USMALLINT",
+	duckdb.TYPE_BIGINT:       "BIGINT",
+	duckdb.TYPE_UINTEGER:     "UINTEGER",
+	duckdb.TYPE_UBIGINT:      "UBIGINT",
+	duckdb.TYPE_HUGEINT:      "HUGEINT",
+	duckdb.TYPE_DECIMAL:      "DECIMAL",
+	duckdb.TYPE_FLOAT:        "FLOAT",
+	duckdb.TYPE_DOUBLE:       "DOUBLE",
+	duckdb.TYPE_VARCHAR:      "VARCHAR",
+	duckdb.TYPE_BLOB:         "BLOB",
+	duckdb.TYPE_DATE:         "DATE",
+	duckdb.TYPE_TIME:         "TIME",
+	duckdb.TYPE_TIMESTAMP:    "TIMESTAMP",
+	duckdb.TYPE_TIMESTAMP_TZ: "TIMESTAMPTZ",
+	duckdb.TYPE_INTERVAL:     "INTERVAL",
+	duckdb.TYPE_UUID:         "UUID",
+}
+
+// typeSizes are the sizes of fixed-size Postgres types, by OID; others are variable, -1
+var typeSizes = map[uint32]int16{
+	pgtype.BoolOID:        1,
+	pgtype.Int2OID:        2,
+	pgtype.Int4OID:        4,
+	pgtype.Int8OID:        8,
+	pgtype.Float4OID:      4,
+	pgtype.Float8OID:      8,
+	pgtype.DateOID:        4,
+	pgtype.TimeOID:        8,
+	pgtype.TimestampOID:   8,
+	pgtype.TimestamptzOID: 8,
+	pgtype.IntervalOID:    16,
+	pgtype.UUIDOID:        16,
+}
+
+// typeNameOID returns the Postgres type of a DuckDB type name, such as "DECIMAL(18,3)"
+func typeNameOID(typeName string) uint32 {
+	if i := strings.IndexByte(typeName, '('); i >= 0 {
+		typeName = typeName[:i]
+	}
+	if oid, ok := typeOIDs[typeName]; ok {
+		return oid
+	}
+	return pgtype.TextOID
+}
+
+// paramTypeOID returns the Postgres type of a prepared statement parameter's DuckDB type
+func paramTypeOID(paramType duckdb.Type) uint32 {
+	if typeName, ok := paramTypeNames[paramType]; ok {
+		return typeOIDs[typeName]
+	}
+	return pgtype.TextOID
+}
+
+// columnOIDs returns the Postgres types of the columns
+func columnOIDs(columnTypes []*sql.ColumnType) []uint32 {
+	oids := make([]uint32, len(columnTypes))
+	for i, columnType := range columnTypes {
+		oids[i] = typeNameOID(columnType.DatabaseTypeName())
+	}
+	return oids
+}
+
+// rowDescription describes columns with the given result format codes, as the Bind message gives them
+func rowDescription(names []string, oids []uint32, formats []int16) *pgproto3.RowDescription {
+	fields := make([]pgproto3.FieldDescription, len(names))
+	for i, name := range names {
+		size, ok := typeSizes[oids[i]]
+		if !ok {
+			size = -1
+		}
+		fields[i] = pgproto3.FieldDescription{
+			Name:         []byte(name),
+			DataTypeOID:  oids[i],
+			DataTypeSize: size,
+			TypeModifier: -1,
+			Format:       resultFormat(formats, i),
+		}
+	}
+	return &pgproto3.RowDescription{Fields: fields}
+}
+
+// resultFormat returns the format code of column i, per the Bind message's format codes:
+// none means all text, one applies to all columns, otherwise there is one per column
+func resultFormat(formats []int16, i int) int16 {
+	switch {
+	case len(formats) == 0:
+		return pgtype.TextFormatCode
+	case len(formats) == 1:
+		return formats[0]
+	case i < len(formats):
+		return formats[i]
+	default:
+		return pgtype.TextFormatCode
+	}
+}
+
+// encodeValue encodes a value scanned from DuckDB as the Postgres type oid in the given format.
+// Returns nil for NULL.
+func encodeValue(typeMap *pgtype.Map, oid uint32, format int16, value any) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case uint64:
+		value = pgtype.Numeric{Int: new(big.Int).SetUint64(v), Valid: true}
+	case *big.Int:
+		value = pgtype.Numeric{Int: v, Valid: true}
+	case duckdb.Decimal:
+		var numeric pgtype.Numeric
+		if err := numeric.Scan(v.String()); err != nil {
+			return nil, err
+		}
+		value = numeric
+	case duckdb.Interval:
+		value = pgtype.Interval{Months: v.Months, Days: v.Days, Microseconds: v.Micros, Valid: true}
+	case time.Time:
+		if oid == pgtype.TimeOID {
+			sinceMidnight := v.Sub(time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, v.Location()))
+			value = pgtype.Time{Microseconds: sinceMidnight.Microseconds(), Valid: true}
+		}
+	case []byte:
+		if oid == pgtype.UUIDOID && len(v) == 16 {
+			value = pgtype.UUID{Bytes: [16]byte(v), Valid: true}
+		}
+	}
+	if oid == pgtype.TextOID {
+		value = textValue(value)
+	}
+
+	buf, err := typeMap.Encode(oid, format, value, []byte{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %T as type %d: %w", value, oid, err)
+	}
+	return buf, nil
+}
+
+// textValue returns the text of a value sent as the Postgres text type, JSON for composite values
+func textValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+	if encoded, err := json.Marshal(value); err == nil {
+		return string(encoded)
+	}
+	return fmt.Sprint(value)
+}
+
+// decodeParams decodes a Bind message's parameters of the given Postgres types for DuckDB.
+// Text parameters are passed as strings, for DuckDB to cast.  Returns an error, if any.
+func decodeParams(typeMap *pgtype.Map, oids []uint32, formats []int16, params [][]byte) ([]any, error) {
+	args := make([]any, len(params))
+	for i, param := range params {
+		if param == nil {
+			continue
+		}
+		format := resultFormat(formats, i)
+		if format == pgtype.TextFormatCode {
+			args[i] = string(param)
+			continue
+		}
+		var oid uint32
+		if i < len(oids) {
+			oid = oids[i]
+		}
+		pgType, ok := typeMap.TypeForOID(oid)
+		if !ok {
+			return nil, fmt.Errorf("unsupported binary parameter $%d of type %d", i+1, oid)
+		}
+		value, err := pgType.Codec.DecodeValue(typeMap, oid, format, param)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode parameter $%d: %w", i+1, err)
+		}
+		args[i] = value
+	}
+	return args, nil
+}
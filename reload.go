@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/handlers"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+)
+
+// reloader re-reads the ServiceConfig, on SIGHUP or POST /admin/reload, and applies the
+// settings that can change while running: the live symbols, the log level, CORS,
+// the readiness staleness and the keys of a keys file.
+// Changes to other settings are logged, and take effect after a restart.
+type reloader struct {
+	mutex          sync.Mutex
+	config         ServiceConfig // config as applied
+	logLevel       zap.AtomicLevel
+	liveDataClient *livedata.LiveDataClient
+	keyStore       *middleware.FileKeyStore // nil unless API keys are in a file
+	logger         *zap.Logger
+}
+
+// Reload re-reads the config and applies its changes.
+// Returns descriptions of the changes applied, and an error if any.
+func (r *reloader) Reload() ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	config, _, _, err := loadServiceConfig(os.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	// symbols are applied only if the config's changed, keeping those set with PUT /api/v1/subscriptions
+	var changes []string
+	if !slices.Equal(config.LiveConfig.SubSymbols, r.config.LiveConfig.SubSymbols) {
+		added, removed, err := r.liveDataClient.SetSubscriptions(config.LiveConfig.SubSymbols)
+		if err != nil {
+			return nil, err
+		}
+		r.config.LiveConfig.SubSymbols = config.LiveConfig.SubSymbols
+		if len(added) != 0 {
+			changes = append(changes, "subscribed "+strings.Join(added, ","))
+		}
+		if len(removed) != 0 {
+			changes = append(changes, "unsubscribed "+strings.Join(removed, ","))
+		}
+	}
+
+	if config.Log.Level != r.config.Log.Level {
+		r.logLevel.SetLevel(config.Log.Level)
+		r.config.Log.Level = config.Log.Level
+		changes = append(changes, "log level "+config.Log.Level.String())
+	}
+
+	if config.Cors != r.config.Cors {
+		middleware.SetCorsConfig(config.Cors)
+		r.config.Cors = config.Cors
+		changes = append(changes, "CORS allowed origins "+config.Cors.AllowedOrigins)
+	}
+
+	if config.Health != r.config.Health {
+		handlers.SetHealthConfig(config.Health)
+		r.config.Health = config.Health
+		changes = append(changes, "readiness staleness "+config.Health.Staleness.String())
+	}
+
+	if r.keyStore != nil {
+		numKeys, err := r.keyStore.Load()
+		if err != nil {
+			return changes, fmt.Errorf("failed to reload API keys, keeping the previous keys: %w", err)
+		}
+		changes = append(changes, fmt.Sprintf("loaded %d API keys", numKeys))
+	}
+
+	if pending := changedSettings("", reflect.ValueOf(r.config), reflect.ValueOf(config)); len(pending) != 0 {
+		r.logger.Warn("config changes need a restart", zap.Strings("settings", pending))
+	}
+	r.logger.Info("config reloaded", zap.Strings("changes", changes))
+	return changes, nil
+}
+
+// changedSettings returns the names of the settings that differ between two configs,
+// such as "live.dataset".  Setting names are their YAML keys, after the prefix.
+func changedSettings(prefix string, a reflect.Value, b reflect.Value) []string {
+	var changed []string
+	for i := range a.NumField() {
+		field := a.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeFor[time.Time]() {
+			changed = append(changed, changedSettings(prefix+name+".", a.Field(i), b.Field(i))...)
+		} else if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, prefix+name)
+		}
+	}
+	return changed
+}
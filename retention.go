@@ -0,0 +1,36 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/livedata"
+)
+
+// runRetention deletes the client's trades and candles older than the config's MaxAge,
+// at startup and then every Interval, until ctx is done
+func runRetention(ctx context.Context, client *livedata.LiveDataClient, config livedata.RetentionConfig, logger *zap.Logger) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().Add(-config.MaxAge)
+		start := time.Now()
+		deleted, err := client.Prune(ctx, cutoff)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("failed to delete old data", zap.Time("cutoff", cutoff), zap.Error(err))
+		} else if deleted != 0 {
+			logger.Info("deleted old data", zap.Time("cutoff", cutoff), zap.Int64("rows", deleted),
+				zap.Duration("elapsed", time.Since(start)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2025 Neomantra Corp
+// auth.go
+//
+// Pluggable authentication of Client requests.
+
+package sdk
+
+import "net/http"
+
+// Authenticator adds credentials to each request of a Client
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator
+type AuthenticatorFunc func(req *http.Request) error
+
+// Authenticate calls f(req)
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerToken returns an Authenticator sending the token as an 'Authorization: Bearer' header
+func BearerToken(token string) Authenticator {
+	return HeaderAuth("Authorization", "Bearer "+token)
+}
+
+// HeaderAuth returns an Authenticator setting the header to the value, e.g. for an API key
+func HeaderAuth(name string, value string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(name, value)
+		return nil
+	})
+}
+
+// BasicAuth returns an Authenticator sending HTTP Basic credentials
+func BasicAuth(username string, password string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
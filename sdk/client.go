@@ -0,0 +1,246 @@
+// Copyright (c) 2025 Neomantra Corp
+// client.go
+//
+// Go client for the REST API.
+
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiPath is the path of the REST API on the server
+const apiPath = "/api/v1"
+
+// ClientConfig is the configuration of a Client
+type ClientConfig struct {
+	BaseURL    string        // URL of the server, e.g. "http://localhost:8888"
+	HTTPClient *http.Client  // HTTP client to use (default: http.DefaultClient)
+	Auth       Authenticator // Adds credentials to each request (default: none)
+	Retry      *RetryPolicy  // Retries of failed requests (default: DefaultRetryPolicy)
+}
+
+// Client is a client of the REST API.  Its methods mirror the API's routes.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	auth       Authenticator
+	retry      RetryPolicy
+}
+
+// NewClient returns a Client for the configuration.  Returns nil and an error, if any.
+func NewClient(config ClientConfig) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(config.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", config.BaseURL)
+	}
+	client := &Client{
+		baseURL:    baseURL,
+		httpClient: config.HTTPClient,
+		auth:       config.Auth,
+		retry:      DefaultRetryPolicy,
+	}
+	if client.httpClient == nil {
+		client.httpClient = http.DefaultClient
+	}
+	if config.Retry != nil {
+		client.retry = *config.Retry
+	}
+	return client, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Errors
+
+// APIError is an error response from the server, decoded from its {"message": ...} body.
+// Use errors.As to check for it.
+type APIError struct {
+	StatusCode int           // HTTP status code
+	Message    string        // Message of the response body, or the body itself if not JSON
+	RetryAfter time.Duration // Retry-After of the response, if any
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// newAPIError decodes the error response.  The response body is consumed.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp)}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var decoded struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &decoded); err == nil && decoded.Message != "" {
+		apiErr.Message = decoded.Message
+	} else if text := strings.TrimSpace(string(body)); text != "" {
+		apiErr.Message = text
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// retryAfter returns the Retry-After header of the response in seconds, or zero if none
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Retries
+
+// RetryPolicy is how a Client retries failed requests.  Requests are retried after
+// network errors and 429, 502 and 503 responses, waiting an exponential backoff with
+// jitter, or the response's Retry-After if longer.  Query timeouts (504) are not retried.
+type RetryPolicy struct {
+	MaxAttempts    int           // Attempts of each request, including the first; 1 disables retries
+	InitialBackoff time.Duration // Backoff before the first retry, doubling after each
+	MaxBackoff     time.Duration // Maximum backoff, including Retry-After
+}
+
+// DefaultRetryPolicy is the RetryPolicy of a Client unless configured
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// retryableStatus returns true if requests failing with the status code may be retried
+func retryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// backoff returns the time to wait before retry number attempt, starting at 1
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	backoff := p.InitialBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	backoff = backoff/2 + rand.N(backoff/2+1) // jitter spreads out retrying clients
+	return min(max(backoff, retryAfter), p.MaxBackoff)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Requests
+
+// do sends a request to the API path with the query and JSON body, if any, retrying per the policy.
+// Returns the successful response, whose body the caller must close, or an error.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body any) (*http.Response, error) {
+	reqURL := c.baseURL.JoinPath(apiPath, path)
+	reqURL.RawQuery = query.Encode()
+
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		if bodyBytes, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	maxAttempts := max(c.retry.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, reqURL.String(), bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.auth != nil {
+			if err := c.auth.Authenticate(req); err != nil {
+				return nil, fmt.Errorf("failed to authenticate request: %w", err)
+			}
+		}
+
+		resp, err := c.httpClient.Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || attempt >= maxAttempts {
+				return nil, err // names the method and URL
+			}
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return resp, nil
+		default:
+			apiErr := newAPIError(resp)
+			resp.Body.Close()
+			if !retryableStatus(resp.StatusCode) || attempt >= maxAttempts {
+				return nil, apiErr
+			}
+			wait = apiErr.RetryAfter
+		}
+
+		timer := time.NewTimer(c.retry.backoff(attempt, wait))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// getJSON sends a GET request to the API path and decodes its JSON response into result
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, result any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeJSON(resp.Body, result)
+}
+
+// decodeJSON decodes a JSON response body into result
+func decodeJSON(body io.Reader, result any) error {
+	if err := json.NewDecoder(body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// getBytes sends a GET request to the API path and returns its response body
+func (c *Client) getBytes(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return body, nil
+}
+
+// pathOf joins escaped path segments
+func pathOf(segments ...string) string {
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// errMissingArgument is returned for empty required arguments, as the server's routes would not match
+var errMissingArgument = errors.New("missing required argument")
@@ -0,0 +1,300 @@
+// Copyright (c) 2025 Neomantra Corp
+// client_routes.go
+//
+// Client methods for each route of the REST API.
+
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeRange is the optional start and end of a request's time range.
+// The server defaults a zero Start to midnight Eastern and a zero End to now.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// addTo adds the range's 'start' and 'end' query parameters, if set
+func (r TimeRange) addTo(query url.Values) {
+	if !r.Start.IsZero() {
+		query.Set("start", r.Start.Format(time.RFC3339Nano))
+	}
+	if !r.End.IsZero() {
+		query.Set("end", r.End.Format(time.RFC3339Nano))
+	}
+}
+
+// ExportOptions are the options of Client.Export
+type ExportOptions struct {
+	TimeRange
+	Table  string // "trades" or "candles" (default: candles)
+	Format string // "csv", "xlsx", "parquet" or "json" (default: csv)
+	Limit  int    // Maximum rows to export (default: the server's maximum)
+}
+
+// ExportInfo describes an exported file
+type ExportInfo struct {
+	Filename    string // Attachment filename suggested by the server
+	ContentType string // Content-Type of the file
+	RowCount    int64  // Number of rows in the file
+	Size        int64  // Number of bytes written
+}
+
+// CandleChartOptions are the options of Client.CandleChart
+type CandleChartOptions struct {
+	TimeRange
+	Live       *bool    // Live-update the chart from the stream (default: true unless End is set)
+	Inline     bool     // Inline the chart's JavaScript, for a single-file offline page
+	Indicators []string // Indicators to draw instead of the VWAP lines, e.g. "ema:20"
+}
+
+// CompareChartOptions are the options of Client.CompareChart
+type CompareChartOptions struct {
+	TimeRange
+	Ratio  []string // Two symbols, plotting the ratio of the first's close to the second's
+	Inline bool     // Inline the chart's JavaScript, for a single-file offline page
+}
+
+// VolumeProfileChartOptions are the options of Client.VolumeProfileChart
+type VolumeProfileChartOptions struct {
+	Date      time.Time // Session date (default: today in Eastern)
+	Bucket    float64   // Price bucket size (default: the session's range divided into 50 levels)
+	ValueArea float64   // Percent of volume in the value area (default: 70)
+	Inline    bool      // Inline the chart's JavaScript, for a single-file offline page
+}
+
+// TapeChartOptions are the options of Client.TapeChart
+type TapeChartOptions struct {
+	TimeRange
+	Inline bool // Inline the chart's JavaScript, for a single-file offline page
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// LastTrades returns the last count trades of the ticker, or the server's default count if zero
+func (c *Client) LastTrades(ctx context.Context, dataset string, ticker string, count int) ([]TradeTick, error) {
+	if dataset == "" || ticker == "" {
+		return nil, errMissingArgument
+	}
+	var trades []TradeTick
+	err := c.getJSON(ctx, pathOf("last-trades", "json", dataset, ticker), countQuery(count), &trades)
+	return trades, err
+}
+
+// LastTradesCSV returns the last count trades of the ticker as a CSV file
+func (c *Client) LastTradesCSV(ctx context.Context, dataset string, ticker string, count int) ([]byte, error) {
+	if dataset == "" || ticker == "" {
+		return nil, errMissingArgument
+	}
+	return c.getBytes(ctx, pathOf("last-trades", "csv", dataset, ticker), countQuery(count))
+}
+
+// LastTradesExcel returns the last count trades of the ticker as an Excel file
+func (c *Client) LastTradesExcel(ctx context.Context, dataset string, ticker string, count int) ([]byte, error) {
+	if dataset == "" || ticker == "" {
+		return nil, errMissingArgument
+	}
+	return c.getBytes(ctx, pathOf("last-trades", "excel", dataset, ticker), countQuery(count))
+}
+
+// countQuery returns the 'count' query parameter, if nonzero
+func countQuery(count int) url.Values {
+	query := url.Values{}
+	if count != 0 {
+		query.Set("count", strconv.Itoa(count))
+	}
+	return query
+}
+
+// Candles returns the ticker's candles in the time range
+func (c *Client) Candles(ctx context.Context, dataset string, ticker string, timeRange TimeRange) ([]Candle, error) {
+	if dataset == "" || ticker == "" {
+		return nil, errMissingArgument
+	}
+	query := url.Values{}
+	timeRange.addTo(query)
+	var candles []Candle
+	err := c.getJSON(ctx, pathOf("candles", dataset, ticker), query, &candles)
+	return candles, err
+}
+
+// Indicators returns the ticker's technical indicators in the time range, e.g. "sma:50" or "rsi:14"
+func (c *Client) Indicators(ctx context.Context, dataset string, ticker string, indicators []string, timeRange TimeRange) ([]IndicatorSeries, error) {
+	if dataset == "" || ticker == "" || len(indicators) == 0 {
+		return nil, errMissingArgument
+	}
+	query := url.Values{"indicators": {strings.Join(indicators, ",")}}
+	timeRange.addTo(query)
+	var series []IndicatorSeries
+	err := c.getJSON(ctx, pathOf("indicators", dataset, ticker), query, &series)
+	return series, err
+}
+
+// Export writes a file of the ticker's trades or candles to w.
+// Returns the file's description and an error, if any.
+func (c *Client) Export(ctx context.Context, dataset string, ticker string, options ExportOptions, w io.Writer) (ExportInfo, error) {
+	if dataset == "" || ticker == "" {
+		return ExportInfo{}, errMissingArgument
+	}
+	query := url.Values{}
+	options.TimeRange.addTo(query)
+	if options.Table != "" {
+		query.Set("table", options.Table)
+	}
+	if options.Format != "" {
+		query.Set("format", options.Format)
+	}
+	if options.Limit != 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, pathOf("export", dataset, ticker), query, nil)
+	if err != nil {
+		return ExportInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	info := ExportInfo{ContentType: resp.Header.Get("Content-Type")}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		info.Filename = params["filename"]
+	}
+	info.RowCount, _ = strconv.ParseInt(resp.Header.Get("X-Row-Count"), 10, 64)
+	info.Size, err = io.Copy(w, resp.Body)
+	if err != nil {
+		return info, fmt.Errorf("failed to write export: %w", err)
+	}
+	return info, nil
+}
+
+// Subscriptions returns the live subscriptions of each dataset the server ingests
+func (c *Client) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	var subscriptions []Subscription
+	err := c.getJSON(ctx, "/subscriptions", nil, &subscriptions)
+	return subscriptions, err
+}
+
+// SetSubscriptions replaces the symbols the server ingests of a dataset, returning its new subscription.
+// It needs an API key with the "subscriptions" scope if the server enables authentication.
+func (c *Client) SetSubscriptions(ctx context.Context, dataset string, symbols []string) (*Subscription, error) {
+	if dataset == "" {
+		return nil, errMissingArgument
+	}
+	resp, err := c.do(ctx, http.MethodPut, pathOf("subscriptions", dataset), nil, SubscriptionRequest{Symbols: symbols})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var subscription Subscription
+	if err := decodeJSON(resp.Body, &subscription); err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Status returns the state of the server, its live datasets and its database
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.getJSON(ctx, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Query runs an ad-hoc, read-only SQL query and returns its result
+func (c *Client) Query(ctx context.Context, request QueryRequest) (*QueryResult, error) {
+	if request.SQL == "" {
+		return nil, errMissingArgument
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/query", url.Values{"format": {"json"}}, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result QueryResult
+	if err := decodeJSON(resp.Body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Charts are returned as HTML pages
+
+// CandleChart returns an HTML page with the ticker's candlestick chart
+func (c *Client) CandleChart(ctx context.Context, dataset string, ticker string, options CandleChartOptions) ([]byte, error) {
+	if dataset == "" || ticker == "" {
+		return nil, errMissingArgument
+	}
+	query := url.Values{}
+	options.TimeRange.addTo(query)
+	if options.Live != nil {
+		query.Set("live", strconv.FormatBool(*options.Live))
+	}
+	if options.Inline {
+		query.Set("inline", "true")
+	}
+	if len(options.Indicators) != 0 {
+		query.Set("indicators", strings.Join(options.Indicators, ","))
+	}
+	return c.getBytes(ctx, pathOf("charts", "candles", dataset, ticker), query)
+}
+
+// CompareChart returns an HTML page comparing the percent change of the symbols
+func (c *Client) CompareChart(ctx context.Context, dataset string, symbols []string, options CompareChartOptions) ([]byte, error) {
+	if dataset == "" || len(symbols) == 0 {
+		return nil, errMissingArgument
+	}
+	query := url.Values{"symbols": {strings.Join(symbols, ",")}}
+	options.TimeRange.addTo(query)
+	if len(options.Ratio) != 0 {
+		query.Set("ratio", strings.Join(options.Ratio, ","))
+	}
+	if options.Inline {
+		query.Set("inline", "true")
+	}
+	return c.getBytes(ctx, pathOf("charts", "compare", dataset), query)
+}
+
+// VolumeProfileChart returns an HTML page with the ticker's volume profile for a session
+func (c *Client) VolumeProfileChart(ctx context.Context, dataset string, ticker string, options VolumeProfileChartOptions) ([]byte, error) {
+	if dataset == "" || ticker == "" {
+		return nil, errMissingArgument
+	}
+	query := url.Values{}
+	if !options.Date.IsZero() {
+		query.Set("date", options.Date.Format(time.DateOnly))
+	}
+	if options.Bucket != 0 {
+		query.Set("bucket", strconv.FormatFloat(options.Bucket, 'f', -1, 64))
+	}
+	if options.ValueArea != 0 {
+		query.Set("va", strconv.FormatFloat(options.ValueArea, 'f', -1, 64))
+	}
+	if options.Inline {
+		query.Set("inline", "true")
+	}
+	return c.getBytes(ctx, pathOf("charts", "volume-profile", dataset, ticker), query)
+}
+
+// TapeChart returns an HTML page with the ticker's time-and-sales tape
+func (c *Client) TapeChart(ctx context.Context, dataset string, ticker string, options TapeChartOptions) ([]byte, error) {
+	if dataset == "" || ticker == "" {
+		return nil, errMissingArgument
+	}
+	query := url.Values{}
+	options.TimeRange.addTo(query)
+	if options.Inline {
+		query.Set("inline", "true")
+	}
+	return c.getBytes(ctx, pathOf("charts", "tape", dataset, ticker), query)
+}
@@ -0,0 +1,431 @@
+// Copyright (c) 2025 Neomantra Corp
+// client_test.go
+//
+// Integration tests of the Client against the server's routes, on an in-memory DuckDB.
+
+package sdk_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/handlers"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/middleware"
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gin-gonic/gin"
+	_ "github.com/marcboeker/go-duckdb/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	testDataset = "DBEQ.BASIC"
+	testTicker  = "AAPL"
+	testTrades  = 30 // trades every 10 seconds from testStart
+	testCandles = 10 // candles every minute from testStart
+)
+
+var (
+	testStart  = time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC)
+	testRange  = sdk.TimeRange{Start: testStart, End: testStart.Add(time.Hour)}
+	testRouter http.Handler // the server's routes, for wrapping by tests
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+// runTests registers the server's routes on a test database and runs the tests
+func runTests(m *testing.M) int {
+	gin.SetMode(gin.TestMode)
+	db, err := openTestDB()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open test database: %s\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	scratchPath, err := os.MkdirTemp("", "sdk-test-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create scratch dir: %s\n", err)
+		return 1
+	}
+	defer os.RemoveAll(scratchPath)
+	scratchDir, err := middleware.NewScratchDir(scratchPath, 2, time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create scratch dir: %s\n", err)
+		return 1
+	}
+	handlers.SetScratchDir(scratchDir)
+	handlers.SetQueryConfig(handlers.QueryConfig{MaxRows: 100, CacheSize: -1})
+
+	testRouter = handlers.Register("localhost", db, gin.New(), zap.NewNop())
+	return m.Run()
+}
+
+// openTestDB returns an in-memory DuckDB with the trades and candles tables filled
+func openTestDB() (*sql.DB, error) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return nil, err
+	}
+	for table, migration := range map[string]string{
+		"trades":  middleware.TradeMigrationTemplate,
+		"candles": middleware.CandlesMigrationTemplate,
+	} {
+		info := middleware.MigrationInfo{MigrationName: table, TableName: table}
+		if err := middleware.RunMigration(db, migration, info); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	for i := range testTrades {
+		ts := testStart.Add(time.Duration(i) * 10 * time.Second)
+		if _, err := db.Exec(`INSERT INTO trades VALUES (?, ?, 0, 1, ?, ?, ?);`,
+			ts, ts.Unix(), testTicker, 200+float64(i)/10, 100+i); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	for i := range testCandles {
+		ts := testStart.Add(time.Duration(i) * time.Minute)
+		price := 200 + float64(i)
+		if _, err := db.Exec(`INSERT INTO candles VALUES (?, ?, 0, 1, ?, ?, ?, ?, ?, ?);`,
+			ts, ts.Unix(), testTicker, price, price+1, price-1, price+0.5, 1000+i); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// newTestClient starts a server of handler and returns a Client of it without retry backoff
+func newTestClient(t *testing.T, handler http.Handler, config sdk.ClientConfig) *sdk.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	config.BaseURL = server.URL
+	if config.Retry == nil {
+		config.Retry = &sdk.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	}
+	client, err := sdk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	return client
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestNewClient(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:8888", "ftp://localhost"} {
+		if _, err := sdk.NewClient(sdk.ClientConfig{BaseURL: baseURL}); err == nil {
+			t.Errorf("NewClient(%q) succeeded, want error", baseURL)
+		}
+	}
+}
+
+func TestLastTrades(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	trades, err := client.LastTrades(context.Background(), testDataset, testTicker, 5)
+	if err != nil {
+		t.Fatalf("LastTrades: %s", err)
+	}
+	if len(trades) != 5 {
+		t.Fatalf("LastTrades returned %d trades, want 5", len(trades))
+	}
+	if trades[0].Ticker != testTicker || trades[0].Timestamp != testStart.Unix() || trades[0].Price != 200 {
+		t.Errorf("LastTrades first trade = %+v", trades[0])
+	}
+
+	trades, err = client.LastTrades(context.Background(), testDataset, "MSFT", 0)
+	if err != nil {
+		t.Fatalf("LastTrades of unknown ticker: %s", err)
+	}
+	if len(trades) != 0 {
+		t.Errorf("LastTrades of unknown ticker returned %d trades, want 0", len(trades))
+	}
+}
+
+func TestLastTradesCSV(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	csv, err := client.LastTradesCSV(context.Background(), testDataset, testTicker, 3)
+	if err != nil {
+		t.Fatalf("LastTradesCSV: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(csv)), "\n")
+	if len(lines) != 4 {
+		t.Errorf("LastTradesCSV returned %d lines, want a header and 3 trades:\n%s", len(lines), csv)
+	}
+}
+
+func TestCandles(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	candles, err := client.Candles(context.Background(), testDataset, testTicker, testRange)
+	if err != nil {
+		t.Fatalf("Candles: %s", err)
+	}
+	if len(candles) != testCandles {
+		t.Fatalf("Candles returned %d candles, want %d", len(candles), testCandles)
+	}
+	if last := candles[len(candles)-1]; last.Close != 209.5 || last.Volume != 1009 {
+		t.Errorf("Candles last candle = %+v", last)
+	}
+}
+
+func TestIndicators(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	series, err := client.Indicators(context.Background(), testDataset, testTicker, []string{"sma:3"}, testRange)
+	if err != nil {
+		t.Fatalf("Indicators: %s", err)
+	}
+	if len(series) != 1 || series[0].Indicator != "sma:3" {
+		t.Fatalf("Indicators returned %+v", series)
+	}
+	if len(series[0].Points) != testCandles-2 {
+		t.Errorf("Indicators returned %d points, want %d", len(series[0].Points), testCandles-2)
+	}
+}
+
+func TestExport(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	var buf bytes.Buffer
+	info, err := client.Export(context.Background(), testDataset, testTicker,
+		sdk.ExportOptions{TimeRange: testRange, Table: "trades", Format: "csv", Limit: 20}, &buf)
+	if err != nil {
+		t.Fatalf("Export: %s", err)
+	}
+	if info.RowCount != 20 {
+		t.Errorf("Export RowCount = %d, want 20", info.RowCount)
+	}
+	if info.Size != int64(buf.Len()) || info.Size == 0 {
+		t.Errorf("Export Size = %d, wrote %d bytes", info.Size, buf.Len())
+	}
+	if !strings.HasSuffix(info.Filename, ".csv") {
+		t.Errorf("Export Filename = %q, want a .csv file", info.Filename)
+	}
+}
+
+func TestCharts(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	ctx := context.Background()
+	live := false
+	charts := map[string]func() ([]byte, error){
+		"CandleChart": func() ([]byte, error) {
+			return client.CandleChart(ctx, testDataset, testTicker, sdk.CandleChartOptions{TimeRange: testRange, Live: &live})
+		},
+		"CompareChart": func() ([]byte, error) {
+			return client.CompareChart(ctx, testDataset, []string{testTicker, "MSFT"}, sdk.CompareChartOptions{TimeRange: testRange})
+		},
+		"VolumeProfileChart": func() ([]byte, error) {
+			return client.VolumeProfileChart(ctx, testDataset, testTicker, sdk.VolumeProfileChartOptions{Date: testStart})
+		},
+		"TapeChart": func() ([]byte, error) {
+			return client.TapeChart(ctx, testDataset, testTicker, sdk.TapeChartOptions{TimeRange: testRange})
+		},
+	}
+	for name, chart := range charts {
+		page, err := chart()
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		} else if !bytes.Contains(page, []byte("<html")) {
+			t.Errorf("%s did not return an HTML page", name)
+		}
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	subscriptions, err := client.Subscriptions(context.Background())
+	if err != nil {
+		t.Fatalf("Subscriptions: %s", err)
+	}
+	if subscriptions == nil || len(subscriptions) != 0 {
+		t.Errorf("Subscriptions without live clients = %+v, want empty", subscriptions)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	status, err := client.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %s", err)
+	}
+	if status.Version == "" || status.Datasets == nil || len(status.Datasets) != 0 {
+		t.Errorf("Status without live clients = %+v", status)
+	}
+	rowCounts := make(map[string]int64)
+	for _, table := range status.Tables {
+		rowCounts[table.Name] = table.RowCount
+	}
+	if rowCounts["trades"] != testTrades || rowCounts["candles"] != testCandles {
+		t.Errorf("Status row counts = %v, want %d trades and %d candles", rowCounts, testTrades, testCandles)
+	}
+	if status.DBFile != "" || status.DBSize != 0 {
+		t.Errorf("Status of an in-memory database has file %q of %d bytes", status.DBFile, status.DBSize)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	result, err := client.Query(context.Background(), sdk.QueryRequest{
+		SQL:     "SELECT ticker, count(*) AS n FROM trades GROUP BY ticker",
+		MaxRows: 10,
+	})
+	if err != nil {
+		t.Fatalf("Query: %s", err)
+	}
+	if result.RowCount != 1 || len(result.Columns) != 2 || result.Columns[1].Name != "n" {
+		t.Fatalf("Query returned %+v", result)
+	}
+	if n, _ := result.Rows[0][1].(float64); n != testTrades {
+		t.Errorf("Query count = %v, want %d", result.Rows[0][1], testTrades)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	client := newTestClient(t, testRouter, sdk.ClientConfig{})
+	_, err := client.Query(context.Background(), sdk.QueryRequest{SQL: "DELETE FROM trades"})
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Query of DELETE returned %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Message == "" {
+		t.Errorf("Query of DELETE returned %+v", apiErr)
+	}
+
+	_, err = client.LastTrades(context.Background(), testDataset, testTicker, -1)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("LastTrades of negative count returned %v, want a 400 APIError", err)
+	}
+
+	_, err = client.SetSubscriptions(context.Background(), testDataset, []string{testTicker})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "dataset not found: "+testDataset {
+		t.Errorf("SetSubscriptions without a live client returned %v, want a 404 APIError", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var attempts atomic.Int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"busy"}`))
+			return
+		}
+		testRouter.ServeHTTP(w, r)
+	})
+
+	client := newTestClient(t, flaky, sdk.ClientConfig{})
+	if _, err := client.LastTrades(context.Background(), testDataset, testTicker, 1); err != nil {
+		t.Fatalf("LastTrades after retries: %s", err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("LastTrades made %d attempts, want 3", n)
+	}
+
+	attempts.Store(0)
+	client = newTestClient(t, flaky, sdk.ClientConfig{Retry: &sdk.RetryPolicy{MaxAttempts: 2}})
+	_, err := client.LastTrades(context.Background(), testDataset, testTicker, 1)
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Message != "busy" {
+		t.Errorf("LastTrades with too few attempts returned %v, want a 503 APIError", err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	const token = "s3cret"
+	authed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"unauthorized"}`))
+			return
+		}
+		testRouter.ServeHTTP(w, r)
+	})
+
+	client := newTestClient(t, authed, sdk.ClientConfig{Auth: sdk.BearerToken(token)})
+	if _, err := client.LastTrades(context.Background(), testDataset, testTicker, 1); err != nil {
+		t.Errorf("LastTrades with token: %s", err)
+	}
+
+	client = newTestClient(t, authed, sdk.ClientConfig{})
+	_, err := client.LastTrades(context.Background(), testDataset, testTicker, 1)
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("LastTrades without token returned %v, want a 401 APIError", err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	keysDB, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keysDB.Close()
+	store, err := middleware.NewDuckDBKeyStore(keysDB, "api_keys")
+	if err != nil {
+		t.Fatalf("NewDuckDBKeyStore: %s", err)
+	}
+	newKey := func(id string, scopes []string, datasets []string) string {
+		key, token, err := middleware.NewAPIKey(id, scopes, datasets)
+		if err != nil {
+			t.Fatalf("NewAPIKey: %s", err)
+		}
+		if err := store.AddKey(context.Background(), key); err != nil {
+			t.Fatalf("AddKey: %s", err)
+		}
+		return token
+	}
+	readToken := newKey("reader", []string{middleware.ScopeRead}, []string{testDataset})
+	otherToken := newKey("other", []string{middleware.ScopeRead, middleware.ScopeExport}, []string{"XNAS.ITCH"})
+	subsToken := newKey("subs", []string{middleware.ScopeSubscriptions}, []string{middleware.AllDatasets})
+	middleware.SetKeyStore(store)
+	t.Cleanup(func() { middleware.SetKeyStore(nil) })
+
+	wantStatus := func(name string, err error, status int) {
+		t.Helper()
+		var apiErr *sdk.APIError
+		if status == http.StatusOK && err != nil {
+			t.Errorf("%s: %s", name, err)
+		} else if status != http.StatusOK && (!errors.As(err, &apiErr) || apiErr.StatusCode != status) {
+			t.Errorf("%s returned %v, want a %d APIError", name, err, status)
+		}
+	}
+	lastTrades := func(auth sdk.Authenticator) error {
+		client := newTestClient(t, testRouter, sdk.ClientConfig{Auth: auth})
+		_, err := client.LastTrades(context.Background(), testDataset, testTicker, 1)
+		return err
+	}
+	readID, readSecret, _ := strings.Cut(readToken, ".")
+	wantStatus("LastTrades with bearer token", lastTrades(sdk.BearerToken(readToken)), http.StatusOK)
+	wantStatus("LastTrades with X-API-Key", lastTrades(sdk.HeaderAuth("X-API-Key", readToken)), http.StatusOK)
+	wantStatus("LastTrades with basic auth", lastTrades(sdk.BasicAuth(readID, readSecret)), http.StatusOK)
+	wantStatus("LastTrades without key", lastTrades(nil), http.StatusUnauthorized)
+	wantStatus("LastTrades with wrong secret", lastTrades(sdk.BearerToken(readID+".wrong")), http.StatusUnauthorized)
+	wantStatus("LastTrades of another dataset", lastTrades(sdk.BearerToken(otherToken)), http.StatusForbidden)
+	wantStatus("LastTrades without read scope", lastTrades(sdk.BearerToken(subsToken)), http.StatusForbidden)
+
+	client := newTestClient(t, testRouter, sdk.ClientConfig{Auth: sdk.BearerToken(readToken)})
+	_, err = client.Export(context.Background(), testDataset, testTicker, sdk.ExportOptions{}, io.Discard)
+	wantStatus("Export without export scope", err, http.StatusForbidden)
+	_, err = client.SetSubscriptions(context.Background(), testDataset, []string{testTicker})
+	wantStatus("SetSubscriptions without subscriptions scope", err, http.StatusForbidden)
+
+	client = newTestClient(t, testRouter, sdk.ClientConfig{Auth: sdk.BearerToken(subsToken)})
+	_, err = client.SetSubscriptions(context.Background(), testDataset, []string{testTicker})
+	wantStatus("SetSubscriptions without live clients", err, http.StatusNotFound)
+}
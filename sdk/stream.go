@@ -0,0 +1,50 @@
+// Copyright (c) 2025 Neomantra Corp
+// stream.go
+//
+// WebSocket market-data gateway protocol.
+//
+// Clients send StreamRequest JSON messages to subscribe and unsubscribe
+// to (dataset, schema, symbols).  The server pushes StreamMessage JSON
+// messages carrying a TradeTick or Candle as they are ingested.
+
+package sdk
+
+// StreamRequest types, sent from client to server
+const (
+	StreamRequestSubscribe   = "subscribe"   // Subscribe to a dataset, schema and symbols
+	StreamRequestUnsubscribe = "unsubscribe" // Unsubscribe from a dataset, schema and symbols
+	StreamRequestPing        = "ping"        // Request a "pong" response
+)
+
+// StreamMessage types, sent from server to client
+const (
+	StreamMessageTrade        = "trade"        // Trade carries a TradeTick
+	StreamMessageCandle       = "candle"       // Candle carries a Candle
+	StreamMessageSubscribed   = "subscribed"   // Acknowledges a "subscribe" request
+	StreamMessageUnsubscribed = "unsubscribed" // Acknowledges an "unsubscribe" request
+	StreamMessageHeartbeat    = "heartbeat"    // Periodic liveness message with the server time
+	StreamMessagePong         = "pong"         // Response to a "ping" request
+	StreamMessageError        = "error"        // Error response, the connection stays open
+)
+
+// StreamRequest is a message from a client to the WebSocket gateway.
+type StreamRequest struct {
+	Type    string   `json:"type" example:"subscribe"`               // Request type, "subscribe", "unsubscribe", or "ping"
+	ID      string   `json:"id,omitempty" example:"1"`               // Optional client request ID, echoed in the response
+	Dataset string   `json:"dataset,omitempty" example:"DBEQ.BASIC"` // Databento dataset
+	Schema  string   `json:"schema,omitempty" example:"trades"`      // DBN schema, "trades" or "ohlcv-1m"
+	Symbols []string `json:"symbols,omitempty" example:"AAPL"`       // Symbols to (un)subscribe
+}
+
+// StreamMessage is a message from the WebSocket gateway to a client.
+type StreamMessage struct {
+	Type    string     `json:"type" example:"trade"`                   // Message type, see the StreamMessage constants
+	ID      string     `json:"id,omitempty" example:"1"`               // Request ID this message responds to, if any
+	Dataset string     `json:"dataset,omitempty" example:"DBEQ.BASIC"` // Databento dataset
+	Schema  string     `json:"schema,omitempty" example:"trades"`      // DBN schema
+	Symbols []string   `json:"symbols,omitempty" example:"AAPL"`       // Symbols of a (un)subscribe response
+	Trade   *TradeTick `json:"trade,omitempty"`                        // Trade datum of a "trade" message
+	Candle  *Candle    `json:"candle,omitempty"`                       // Candle datum of a "candle" message
+	Time    int64      `json:"time,omitempty" example:"1713644400"`    // Server time of a "heartbeat" as seconds from the epoch
+	Message string     `json:"message,omitempty"`                      // Error message of an "error" message
+}
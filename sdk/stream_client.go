@@ -0,0 +1,135 @@
+// Copyright (c) 2025 Neomantra Corp
+// stream_client.go
+//
+// Go client for the WebSocket market-data gateway protocol.
+
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamClientBufferSize   = 1024             // messages buffered before the client's reader blocks
+	streamClientWriteTimeout = 10 * time.Second // time allowed to write a request
+)
+
+// StreamClient is a connection to the /ws/v1 WebSocket market-data gateway.
+//
+// Received messages are delivered on the channel returned by Messages(),
+// which is closed when the connection ends; Err() then returns the reason.
+type StreamClient struct {
+	conn      *websocket.Conn
+	msgs      chan StreamMessage
+	nextID    atomic.Uint64
+	closed    atomic.Bool
+	done      chan struct{} // closed by Close, so the reader stops delivering
+	closeOnce sync.Once
+
+	writeMu sync.Mutex
+	errMu   sync.Mutex
+	err     error
+}
+
+// DialStream connects to the WebSocket gateway at url, e.g. "ws://localhost:8888/ws/v1".
+// The header is sent with the handshake and may be nil.
+func DialStream(ctx context.Context, url string, header http.Header) (*StreamClient, error) {
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("failed to dial %s: %s: %w", url, resp.Status, err)
+		}
+		return nil, fmt.Errorf("failed to dial %s: %w", url, err)
+	}
+	sc := &StreamClient{
+		conn: conn,
+		msgs: make(chan StreamMessage, streamClientBufferSize),
+		done: make(chan struct{}),
+	}
+	go sc.readLoop()
+	return sc, nil
+}
+
+// Messages returns the channel of messages from the server.
+func (sc *StreamClient) Messages() <-chan StreamMessage {
+	return sc.msgs
+}
+
+// Err returns the error that ended the connection, if any.
+// Only valid after the Messages() channel is closed.
+func (sc *StreamClient) Err() error {
+	sc.errMu.Lock()
+	defer sc.errMu.Unlock()
+	return sc.err
+}
+
+// Subscribe requests (dataset, schema, symbols) from the server.
+// Returns the request ID, which is echoed in the "subscribed" or "error" response.
+func (sc *StreamClient) Subscribe(dataset string, schema string, symbols ...string) (string, error) {
+	return sc.send(StreamRequest{Type: StreamRequestSubscribe, Dataset: dataset, Schema: schema, Symbols: symbols})
+}
+
+// Unsubscribe removes (dataset, schema, symbols) from the connection's subscriptions.
+// Returns the request ID, which is echoed in the "unsubscribed" or "error" response.
+func (sc *StreamClient) Unsubscribe(dataset string, schema string, symbols ...string) (string, error) {
+	return sc.send(StreamRequest{Type: StreamRequestUnsubscribe, Dataset: dataset, Schema: schema, Symbols: symbols})
+}
+
+// Ping requests a "pong" from the server.
+// Returns the request ID, which is echoed in the response.
+func (sc *StreamClient) Ping() (string, error) {
+	return sc.send(StreamRequest{Type: StreamRequestPing})
+}
+
+// Close sends a close message and closes the connection.
+// Messages not yet received are dropped, so the client need not drain them.
+func (sc *StreamClient) Close() error {
+	sc.closed.Store(true)
+	sc.closeOnce.Do(func() { close(sc.done) })
+	sc.writeMu.Lock()
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	sc.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(streamClientWriteTimeout))
+	sc.writeMu.Unlock()
+	return sc.conn.Close()
+}
+
+// send writes the request, assigning it an ID
+func (sc *StreamClient) send(req StreamRequest) (string, error) {
+	req.ID = strconv.FormatUint(sc.nextID.Add(1), 10)
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	sc.conn.SetWriteDeadline(time.Now().Add(streamClientWriteTimeout))
+	if err := sc.conn.WriteJSON(req); err != nil {
+		return "", fmt.Errorf("failed to send %s request: %w", req.Type, err)
+	}
+	return req.ID, nil
+}
+
+// readLoop delivers messages until the connection ends
+func (sc *StreamClient) readLoop() {
+	defer close(sc.msgs)
+	for {
+		var msg StreamMessage
+		if err := sc.conn.ReadJSON(&msg); err != nil {
+			if !sc.closed.Load() && !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				sc.errMu.Lock()
+				sc.err = err
+				sc.errMu.Unlock()
+			}
+			return
+		}
+		select {
+		case sc.msgs <- msg:
+		case <-sc.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2025 Neomantra Corp
+// stream_client_test.go
+//
+// Tests of the StreamClient's protocol against a scripted WebSocket server.
+// The gateway itself is tested with the StreamClient in handlers/get_ws_stream_test.go.
+
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+	"github.com/gorilla/websocket"
+)
+
+// dialScriptedServer starts a WebSocket server running script on each connection, and dials it
+func dialScriptedServer(t *testing.T, script func(conn *websocket.Conn)) *sdk.StreamClient {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		script(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sc, err := sdk.DialStream(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("DialStream: %s", err)
+	}
+	t.Cleanup(func() { sc.Close() })
+	return sc
+}
+
+// drainMessages returns the client's messages until its connection ends
+func drainMessages(t *testing.T, sc *sdk.StreamClient) []sdk.StreamMessage {
+	t.Helper()
+	var msgs []sdk.StreamMessage
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-sc.Messages():
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		case <-timeout:
+			t.Fatalf("timed out waiting for the connection to close")
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestStreamClientRequests(t *testing.T) {
+	requests := make(chan sdk.StreamRequest, 3)
+	sc := dialScriptedServer(t, func(conn *websocket.Conn) {
+		// acknowledge each request, then close normally
+		for range 3 {
+			var req sdk.StreamRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			requests <- req
+			conn.WriteJSON(sdk.StreamMessage{Type: sdk.StreamMessagePong, ID: req.ID})
+		}
+		conn.WriteJSON(sdk.StreamMessage{Type: sdk.StreamMessageTrade, Trade: &sdk.TradeTick{Ticker: "AAPL"}})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	})
+
+	subID, err := sc.Subscribe("DBEQ.BASIC", "trades", "AAPL", "MSFT")
+	if err != nil {
+		t.Fatalf("Subscribe: %s", err)
+	}
+	unsubID, err := sc.Unsubscribe("DBEQ.BASIC", "trades", "MSFT")
+	if err != nil {
+		t.Fatalf("Unsubscribe: %s", err)
+	}
+	pingID, err := sc.Ping()
+	if err != nil {
+		t.Fatalf("Ping: %s", err)
+	}
+	if subID == unsubID || unsubID == pingID || subID == pingID {
+		t.Errorf("request IDs %q, %q, %q are not unique", subID, unsubID, pingID)
+	}
+
+	for _, want := range []sdk.StreamRequest{
+		{Type: sdk.StreamRequestSubscribe, ID: subID, Dataset: "DBEQ.BASIC", Schema: "trades", Symbols: []string{"AAPL", "MSFT"}},
+		{Type: sdk.StreamRequestUnsubscribe, ID: unsubID, Dataset: "DBEQ.BASIC", Schema: "trades", Symbols: []string{"MSFT"}},
+		{Type: sdk.StreamRequestPing, ID: pingID},
+	} {
+		got := <-requests
+		if got.Type != want.Type || got.ID != want.ID || got.Dataset != want.Dataset || got.Schema != want.Schema ||
+			strings.Join(got.Symbols, ",") != strings.Join(want.Symbols, ",") {
+			t.Errorf("server received %+v, want %+v", got, want)
+		}
+	}
+
+	msgs := drainMessages(t, sc)
+	if len(msgs) != 4 || msgs[0].ID != subID || msgs[1].ID != unsubID || msgs[2].ID != pingID ||
+		msgs[3].Trade == nil || msgs[3].Trade.Ticker != "AAPL" {
+		t.Errorf("received %+v", msgs)
+	}
+	if err := sc.Err(); err != nil {
+		t.Errorf("Err after a normal close = %v, want nil", err)
+	}
+}
+
+func TestStreamClientServerClose(t *testing.T) {
+	sc := dialScriptedServer(t, func(conn *websocket.Conn) {
+		msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer")
+		conn.WriteMessage(websocket.CloseMessage, msg)
+	})
+	drainMessages(t, sc)
+	var closeErr *websocket.CloseError
+	if err := sc.Err(); !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseTryAgainLater {
+		t.Errorf("Err = %v, want the server's close", err)
+	}
+}
+
+func TestStreamClientClose(t *testing.T) {
+	sc := dialScriptedServer(t, func(conn *websocket.Conn) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	if err := sc.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+	drainMessages(t, sc)
+	if err := sc.Err(); err != nil {
+		t.Errorf("Err after Close = %v, want nil", err)
+	}
+	if _, err := sc.Ping(); err == nil {
+		t.Errorf("Ping after Close succeeded, want error")
+	}
+}
+
+func TestStreamClientCloseUnread(t *testing.T) {
+	sc := dialScriptedServer(t, func(conn *websocket.Conn) {
+		for {
+			if err := conn.WriteJSON(sdk.StreamMessage{Type: sdk.StreamMessageTrade}); err != nil {
+				return
+			}
+		}
+	})
+	// the consumer stops reading, so the reader blocks once the buffer is full
+	for deadline := time.Now().Add(5 * time.Second); len(sc.Messages()) < cap(sc.Messages()); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the buffer to fill")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		stacks := make([]byte, 1<<20)
+		stacks = stacks[:runtime.Stack(stacks, true)]
+		if !strings.Contains(string(stacks), "(*StreamClient).readLoop") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reader still running after Close")
+		}
+	}
+}
+
+func TestDialStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	_, err := sdk.DialStream(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("DialStream of an unauthorized route returned %v, want a 401 error", err)
+	}
+}
@@ -30,3 +30,77 @@ type Candle struct {
 	Close       float64 `json:"close" example:"214.21"`       // Close price of candlestick
 	Volume      uint64  `json:"volume" example:"100"`         // Volume in candlestick
 }
+
+// IndicatorSeries is one line of a technical indicator over time.
+type IndicatorSeries struct {
+	Name      string           `json:"name" example:"bb_20_2_upper"`   // Field name of the line, unique per request
+	Indicator string           `json:"indicator" example:"bb:20:2"`    // Indicator specification that produced the line
+	Line      string           `json:"line,omitempty" example:"upper"` // Line of a multi-line indicator, e.g. "upper" or "signal"
+	Pane      string           `json:"pane" example:"overlay"`         // "overlay" to draw over prices, otherwise the name of its own pane
+	Points    []IndicatorPoint `json:"points"`                         // Values, omitting the warm-up period
+}
+
+// IndicatorPoint is a technical indicator value at a time.
+type IndicatorPoint struct {
+	Timestamp int64   `json:"ts" example:"1713644400"` // Candle timestamp as seconds from the epoch
+	Value     float64 `json:"value" example:"214.21"`  // Indicator value
+}
+
+// QueryRequest is an ad-hoc SQL query.
+type QueryRequest struct {
+	SQL     string `json:"sql" example:"SELECT ticker, count(*) AS trades FROM trades GROUP BY ticker"` // Single SELECT statement of the allowed tables
+	MaxRows int    `json:"max_rows,omitempty" example:"1000"`                                           // Maximum rows to return, at most the server's limit
+}
+
+// QueryColumn is a column of an ad-hoc SQL query result.
+type QueryColumn struct {
+	Name string `json:"name" example:"ticker"`  // Column name
+	Type string `json:"type" example:"VARCHAR"` // DuckDB type of the column
+}
+
+// QueryResult is the result of an ad-hoc SQL query.
+type QueryResult struct {
+	Columns   []QueryColumn `json:"columns"`                         // Result columns
+	Rows      [][]any       `json:"rows" swaggertype:"array,object"` // Result rows, each with a value per column
+	RowCount  int           `json:"row_count" example:"1"`           // Number of rows
+	Truncated bool          `json:"truncated" example:"false"`       // True if rows beyond the row limit were dropped
+	Cached    bool          `json:"cached" example:"false"`          // True if the result was served from the cache
+}
+
+// Subscription is the live feed of a dataset that the server ingests.
+type Subscription struct {
+	Dataset string   `json:"dataset" example:"DBEQ.BASIC"`      // Databento dataset
+	Schemas []string `json:"schemas" example:"trades,ohlcv-1m"` // DBN schemas ingested
+	Symbols []string `json:"symbols" example:"AAPL,SPY"`        // Subscribed symbols, sorted
+}
+
+// SubscriptionRequest is the request of PUT /subscriptions/{dataset}, replacing its symbols
+type SubscriptionRequest struct {
+	Symbols []string `json:"symbols" example:"AAPL,SPY"` // Symbols to subscribe
+}
+
+// Status is the state of the server, its live datasets and its database.
+type Status struct {
+	Version  string          `json:"version" example:"git:0123abcd"`           // VCS type and revision of the server's build
+	Datasets []DatasetStatus `json:"datasets"`                                 // Live datasets, sorted by dataset
+	Tables   []TableStatus   `json:"tables"`                                   // Tables of the database, sorted by name
+	DBFile   string          `json:"db_file,omitempty" example:"goose.duckdb"` // DuckDB database file, empty if in-memory
+	DBSize   int64           `json:"db_size" example:"1048576"`                // Bytes of the database file and its write-ahead log, 0 if in-memory
+}
+
+// DatasetStatus is the state of the live feed of a dataset.
+type DatasetStatus struct {
+	Dataset        string   `json:"dataset" example:"DBEQ.BASIC"`                // Databento dataset
+	Schemas        []string `json:"schemas" example:"trades,ohlcv-1m"`           // DBN schemas ingested
+	Symbols        []string `json:"symbols" example:"AAPL,SPY"`                  // Subscribed symbols, sorted
+	Connected      bool     `json:"connected" example:"true"`                    // True while the live session is followed
+	LastRecordTime int64    `json:"last_record_ts" example:"1713644401"`         // Time the last record of any kind was received, as seconds from the epoch; 0 if none
+	LastEventTime  int64    `json:"last_event_ts" example:"1713644400123456789"` // Latest ts_event of the trades and candles ingested, as nanoseconds from the epoch; 0 if none
+	LagSeconds     float64  `json:"lag_seconds" example:"0.25"`                  // Seconds from the latest ts_event to the server's clock; 0 if none
+}
+
+// TableStatus is the size of a database table.
+type TableStatus struct {
+	Name     string `json:"name" example:"trades"`      // Table name
+	RowCount int64  `json:"row_count" example:"123456"` // Number of rows
+}
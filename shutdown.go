@@ -0,0 +1,45 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// shutdownPhase is a step of the server's graceful shutdown
+type shutdownPhase struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// runShutdown runs the phases in order, logging each.  A phase that fails is logged and
+// the next one runs.  A phase still running at ctx's deadline is left behind, and the
+// next ones run with the expired ctx, so phases must check what they depend on.
+// Returns true if every phase succeeded.
+func runShutdown(ctx context.Context, logger *zap.Logger, phases []shutdownPhase) bool {
+	clean := true
+	for _, phase := range phases {
+		logger.Info("shutdown: "+phase.Name, zap.String("phase", phase.Name))
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			done <- phase.Run(ctx)
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				clean = false
+				logger.Error("shutdown: "+phase.Name+" failed", zap.String("phase", phase.Name), zap.Error(err))
+			} else {
+				logger.Info("shutdown: "+phase.Name+" done", zap.String("phase", phase.Name), zap.Duration("duration", time.Since(start)))
+			}
+		case <-ctx.Done():
+			clean = false
+			logger.Error("shutdown: "+phase.Name+" timed out", zap.String("phase", phase.Name), zap.Error(ctx.Err()))
+		}
+	}
+	return clean
+}
@@ -0,0 +1,351 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/ntcharts/sparkline"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+)
+
+const (
+	maxBatchSize    = 4096 // stream messages applied per update
+	maxSparkHeight  = 12   // rows of the sparkline
+	minSparkHeight  = 3
+	sparkBorderSize = 2 // rows and columns of the sparkline's border
+	fixedViewHeight = 5 // rows of the title, error, table header, caption and help
+)
+
+// Config is the configuration of the watchlist TUI
+type Config struct {
+	Title   string   // Title of the feed, such as its dataset and source
+	Tickers []string // Tickers to watch; if empty, every ticker of the source is shown
+	Source  Source   // Feed of trades and candles
+}
+
+// Run shows the watchlist until the user quits or ctx is done.
+// Returns an error, if any.
+func Run(ctx context.Context, config Config) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	program := tea.NewProgram(newModel(config), tea.WithAltScreen(), tea.WithContext(ctx))
+
+	// The source's messages are applied in batches, so fast feeds such as
+	// whole files are not rendered once per record
+	events := make(chan sdk.StreamMessage, maxBatchSize)
+	send := func(msg sdk.StreamMessage) {
+		select {
+		case events <- msg:
+		case <-ctx.Done():
+		}
+	}
+	var sourceErr error
+	go func() {
+		sourceErr = config.Source(ctx, send)
+		close(events)
+	}()
+	go func() {
+		defer func() { program.Send(sourceDoneMsg{sourceErr}) }() // after the last batch
+		for msg := range events {
+			batch := batchMsg{msg}
+		drain:
+			for len(batch) < maxBatchSize {
+				select {
+				case msg, ok := <-events:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, msg)
+				default:
+					break drain
+				}
+			}
+			program.Send(batch)
+		}
+	}()
+
+	_, err := program.Run()
+	if errors.Is(err, tea.ErrProgramKilled) && ctx.Err() != nil {
+		return nil // interrupted
+	}
+	return err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// batchMsg is a batch of stream messages from the Source
+type batchMsg []sdk.StreamMessage
+
+// sourceDoneMsg is sent when the Source ends
+type sourceDoneMsg struct {
+	err error
+}
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#5f5fd7")).Padding(0, 1)
+	headerStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#8a8a8a"))
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	upStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("#00c853"))
+	downStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#ff5252"))
+	dimStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#8a8a8a"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#ff5252"))
+	sparkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#5fafff"))
+	sparkBorder   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#585858"))
+)
+
+// watchlistColumns are the watchlist table's columns; all but the first are right-aligned
+var watchlistColumns = []struct {
+	title string
+	width int
+}{
+	{"SYMBOL", 10}, {"LAST", 12}, {"CHG", 10}, {"CHG%", 9}, {"VOLUME", 14}, {"TRADES", 9}, {"TIME", 10},
+}
+
+// model is the bubbletea model of the watchlist
+type model struct {
+	config    Config
+	watchlist *Watchlist
+	selected  string // ticker of the selected row
+	width     int
+	height    int
+	status    string // state of the source
+	err       error  // error that ended the source, if any
+}
+
+func newModel(config Config) model {
+	m := model{
+		config:    config,
+		watchlist: NewWatchlist(config.Tickers...),
+		status:    "connecting",
+	}
+	if tickers := m.watchlist.Tickers(); len(tickers) > 0 {
+		m.selected = tickers[0]
+	}
+	return m
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "up", "k":
+			m.moveSelection(-1)
+		case "down", "j":
+			m.moveSelection(1)
+		case "home", "g":
+			m.moveSelection(-len(m.watchlist.Tickers()))
+		case "end", "G":
+			m.moveSelection(len(m.watchlist.Tickers()))
+		}
+	case batchMsg:
+		m.status = "live"
+		for _, streamMsg := range msg {
+			m.applyStreamMessage(streamMsg)
+		}
+		if m.selected == "" {
+			if tickers := m.watchlist.Tickers(); len(tickers) > 0 {
+				m.selected = tickers[0]
+			}
+		}
+	case sourceDoneMsg:
+		m.err = msg.err
+		m.status = "ended"
+	}
+	return m, nil
+}
+
+// applyStreamMessage applies a trade or candle of the watched tickers, and shows errors
+func (m *model) applyStreamMessage(msg sdk.StreamMessage) {
+	switch msg.Type {
+	case sdk.StreamMessageError:
+		m.err = errors.New(msg.Message)
+		return
+	case sdk.StreamMessageTrade, sdk.StreamMessageCandle:
+		if len(m.config.Tickers) > 0 && !slices.Contains(m.config.Tickers, messageTicker(msg)) {
+			return
+		}
+		m.watchlist.Apply(msg)
+	}
+}
+
+// messageTicker returns the ticker of a trade or candle message
+func messageTicker(msg sdk.StreamMessage) string {
+	switch {
+	case msg.Trade != nil:
+		return msg.Trade.Ticker
+	case msg.Candle != nil:
+		return msg.Candle.Ticker
+	}
+	return ""
+}
+
+// moveSelection moves the selected row by delta rows, clamped to the watchlist
+func (m *model) moveSelection(delta int) {
+	tickers := m.watchlist.Tickers()
+	if len(tickers) == 0 {
+		return
+	}
+	i := max(slices.Index(tickers, m.selected), 0)
+	m.selected = tickers[min(max(i+delta, 0), len(tickers)-1)]
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (m model) View() string {
+	if m.width == 0 {
+		return "" // wait for the window size
+	}
+	var view strings.Builder
+
+	// Title and status
+	status := dimStyle.Render(m.status)
+	if m.status == "live" {
+		status = upStyle.Render("● live")
+	}
+	view.WriteString(titleStyle.Render("dbn-goose") + " " + m.config.Title + "  " + status + "\n")
+	if m.err != nil {
+		view.WriteString(errorStyle.Render(truncate(m.err.Error(), m.width)))
+	}
+	view.WriteString("\n")
+
+	// Watchlist table, scrolled to keep the selected row visible
+	sparkHeight := min(max(m.height/3, minSparkHeight), maxSparkHeight)
+	visibleRows := max(m.height-fixedViewHeight-sparkHeight-sparkBorderSize, 1)
+	tickers := m.watchlist.Tickers()
+	offset := max(slices.Index(tickers, m.selected)-visibleRows+1, 0)
+
+	header := make([]string, len(watchlistColumns))
+	for i, column := range watchlistColumns {
+		header[i] = column.title
+	}
+	view.WriteString(headerStyle.Render(formatRow(header)) + "\n")
+	for i := offset; i < min(offset+visibleRows, len(tickers)); i++ {
+		view.WriteString(m.quoteRow(m.watchlist.Quote(tickers[i]), tickers[i] == m.selected) + "\n")
+	}
+	for i := len(tickers) - offset; i < visibleRows; i++ {
+		view.WriteString("\n")
+	}
+
+	// Sparkline of the selected symbol
+	view.WriteString(m.sparklineView(m.watchlist.Quote(m.selected), sparkHeight) + "\n")
+	view.WriteString(dimStyle.Render("↑/↓ select • g/G first/last • q quit"))
+	return view.String()
+}
+
+// quoteRow returns the watchlist row of a quote
+func (m model) quoteRow(quote *Quote, selected bool) string {
+	row := []string{quote.Ticker, "-", "-", "-", "-", "-", "-"}
+	if !quote.Updated.IsZero() {
+		row = []string{
+			quote.Ticker,
+			formatPrice(quote.Last),
+			fmt.Sprintf("%+.2f", quote.Change()),
+			fmt.Sprintf("%+.2f%%", quote.ChangePercent()),
+			formatCount(quote.Volume),
+			formatCount(uint64(quote.Trades)),
+			quote.Updated.Local().Format(time.TimeOnly),
+		}
+	}
+	line := formatRow(row)
+	switch {
+	case selected:
+		return selectedStyle.Render(line)
+	case quote.Change() > 0:
+		return upStyle.Render(line)
+	case quote.Change() < 0:
+		return downStyle.Render(line)
+	}
+	return line
+}
+
+// sparklineView returns the bordered sparkline of a quote's prices, offset from their low
+// so moves are visible, with its range as a caption
+func (m model) sparklineView(quote *Quote, height int) string {
+	width := max(m.width-sparkBorderSize, 1)
+	caption := "no symbol selected"
+	spark := sparkline.New(width, height, sparkline.WithStyle(sparkStyle), sparkline.WithNoAutoMaxValue())
+	if quote != nil {
+		caption = quote.Ticker + "  no prices yet"
+		if prices := quote.Prices(); len(prices) > 0 {
+			low, high := slices.Min(prices), slices.Max(prices)
+			floor := low - max((high-low)*0.1, low*0.0001) // the low gets a visible column
+			spark.SetMax(high - floor)
+			for _, price := range prices {
+				spark.Push(price - floor)
+			}
+			spark.Draw()
+			caption = fmt.Sprintf("%s  last %s  high %s  low %s  (%d minutes)",
+				quote.Ticker, formatPrice(quote.Last), formatPrice(high), formatPrice(low), len(prices))
+		}
+	}
+	return sparkBorder.Render(spark.View()) + "\n" + dimStyle.Render(truncate(caption, m.width))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// formatRow pads the cells to the watchlistColumns widths
+func formatRow(cells []string) string {
+	var line strings.Builder
+	for i, cell := range cells {
+		width := watchlistColumns[i].width
+		cell = truncate(cell, width)
+		if i == 0 {
+			line.WriteString(cell + strings.Repeat(" ", width-lipgloss.Width(cell)))
+		} else {
+			line.WriteString(strings.Repeat(" ", width-lipgloss.Width(cell)) + cell)
+		}
+	}
+	return line.String()
+}
+
+// formatPrice formats a price with cents, or more digits if below a dollar
+func formatPrice(price float64) string {
+	if price != 0 && price < 1 && price > -1 {
+		return strconv.FormatFloat(price, 'f', 4, 64)
+	}
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
+
+// formatCount formats a count with thousands separators
+func formatCount(n uint64) string {
+	digits := strconv.FormatUint(n, 10)
+	var out strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte(',')
+		}
+		out.WriteRune(digit)
+	}
+	return out.String()
+}
+
+// truncate shortens s to width columns
+func truncate(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && lipgloss.Width(string(runes)) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
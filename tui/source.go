@@ -0,0 +1,199 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-go"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+)
+
+// filePollInterval is how often a followed file is checked for new records
+const filePollInterval = 250 * time.Millisecond
+
+// Source sends the stream messages of a feed until ctx is done or the feed ends.
+// Returns the error that ended the feed, if any.
+type Source func(ctx context.Context, send func(sdk.StreamMessage)) error
+
+///////////////////////////////////////////////////////////////////////////////
+
+// StreamSource returns a Source of a server's WebSocket gateway at streamURL, such as
+// "ws://localhost:8888/ws/v1", subscribing to the schemas of the dataset's tickers.
+// Each ticker's candles since midnight Eastern are first fetched with the client,
+// so the session's open, volume and sparkline are complete.
+func StreamSource(client *sdk.Client, streamURL string, header http.Header, dataset string, schemas []string, tickers []string) Source {
+	return func(ctx context.Context, send func(sdk.StreamMessage)) error {
+		for _, ticker := range tickers {
+			candles, err := client.Candles(ctx, dataset, ticker, sdk.TimeRange{})
+			if err != nil {
+				return fmt.Errorf("failed to get candles of %s: %w", ticker, err)
+			}
+			for i := range candles {
+				candles[i].Ticker = ticker
+				send(sdk.StreamMessage{Type: sdk.StreamMessageCandle, Dataset: dataset, Candle: &candles[i]})
+			}
+		}
+
+		stream, err := sdk.DialStream(ctx, streamURL, header)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		for _, schema := range schemas {
+			if _, err := stream.Subscribe(dataset, schema, tickers...); err != nil {
+				return err
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case msg, ok := <-stream.Messages():
+				if !ok {
+					if err := stream.Err(); err != nil {
+						return fmt.Errorf("stream ended: %w", err)
+					}
+					return fmt.Errorf("stream ended")
+				}
+				send(msg)
+			}
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// FileSource returns a Source of the trades and candles of a DBN file, zstd-compressed
+// if it ends in ".zst" or ".zstd".  If follow is true, records appended to the file,
+// such as by the server's --out, are read as they are written, like 'tail -f'.
+func FileSource(filename string, follow bool) Source {
+	return func(ctx context.Context, send func(sdk.StreamMessage)) error {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		var reader io.Reader = file
+		if follow {
+			reader = &followReader{ctx: ctx, r: file}
+		}
+		if strings.HasSuffix(filename, ".zst") || strings.HasSuffix(filename, ".zstd") {
+			zstdReader, err := zstd.NewReader(reader)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", filename, err)
+			}
+			defer zstdReader.Close()
+			reader = zstdReader
+		}
+
+		dbnScanner := dbn.NewDbnScanner(reader)
+		metadata, err := dbnScanner.Metadata()
+		if err != nil {
+			return fmt.Errorf("failed to read metadata of %s: %w", filename, err)
+		}
+		visitor := &fileVisitor{dataset: metadata.Dataset, symbolMap: dbn.NewPitSymbolMap(), send: send}
+		midTime := metadata.Start + (metadata.End-metadata.Start)/2
+		visitor.symbolMap.FillFromMetadata(metadata, midTime)
+
+		for dbnScanner.Next() {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := dbnScanner.Visit(visitor); err != nil {
+				return fmt.Errorf("failed to visit record: %w", err)
+			}
+		}
+		if err := dbnScanner.Error(); err != nil && err != io.EOF {
+			return err
+		}
+		return nil
+	}
+}
+
+// followReader reads a file that is being appended to, waiting for more data at its end
+// until ctx is done.  Then it returns io.EOF.
+type followReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		select {
+		case <-f.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(filePollInterval):
+		}
+	}
+}
+
+// fileVisitor sends the trades and candles of a DBN file as stream messages
+type fileVisitor struct {
+	dbn.NullVisitor
+	dataset   string
+	symbolMap *dbn.PitSymbolMap
+	send      func(sdk.StreamMessage)
+}
+
+func (v *fileVisitor) OnMbp0(record *dbn.Mbp0Msg) error {
+	timestamp, nanos := dbn.TimestampToSecNanos(record.Header.TsEvent)
+	ticker := v.symbolMap.Get(record.Header.InstrumentID)
+	v.send(sdk.StreamMessage{
+		Type:    sdk.StreamMessageTrade,
+		Dataset: v.dataset,
+		Schema:  "trades",
+		Trade: &sdk.TradeTick{
+			Timestamp:   timestamp,
+			Nanos:       nanos,
+			PublisherID: record.Header.PublisherID,
+			Ticker:      ticker,
+			Price:       dbn.Fixed9ToFloat64(record.Price),
+			Shares:      int64(record.Size),
+		},
+	})
+	return nil
+}
+
+func (v *fileVisitor) OnOhlcv(record *dbn.OhlcvMsg) error {
+	timestamp, nanos := dbn.TimestampToSecNanos(record.Header.TsEvent)
+	ticker := v.symbolMap.Get(record.Header.InstrumentID)
+	v.send(sdk.StreamMessage{
+		Type:    sdk.StreamMessageCandle,
+		Dataset: v.dataset,
+		Schema:  "ohlcv-1m",
+		Candle: &sdk.Candle{
+			Timestamp:   timestamp,
+			Nanos:       nanos,
+			PublisherID: record.Header.PublisherID,
+			Ticker:      ticker,
+			Open:        dbn.Fixed9ToFloat64(record.Open),
+			High:        dbn.Fixed9ToFloat64(record.High),
+			Low:         dbn.Fixed9ToFloat64(record.Low),
+			Close:       dbn.Fixed9ToFloat64(record.Close),
+			Volume:      record.Volume,
+		},
+	})
+	return nil
+}
+
+// OnSymbolMappingMsg updates the symbol map, as live files map symbols in-band
+func (v *fileVisitor) OnSymbolMappingMsg(record *dbn.SymbolMappingMsg) error {
+	if err := v.symbolMap.OnSymbolMappingMsg(record); err != nil {
+		return fmt.Errorf("failed to handle SymbolMappingMsg: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2025 Neomantra Corp
+
+package tui
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NimbleMarkets/dbn-duckduck-goose/sdk"
+)
+
+const maxQuotePoints = 24 * 60 // minutes of prices kept for a Quote's sparkline
+
+// Quote is the session summary of a symbol on the watchlist
+type Quote struct {
+	Ticker  string
+	Open    float64   // First price of the session
+	Last    float64   // Latest price
+	Volume  uint64    // Session volume
+	Trades  int       // Number of trades seen
+	Updated time.Time // Time of the latest price
+
+	openMinute int64                  // minute of Open, from the epoch
+	points     []quotePoint           // last price of each minute, oldest first
+	volumes    map[int64]minuteVolume // volume of each minute
+}
+
+// quotePoint is the last price of a minute
+type quotePoint struct {
+	minute int64
+	at     time.Time // time of the price
+	price  float64
+}
+
+// minuteVolume is the volume of a minute, by candle and by summed trades.
+// A minute's candle supersedes its trades, which may have been missed.
+type minuteVolume struct {
+	candle uint64
+	trades uint64
+}
+
+func (v minuteVolume) total() uint64 {
+	return max(v.candle, v.trades)
+}
+
+// Change returns Last minus Open
+func (q *Quote) Change() float64 {
+	return q.Last - q.Open
+}
+
+// ChangePercent returns Change as a percent of Open
+func (q *Quote) ChangePercent() float64 {
+	if q.Open == 0 {
+		return 0
+	}
+	return 100 * q.Change() / q.Open
+}
+
+// Prices returns the last price of each minute, oldest first
+func (q *Quote) Prices() []float64 {
+	prices := make([]float64, len(q.points))
+	for i, point := range q.points {
+		prices[i] = point.price
+	}
+	return prices
+}
+
+// applyTrade updates the quote with a trade
+func (q *Quote) applyTrade(trade *sdk.TradeTick) {
+	at := time.Unix(trade.Timestamp, trade.Nanos)
+	minute := trade.Timestamp / 60
+	q.applyOpen(minute, trade.Price)
+	q.applyPrice(minute, at, trade.Price)
+	q.addVolume(minute, minuteVolume{trades: uint64(max(trade.Shares, 0))}, true)
+	q.Trades++
+}
+
+// applyCandle updates the quote with a one-minute candle, whose close is as of the end of its minute
+func (q *Quote) applyCandle(candle *sdk.Candle) {
+	at := time.Unix(candle.Timestamp, candle.Nanos).Add(time.Minute)
+	minute := candle.Timestamp / 60
+	q.applyOpen(minute, candle.Open)
+	q.applyPrice(minute, at, candle.Close)
+	q.addVolume(minute, minuteVolume{candle: candle.Volume}, false)
+}
+
+// applyOpen sets Open if the minute is the earliest seen
+func (q *Quote) applyOpen(minute int64, price float64) {
+	if q.Open == 0 || minute < q.openMinute {
+		q.Open, q.openMinute = price, minute
+	}
+}
+
+// applyPrice sets Last if at is the latest time seen, and records the minute's price
+func (q *Quote) applyPrice(minute int64, at time.Time, price float64) {
+	if !at.Before(q.Updated) {
+		q.Last, q.Updated = price, at
+	}
+	point := quotePoint{minute, at, price}
+	i, found := slices.BinarySearchFunc(q.points, minute, func(p quotePoint, minute int64) int {
+		return int(p.minute - minute)
+	})
+	switch {
+	case found:
+		if !at.Before(q.points[i].at) {
+			q.points[i] = point
+		}
+	case len(q.points) < maxQuotePoints:
+		q.points = slices.Insert(q.points, i, point)
+	case i > 0: // drop the oldest minute
+		q.points = slices.Insert(q.points[1:], i-1, point)
+	}
+}
+
+// addVolume adds to the minute's candle or trade volume, keeping Volume their total
+func (q *Quote) addVolume(minute int64, volume minuteVolume, isTrade bool) {
+	if q.volumes == nil {
+		q.volumes = make(map[int64]minuteVolume)
+	}
+	current := q.volumes[minute]
+	q.Volume -= current.total()
+	if isTrade {
+		current.trades += volume.trades
+	} else {
+		current.candle = volume.candle
+	}
+	q.Volume += current.total()
+	q.volumes[minute] = current
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Watchlist is the quotes of symbols, updated by stream messages
+type Watchlist struct {
+	quotes  map[string]*Quote
+	tickers []string // sorted
+}
+
+// NewWatchlist returns a Watchlist with empty quotes of the tickers
+func NewWatchlist(tickers ...string) *Watchlist {
+	w := &Watchlist{quotes: make(map[string]*Quote)}
+	for _, ticker := range tickers {
+		w.quote(ticker)
+	}
+	return w
+}
+
+// Apply updates the watchlist with a "trade" or "candle" message.
+// Returns true if a quote was updated.
+func (w *Watchlist) Apply(msg sdk.StreamMessage) bool {
+	switch {
+	case msg.Type == sdk.StreamMessageTrade && msg.Trade != nil && msg.Trade.Ticker != "":
+		w.quote(msg.Trade.Ticker).applyTrade(msg.Trade)
+		return true
+	case msg.Type == sdk.StreamMessageCandle && msg.Candle != nil && msg.Candle.Ticker != "":
+		w.quote(msg.Candle.Ticker).applyCandle(msg.Candle)
+		return true
+	}
+	return false
+}
+
+// Tickers returns the tickers of the watchlist, sorted
+func (w *Watchlist) Tickers() []string {
+	return w.tickers
+}
+
+// Quote returns the quote of the ticker, or nil if it is not on the watchlist
+func (w *Watchlist) Quote(ticker string) *Quote {
+	return w.quotes[ticker]
+}
+
+// quote returns the quote of the ticker, adding it to the watchlist if needed
+func (w *Watchlist) quote(ticker string) *Quote {
+	if quote, ok := w.quotes[ticker]; ok {
+		return quote
+	}
+	quote := &Quote{Ticker: ticker}
+	w.quotes[ticker] = quote
+	i, _ := slices.BinarySearchFunc(w.tickers, ticker, strings.Compare)
+	w.tickers = slices.Insert(w.tickers, i, ticker)
+	return quote
+}